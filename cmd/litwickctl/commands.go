@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/jobs"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+)
+
+type adjustmentResult struct {
+	DryRun         bool                       `json:"dry_run"`
+	UserID         uuid.UUID                  `json:"user_id"`
+	OrganizationID *uuid.UUID                 `json:"organization_id,omitempty"`
+	Amount         int                        `json:"amount"`
+	Reason         string                     `json:"reason"`
+	BalanceBefore  int                        `json:"balance_before"`
+	BalanceAfter   int                        `json:"balance_after"`
+	Transactions   []models.CreditTransaction `json:"transactions,omitempty"`
+}
+
+// resolveAccount reads --user and --organization. An organization's balance
+// can be adjusted without --user; its creator is recorded as the actor.
+func resolveAccount(userRef, orgRef string) (services.Account, error) {
+	if orgRef == "" {
+		user, err := findUser(userRef)
+		if err != nil {
+			return services.Account{}, err
+		}
+		return services.PersonalAccount(user.ID), nil
+	}
+
+	orgID, err := uuid.Parse(orgRef)
+	if err != nil {
+		return services.Account{}, fmt.Errorf("invalid organization ID %s", orgRef)
+	}
+	var org models.Organization
+	if err := database.DB.Where("id = ?", orgID).First(&org).Error; err != nil {
+		return services.Account{}, fmt.Errorf("organization %s not found", orgRef)
+	}
+
+	actor := org.CreatedByID
+	if userRef != "" {
+		user, err := findUser(userRef)
+		if err != nil {
+			return services.Account{}, err
+		}
+		actor = user.ID
+	}
+	return services.AccountFor(actor, &org.ID), nil
+}
+
+func adjustCredits(opts *options, name string, args []string, sign int) error {
+	fs := newChangeFlagSet(name, opts)
+	userRef := fs.String("user", "", "user ID or email")
+	orgRef := fs.String("organization", "", "organization ID, to adjust its shared pool")
+	amount := fs.Int("amount", 0, "minutes")
+	reason := fs.String("reason", "", "why, recorded in the ledger (required)")
+	expiresInDays := 0
+	if sign > 0 {
+		fs.IntVar(&expiresInDays, "expires-in-days", 0, "expire the granted minutes after this many days")
+	}
+	fs.Parse(args)
+
+	if *amount <= 0 {
+		return errors.New("--amount must be positive")
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return errors.New("--reason is required")
+	}
+
+	account, err := resolveAccount(*userRef, *orgRef)
+	if err != nil {
+		return err
+	}
+
+	before, err := services.AccountBalance(database.DB, account)
+	if err != nil {
+		return fmt.Errorf("failed to read balance: %w", err)
+	}
+
+	result := adjustmentResult{
+		DryRun:         opts.DryRun,
+		UserID:         account.UserID,
+		OrganizationID: account.OrganizationID,
+		Amount:         sign * *amount,
+		Reason:         strings.TrimSpace(*reason),
+		BalanceBefore:  before,
+		BalanceAfter:   before + sign**amount,
+	}
+
+	if result.BalanceAfter < 0 {
+		return fmt.Errorf("the balance is %d, can't remove %d minutes", before, *amount)
+	}
+
+	if !opts.DryRun {
+		var expiresAt *time.Time
+		if expiresInDays > 0 {
+			t := time.Now().AddDate(0, 0, expiresInDays)
+			expiresAt = &t
+		}
+		result.Transactions, err = services.AdjustCredits(database.DB, account, result.Amount, result.Reason, expiresAt)
+		if err != nil {
+			return err
+		}
+		if result.BalanceAfter, err = services.AccountBalance(database.DB, account); err != nil {
+			return err
+		}
+	}
+
+	output(opts, result, func() {
+		fmt.Printf("%sBalance of %s: %d -> %d minutes (%+d, %s)\n",
+			dryRunLabel(opts), account.OwnerID(), result.BalanceBefore, result.BalanceAfter, result.Amount, result.Reason)
+	})
+	return nil
+}
+
+func grantCredits(opts *options, args []string) error {
+	return adjustCredits(opts, "grant-credits", args, 1)
+}
+
+func revokeCredits(opts *options, args []string) error {
+	return adjustCredits(opts, "revoke-credits", args, -1)
+}
+
+func reconcilePayments(opts *options, args []string) error {
+	reconcileOpts := jobs.DefaultReconcileOptions("cli")
+
+	fs := newChangeFlagSet("reconcile-payments", opts)
+	fs.DurationVar(&reconcileOpts.PendingOlderThan, "pending-older-than", reconcileOpts.PendingOlderThan, "check pending payments created before this long ago")
+	fs.DurationVar(&reconcileOpts.RecentWindow, "recent-window", reconcileOpts.RecentWindow, "recheck payments settled within this window")
+	fs.DurationVar(&reconcileOpts.AbandonAfter, "abandon-after", reconcileOpts.AbandonAfter, "cancel checkouts abandoned for this long")
+	fs.Parse(args)
+	reconcileOpts.DryRun = opts.DryRun
+
+	run, report, err := services.ReconcilePayments(context.Background(), database.DB, services.NewMercadoPagoService(), reconcileOpts)
+	if err != nil {
+		return err
+	}
+
+	output(opts, map[string]interface{}{"run": run, "report": report}, func() {
+		fmt.Printf("%sReconciliation %s: checked=%d settled=%d discrepancies=%d errors=%d\n",
+			dryRunLabel(opts), run.ID, run.Checked, run.Settled, run.Discrepancies, run.Errors)
+	})
+	return nil
+}
+
+type requeuedJob struct {
+	ID         uuid.UUID `json:"id"`
+	FileName   string    `json:"file_name"`
+	UserID     uuid.UUID `json:"user_id"`
+	StuckSince time.Time `json:"stuck_since"`
+	Requeued   bool      `json:"requeued"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// requeueJobs hands stuck jobs back to the server, which runs them again
+// within a minute. Jobs only ever run in the server, so they can still be
+// cancelled from the app.
+func requeueJobs(opts *options, args []string) error {
+	fs := newChangeFlagSet("requeue-jobs", opts)
+	olderThan := fs.Duration("older-than", time.Hour, "requeue jobs processing without progress for this long")
+	limit := fs.Int("limit", 10, "requeue at most this many jobs")
+	fs.Parse(args)
+
+	cutoff := time.Now().Add(-*olderThan)
+	var stuck []models.Transcription
+	if err := database.DB.
+		Where("status = ? AND updated_at < ? AND requeued_at IS NULL", models.StatusProcessing, cutoff).
+		Order("updated_at").
+		Limit(*limit).
+		Find(&stuck).Error; err != nil {
+		return fmt.Errorf("failed to find stuck jobs: %w", err)
+	}
+
+	results := make([]requeuedJob, len(stuck))
+	for i, t := range stuck {
+		results[i] = requeuedJob{ID: t.ID, FileName: t.FileName, UserID: t.UserID, StuckSince: t.UpdatedAt}
+		if opts.DryRun {
+			continue
+		}
+
+		requeued, err := services.RequeueTranscription(database.DB, t.ID, cutoff)
+		switch {
+		case err != nil:
+			results[i].Error = err.Error()
+		case !requeued:
+			results[i].Error = "requeued by another run or no longer stuck"
+		default:
+			results[i].Requeued = true
+		}
+	}
+
+	output(opts, map[string]interface{}{"dry_run": opts.DryRun, "jobs": results}, func() {
+		fmt.Printf("%s%d stuck jobs\n", dryRunLabel(opts), len(results))
+		for _, job := range results {
+			status := "requeued"
+			if opts.DryRun {
+				status = "would requeue"
+			} else if !job.Requeued {
+				status = "skipped"
+			}
+			fmt.Printf("  %s  %-40s stuck since %s  %s %s\n", job.ID, job.FileName, job.StuckSince.Format(time.RFC3339), status, job.Error)
+		}
+	})
+	return nil
+}
+
+type purgedObject struct {
+	services.StoredObject
+	Status models.MediaDeletionStatus `json:"status,omitempty"`
+	Error  string                     `json:"error,omitempty"`
+}
+
+// purgeMedia deletes orphaned uploads through the deletion queue, so objects
+// storage fails to delete now are retried by the server
+func purgeMedia(opts *options, args []string) error {
+	fs := newChangeFlagSet("purge-media", opts)
+	minAge := fs.Duration("min-age", 24*time.Hour, "only purge objects older than this")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	storage, err := services.NewStorageService(ctx)
+	if err != nil {
+		return err
+	}
+
+	orphaned, err := services.FindOrphanedMedia(ctx, database.DB, storage, *minAge)
+	if err != nil {
+		return err
+	}
+
+	results := make([]purgedObject, len(orphaned))
+	for i, object := range orphaned {
+		results[i] = purgedObject{StoredObject: object}
+	}
+
+	var freed int64
+	if !opts.DryRun {
+		deletions, err := services.QueueOrphanedMedia(database.DB, orphaned)
+		if err != nil {
+			return err
+		}
+		for i, deletion := range deletions {
+			if err := services.DeleteQueuedMedia(ctx, database.DB, storage, deletion.ID); err != nil {
+				results[i].Error = err.Error()
+			}
+			var attempted models.MediaDeletion
+			if err := database.DB.Where("id = ?", deletion.ID).First(&attempted).Error; err == nil {
+				results[i].Status = attempted.Status
+				if attempted.LastError != "" {
+					results[i].Error = attempted.LastError
+				}
+			}
+			if results[i].Status == models.MediaDeletionDone {
+				freed += results[i].Size
+			}
+		}
+	}
+
+	output(opts, map[string]interface{}{"dry_run": opts.DryRun, "objects": results, "bytes_freed": freed}, func() {
+		fmt.Printf("%s%d orphaned objects\n", dryRunLabel(opts), len(results))
+		for _, object := range results {
+			state := "deleted"
+			switch {
+			case opts.DryRun:
+				state = "would delete"
+			case object.Status != models.MediaDeletionDone:
+				state = "queued for retry: " + object.Error
+			}
+			fmt.Printf("  %-60s %10d bytes  %s\n", object.Key, object.Size, state)
+		}
+		if !opts.DryRun {
+			fmt.Printf("%d bytes freed\n", freed)
+		}
+	})
+	return nil
+}
+
+type ledgerReport struct {
+	UserID         uuid.UUID                  `json:"user_id"`
+	OrganizationID *uuid.UUID                 `json:"organization_id,omitempty"`
+	CachedBalance  int                        `json:"cached_balance"`
+	LotsBalance    int                        `json:"lots_balance"`
+	LedgerBalance  *int                       `json:"ledger_balance,omitempty"` // balance_after of the latest transaction
+	Consistent     bool                       `json:"consistent"`
+	Lots           []models.CreditLot         `json:"lots"`
+	Transactions   []models.CreditTransaction `json:"transactions"`
+}
+
+func showLedger(opts *options, args []string) error {
+	fs := newFlagSet("ledger", opts)
+	userRef := fs.String("user", "", "user ID or email")
+	orgRef := fs.String("organization", "", "organization ID, to show its shared pool")
+	limit := fs.Int("limit", 50, "latest transactions to show")
+	fs.Parse(args)
+
+	account, err := resolveAccount(*userRef, *orgRef)
+	if err != nil {
+		return err
+	}
+
+	report := ledgerReport{UserID: account.UserID, OrganizationID: account.OrganizationID}
+	if report.CachedBalance, err = services.AccountBalance(database.DB, account); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := database.DB.Scopes(account.Scope).Order("purchased_at").Find(&report.Lots).Error; err != nil {
+		return err
+	}
+	for _, lot := range report.Lots {
+		if lot.IsUsable(now) {
+			report.LotsBalance += lot.Remaining
+		}
+	}
+
+	if err := database.DB.Scopes(account.Scope).Order("created_at DESC").Limit(*limit).Find(&report.Transactions).Error; err != nil {
+		return err
+	}
+	if len(report.Transactions) > 0 {
+		report.LedgerBalance = &report.Transactions[0].BalanceAfter
+	}
+
+	// Expired lots only leave the ledger when the expiry job runs, so the
+	// latest balance_after can be ahead of the lots until then
+	report.Consistent = report.CachedBalance == report.LotsBalance
+
+	output(opts, report, func() {
+		fmt.Printf("Account %s\n", account.OwnerID())
+		fmt.Printf("  cached balance: %d  usable lots: %d", report.CachedBalance, report.LotsBalance)
+		if report.LedgerBalance != nil {
+			fmt.Printf("  last transaction: %d", *report.LedgerBalance)
+		}
+		if !report.Consistent {
+			fmt.Print("  MISMATCH")
+		}
+		fmt.Println()
+
+		fmt.Println("\nLots:")
+		for _, lot := range report.Lots {
+			expires := "never"
+			if lot.ExpiresAt != nil {
+				expires = lot.ExpiresAt.Format("2006-01-02")
+			}
+			fmt.Printf("  %s  %-12s %6d/%-6d expires %-10s %s\n", lot.PurchasedAt.Format("2006-01-02"), lot.Source, lot.Remaining, lot.Amount, expires, lot.Description)
+		}
+
+		fmt.Println("\nTransactions:")
+		for _, t := range report.Transactions {
+			fmt.Printf("  %s  %-6s %6d  %6d -> %-6d %s\n", t.CreatedAt.Format("2006-01-02 15:04"), t.Type, t.Amount, t.BalanceBefore, t.BalanceAfter, t.Description)
+		}
+	})
+	return nil
+}
+
+func checkSchema(opts *options, args []string) error {
+	fs := newFlagSet("check-schema", opts)
+	fs.Parse(args)
+
+	migrations, err := database.MigrationStatus(database.DB)
+	if err != nil {
+		return err
+	}
+	tables, err := database.CheckSchema(database.DB)
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+	for _, m := range migrations {
+		if !m.Applied || m.Modified {
+			problems++
+		}
+	}
+	for _, t := range tables {
+		if !t.OK() {
+			problems++
+		}
+	}
+
+	output(opts, map[string]interface{}{"migrations": migrations, "tables": tables, "problems": problems}, func() {
+		fmt.Println("Migrations:")
+		for _, m := range migrations {
+			state := "pending"
+			if m.Applied {
+				state = "applied"
+			}
+			if m.Modified {
+				state = "modified since applied"
+			}
+			fmt.Printf("  %04d %-45s %s\n", m.Version, m.Name, state)
+		}
+		fmt.Println("\nTables:")
+		for _, t := range tables {
+			switch {
+			case !t.Exists:
+				fmt.Printf("  %-28s MISSING\n", t.Table)
+			case len(t.MissingColumns) > 0:
+				fmt.Printf("  %-28s missing columns: %s\n", t.Table, strings.Join(t.MissingColumns, ", "))
+			default:
+				fmt.Printf("  %-28s ok\n", t.Table)
+			}
+			if len(t.ExtraColumns) > 0 {
+				fmt.Printf("  %-28s extra columns: %s\n", "", strings.Join(t.ExtraColumns, ", "))
+			}
+		}
+	})
+
+	if problems > 0 {
+		return fmt.Errorf("%d schema problems found", problems)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// Command litwickctl runs operational tasks against the Litwick database and
+// services: credit corrections, payment reconciliation, stuck jobs, orphaned
+// media, ledger inspection and schema checks.
+//
+// Every command accepts --json, which prints the result as JSON, and the
+// commands that change anything accept --dry-run, which reports what would
+// change without changing it.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+)
+
+// command is one litwickctl subcommand. Read-only commands have no
+// --dry-run.
+type command struct {
+	summary  string
+	run      func(opts *options, args []string) error
+	readOnly bool
+}
+
+var commands = map[string]command{
+	"grant-credits":      {"Add minutes to a user's or organization's balance", grantCredits, false},
+	"revoke-credits":     {"Remove minutes from a user's or organization's balance", revokeCredits, false},
+	"reconcile-payments": {"Settle payments whose MercadoPago webhook was missed", reconcilePayments, false},
+	"requeue-jobs":       {"Hand transcriptions stuck in processing back to the server", requeueJobs, false},
+	"purge-media":        {"Delete stored uploads no transcription points to", purgeMedia, false},
+	"ledger":             {"Show a balance with its credit lots and transactions", showLedger, true},
+	"check-schema":       {"Compare the database with the migrations and models", checkSchema, true},
+}
+
+// options are the global flags
+type options struct {
+	DryRun bool
+	JSON   bool
+}
+
+// newFlagSet creates a command's flags, with --json
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.BoolVar(&opts.JSON, "json", opts.JSON, "print the result as JSON")
+	return fs
+}
+
+// newChangeFlagSet creates the flags of a command that changes data, with
+// --json and --dry-run
+func newChangeFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := newFlagSet(name, opts)
+	fs.BoolVar(&opts.DryRun, "dry-run", opts.DryRun, "report what would change without changing it")
+	return fs
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: litwickctl [--dry-run] [--json] <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun litwickctl <command> -h for the command's flags.")
+}
+
+func main() {
+	opts := &options{}
+	global := newChangeFlagSet("litwickctl", opts)
+	global.Usage = usage
+	global.Parse(os.Args[1:])
+
+	if global.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := global.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+	if cmd.readOnly && opts.DryRun {
+		fmt.Fprintf(os.Stderr, "%s doesn't change anything, --dry-run doesn't apply\n", name)
+		os.Exit(2)
+	}
+
+	// Logs go to stderr so --json output stays parseable
+	log.SetOutput(os.Stderr)
+
+	config.Load()
+	if err := database.Connect(); err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	defer database.Close()
+
+	// Settling payments and finishing jobs send notification emails
+	if err := services.ConfigureMail(); err != nil {
+		log.Fatal("Failed to configure mail: ", err)
+	}
+
+	if err := cmd.run(opts, global.Args()[1:]); err != nil {
+		if opts.JSON {
+			printJSON(map[string]string{"error": err.Error()})
+		} else {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// output prints the result as JSON with --json, or runs the text printer
+func output(opts *options, result interface{}, text func()) {
+	if opts.JSON {
+		printJSON(result)
+		return
+	}
+	text()
+}
+
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// findUser looks a user up by ID or email
+func findUser(ref string) (*models.User, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, fmt.Errorf("--user is required")
+	}
+
+	var user models.User
+	query := database.DB.Where("LOWER(email) = ?", strings.ToLower(ref))
+	if id, err := uuid.Parse(ref); err == nil {
+		query = database.DB.Where("id = ?", id)
+	}
+	if err := query.First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user %s not found", ref)
+	}
+	return &user, nil
+}
+
+// dryRunLabel marks text output of dry runs
+func dryRunLabel(opts *options) string {
+	if opts.DryRun {
+		return "[dry run] "
+	}
+	return ""
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/matills/litwick/internal/config"
 	"github.com/matills/litwick/internal/database"
 	"github.com/matills/litwick/internal/handlers"
+	"github.com/matills/litwick/internal/jobs"
 	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
 )
 
 func main() {
@@ -23,10 +31,30 @@ func main() {
 	}
 	log.Println("Database connected")
 
-	if err := database.Migrate(); err != nil {
-		log.Fatal("Failed to migrate database:", err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
 	}
-	log.Println("Database migrations completed")
+
+	if config.AppConfig.AutoMigrate {
+		if err := database.Migrate(); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+		log.Println("Database migrations completed")
+	} else if pending, err := database.PendingMigrations(); err != nil {
+		log.Printf("Failed to check migrations: %v", err)
+	} else if pending > 0 {
+		log.Printf("Warning: %d pending database migrations, run `litwick migrate up`", pending)
+	}
+
+	if err := services.ConfigureMail(); err != nil {
+		log.Fatal("Failed to configure mail: ", err)
+	}
+
+	jobs.Start(context.Background())
+	// The transcription worker lives in handlers, which jobs can't import
+	go jobs.Every(context.Background(), "resume-requeued-transcriptions", time.Minute, handlers.ResumeRequeuedTranscriptions)
+	log.Println("Background jobs started")
 
 	app := fiber.New(fiber.Config{
 		BodyLimit: 500 * 1024 * 1024,
@@ -36,7 +64,7 @@ func main() {
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     config.AppConfig.FrontendURL,
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Organization-ID, X-Share-Password, Range",
 		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
 		AllowCredentials: true,
 	}))
@@ -47,33 +75,200 @@ func main() {
 
 	auth := api.Group("/auth")
 	auth.Use(middleware.AuthMiddleware())
+	auth.Use(middleware.RequireScope(models.ScopeRead, ""))
 	auth.Get("/me", handlers.GetMe)
 	auth.Put("/settings", handlers.UpdateSettings)
+	auth.Get("/consents", handlers.GetEmailConsents)
+	auth.Get("/billing", handlers.GetBillingProfile)
+	auth.Put("/billing", handlers.UpdateBillingProfile)
+
+	account := api.Group("/account")
+	account.Use(middleware.AuthMiddleware())
+	account.Use(middleware.RequireScope("", ""))
+	account.Post("/export", handlers.ExportAccountData)
+	account.Delete("/", handlers.DeleteAccount)
 
 	dashboard := api.Group("/dashboard")
 	dashboard.Use(middleware.AuthMiddleware())
+	dashboard.Use(middleware.RequireScope(models.ScopeRead, models.ScopeRead))
+	dashboard.Use(middleware.OrganizationMiddleware())
+	dashboard.Use(middleware.RequireOrgRole(models.OrgRoleViewer, models.OrgRoleViewer))
 	dashboard.Get("/", handlers.GetDashboard)
 
 	upload := api.Group("/upload")
 	upload.Use(middleware.AuthMiddleware())
+	upload.Use(middleware.RequireScope(models.ScopeUpload, models.ScopeUpload))
+	upload.Use(middleware.OrganizationMiddleware())
+	upload.Use(middleware.RequireOrgRole(models.OrgRoleEditor, models.OrgRoleEditor))
 	upload.Post("/", handlers.UploadFile)
+	upload.Post("/batch", handlers.UploadBatch)
 
 	transcriptions := api.Group("/transcriptions")
 	transcriptions.Use(middleware.AuthMiddleware())
+	transcriptions.Use(middleware.RequireScope(models.ScopeRead, models.ScopeUpload))
+	transcriptions.Use(middleware.OrganizationMiddleware())
+	transcriptions.Use(middleware.RequireOrgRole(models.OrgRoleViewer, models.OrgRoleEditor))
 	transcriptions.Get("/", handlers.GetTranscriptions)
+	transcriptions.Post("/", handlers.CreateTranscriptionFromURL)
+	transcriptions.Get("/events", handlers.StreamTranscriptionEvents)
+	transcriptions.Get("/export", handlers.BulkExportTranscriptions)
+	transcriptions.Post("/bulk/delete", handlers.BulkDeleteTranscriptions)
+	transcriptions.Post("/bulk/reprocess", handlers.BulkReprocessTranscriptions)
+	transcriptions.Post("/bulk/move", handlers.BulkMoveTranscriptions)
+	transcriptions.Post("/bulk/tag", handlers.BulkTagTranscriptions)
+	transcriptions.Get("/tags", handlers.ListTags)
+	transcriptions.Get("/batches/:id", handlers.GetTranscriptionBatch)
+	transcriptions.Get("/trash", handlers.GetTrash)
+	transcriptions.Delete("/trash", handlers.EmptyTrash)
 	transcriptions.Post("/:id/process", handlers.ProcessTranscription)
+	transcriptions.Post("/:id/retry", handlers.RetryTranscription)
+	transcriptions.Post("/:id/cancel", handlers.CancelTranscription)
+	transcriptions.Post("/:id/reprocess", handlers.ReprocessTranscription)
+	transcriptions.Post("/:id/restore", handlers.RestoreTranscription)
+	transcriptions.Put("/:id/folder", handlers.MoveTranscription)
+	transcriptions.Put("/:id/tags", handlers.SetTranscriptionTags)
+	transcriptions.Get("/:id/waveform", handlers.GetTranscriptionWaveform)
+	transcriptions.Get("/:id/poster", handlers.GetTranscriptionPoster)
+	transcriptions.Post("/:id/analyze", handlers.AnalyzeTranscription)
+	transcriptions.Get("/:id/shares", handlers.ListShareLinks)
+	transcriptions.Post("/:id/shares", handlers.CreateShareLink)
+	transcriptions.Delete("/:id/shares/:shareId", handlers.RevokeShareLink)
+	transcriptions.Get("/:id/versions", handlers.GetTranscriptVersions)
+	transcriptions.Get("/:id/versions/diff", handlers.DiffTranscriptVersions)
+	transcriptions.Get("/:id/versions/:version", handlers.GetTranscriptVersion)
+	transcriptions.Post("/:id/versions/:version/restore", handlers.RestoreTranscriptVersion)
 	transcriptions.Get("/:id", handlers.GetTranscription)
 	transcriptions.Put("/:id", handlers.UpdateTranscription)
 	transcriptions.Delete("/:id", handlers.DeleteTranscription)
 	transcriptions.Get("/:id/download", handlers.DownloadTranscription)
 
+	folders := api.Group("/folders")
+	folders.Use(middleware.AuthMiddleware())
+	folders.Use(middleware.RequireScope(models.ScopeRead, models.ScopeUpload))
+	folders.Use(middleware.OrganizationMiddleware())
+	folders.Use(middleware.RequireOrgRole(models.OrgRoleViewer, models.OrgRoleEditor))
+	folders.Get("/", handlers.ListFolders)
+	folders.Post("/", handlers.CreateFolder)
+	folders.Put("/:id", handlers.UpdateFolder)
+	folders.Delete("/:id", handlers.DeleteFolder)
+
+	notifications := api.Group("/notifications")
+	notifications.Get("/unsubscribe", handlers.Unsubscribe)
+	notifications.Post("/unsubscribe", handlers.Unsubscribe)
+
+	// Throttle wrong passwords (and unknown tokens) per client and link; only
+	// failed requests count against the limit. It is attached to each route
+	// rather than the group so the :token param is available to the key.
+	shareLimiter := limiter.New(limiter.Config{
+		Max:                    10,
+		Expiration:             15 * time.Minute,
+		SkipSuccessfulRequests: true,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.IP() + "|" + c.Params("token")
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "too many attempts, try again later",
+			})
+		},
+	})
+	share := api.Group("/share")
+	share.Get("/:token", shareLimiter, handlers.GetSharedTranscript)
+	share.Get("/:token/download", shareLimiter, handlers.DownloadSharedTranscript)
+	share.Get("/:token/media", shareLimiter, handlers.StreamSharedMedia)
+
+	credits := api.Group("/credits")
+	credits.Use(middleware.AuthMiddleware())
+	credits.Use(middleware.RequireScope(models.ScopeBilling, models.ScopeBilling))
+	credits.Use(middleware.OrganizationMiddleware())
+	credits.Use(middleware.RequireOrgRole(models.OrgRoleAdmin, models.OrgRoleAdmin))
+	credits.Get("/lots", handlers.GetCreditLots)
+	credits.Get("/transactions", handlers.GetCreditTransactions)
+	credits.Get("/statements", handlers.GetUsageStatements)
+	credits.Get("/statements/:month", handlers.GetUsageStatement)
+
 	payments := api.Group("/payments")
 	payments.Get("/packages", handlers.GetCreditPackages)
 	payments.Post("/webhook", handlers.WebhookMercadoPago)
 	payments.Use(middleware.AuthMiddleware())
+	payments.Use(middleware.RequireScope(models.ScopeBilling, models.ScopeBilling))
+	payments.Use(middleware.OrganizationMiddleware())
+	payments.Use(middleware.RequireOrgRole(models.OrgRoleAdmin, models.OrgRoleAdmin))
 	payments.Post("/create", handlers.CreatePayment)
+	payments.Post("/promo/validate", handlers.ValidatePromoCode)
 	payments.Get("/history", handlers.GetPaymentHistory)
 	payments.Get("/success", handlers.ProcessPaymentSuccess)
+	payments.Get("/:id/receipt", handlers.GetPaymentReceipt)
+
+	organizations := api.Group("/organizations")
+	organizations.Use(middleware.AuthMiddleware())
+	organizations.Use(middleware.RequireScope("", ""))
+	organizations.Get("/", handlers.ListOrganizations)
+	organizations.Post("/", handlers.CreateOrganization)
+	organizations.Get("/:id", handlers.GetOrganization)
+	organizations.Put("/:id", handlers.UpdateOrganization)
+	organizations.Delete("/:id", handlers.DeleteOrganization)
+	organizations.Post("/:id/members", handlers.AddOrganizationMember)
+	organizations.Put("/:id/members/:userId", handlers.UpdateOrganizationMember)
+	organizations.Delete("/:id/members/:userId", handlers.RemoveOrganizationMember)
+
+	apiKeys := api.Group("/keys")
+	apiKeys.Use(middleware.AuthMiddleware())
+	apiKeys.Use(middleware.RequireScope("", ""))
+	apiKeys.Get("/", handlers.ListAPIKeys)
+	apiKeys.Post("/", handlers.CreateAPIKey)
+	apiKeys.Put("/:id", handlers.UpdateAPIKey)
+	apiKeys.Post("/:id/rotate", handlers.RotateAPIKey)
+	apiKeys.Delete("/:id", handlers.RevokeAPIKey)
+
+	webhooks := api.Group("/webhooks")
+	webhooks.Use(middleware.AuthMiddleware())
+	webhooks.Use(middleware.RequireScope("", ""))
+	webhooks.Get("/", handlers.ListWebhookEndpoints)
+	webhooks.Post("/", handlers.CreateWebhookEndpoint)
+	webhooks.Put("/:id", handlers.UpdateWebhookEndpoint)
+	webhooks.Delete("/:id", handlers.DeleteWebhookEndpoint)
+	webhooks.Post("/:id/roll-secret", handlers.RollWebhookSecret)
+	webhooks.Get("/:id/deliveries", handlers.ListWebhookDeliveries)
+	webhooks.Get("/:id/deliveries/:deliveryId", handlers.GetWebhookDelivery)
+	webhooks.Post("/:id/deliveries/:deliveryId/redeliver", handlers.RedeliverWebhookDelivery)
+
+	admin := api.Group("/admin")
+	admin.Use(middleware.AuthMiddleware())
+	admin.Use(middleware.AdminMiddleware())
+	admin.Use(middleware.AuditAdminActions())
+	admin.Get("/packages", handlers.AdminListCreditPackages)
+	admin.Post("/packages", handlers.AdminCreateCreditPackage)
+	admin.Put("/packages/:id", handlers.AdminUpdateCreditPackage)
+	admin.Delete("/packages/:id", handlers.AdminDeleteCreditPackage)
+	admin.Get("/promo-codes", handlers.AdminListPromoCodes)
+	admin.Post("/promo-codes", handlers.AdminCreatePromoCode)
+	admin.Put("/promo-codes/:id", handlers.AdminUpdatePromoCode)
+	admin.Delete("/promo-codes/:id", handlers.AdminDeletePromoCode)
+	admin.Get("/reconciliations", handlers.AdminListReconciliationRuns)
+	admin.Post("/reconciliations", handlers.AdminRunReconciliation)
+	admin.Get("/reconciliations/:id", handlers.AdminGetReconciliationRun)
+	admin.Get("/audience", handlers.AdminExportAudience)
+	admin.Get("/campaigns", handlers.AdminListCampaigns)
+	admin.Post("/campaigns", handlers.AdminCreateCampaign)
+	admin.Get("/campaigns/:id", handlers.AdminGetCampaign)
+	admin.Get("/users", handlers.AdminSearchUsers)
+	admin.Get("/users/:id", handlers.AdminGetUser)
+	admin.Get("/users/:id/consents", handlers.AdminGetUserConsents)
+	admin.Post("/users/:id/credits", handlers.AdminAdjustCredits)
+	admin.Post("/users/:id/impersonate", handlers.AdminImpersonateUser)
+	admin.Post("/users/:id/erase", handlers.AdminEraseUser)
+	admin.Delete("/impersonations/:id", handlers.AdminEndImpersonation)
+	admin.Get("/transcriptions", handlers.AdminListTranscriptions)
+	admin.Get("/transcriptions/:id", handlers.AdminGetTranscription)
+	admin.Post("/transcriptions/:id/retry", handlers.AdminRetryTranscription)
+	admin.Get("/payments", handlers.AdminListPayments)
+	admin.Get("/payments/:id", handlers.AdminGetPayment)
+	admin.Get("/audit-log", handlers.AdminListAuditLog)
+	admin.Get("/storage/orphans", handlers.AdminListOrphanedMedia)
+	admin.Post("/storage/orphans/purge", handlers.AdminPurgeOrphanedMedia)
+	admin.Get("/storage/deletions", handlers.AdminListMediaDeletions)
+	admin.Post("/storage/deletions/:id/retry", handlers.AdminRetryMediaDeletion)
 
 	distPath := "./frontend/dist"
 
@@ -93,3 +288,55 @@ func main() {
 		log.Fatal("Failed to start server:", err)
 	}
 }
+
+// runMigrate implements `litwick migrate up|down|status [steps]`
+func runMigrate(args []string) {
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	steps := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid number of steps: %s", args[1])
+		}
+		steps = n
+	}
+
+	switch command {
+	case "up":
+		applied, err := database.MigrateUp(database.DB, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := database.Seed(); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%d migrations applied", len(applied))
+	case "down":
+		reverted, err := database.MigrateDown(database.DB, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%d migrations reverted", len(reverted))
+	case "status":
+		states, err := database.MigrationStatus(database.DB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, state := range states {
+			status := "pending"
+			if state.Applied {
+				status = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if state.Modified {
+				status += " (modified since)"
+			}
+			fmt.Printf("%04d  %-45s %s\n", state.Version, state.Name, status)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q, use up, down or status", command)
+	}
+}
@@ -3,26 +3,46 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port                      string
-	Environment               string
-	DatabaseURL               string
-	SupabaseURL               string
-	SupabaseAnonKey           string
-	SupabaseServiceKey        string
-	SupabaseJWTSecret         string
-	AssemblyAIAPIKey          string
-	StorageBucket             string
-	StripeSecretKey           string
-	StripeWebhookSecret       string
-	MercadoPagoAccessToken    string
-	MercadoPagoWebhookSecret  string
-	WebhookURL                string
-	FrontendURL               string
+	Port                     string
+	Environment              string
+	DatabaseURL              string
+	SupabaseURL              string
+	SupabaseAnonKey          string
+	SupabaseServiceKey       string
+	SupabaseJWTSecret        string
+	AssemblyAIAPIKey         string
+	StorageBucket            string
+	StripeSecretKey          string
+	StripeWebhookSecret      string
+	MercadoPagoAccessToken   string
+	MercadoPagoWebhookSecret string
+	WebhookURL               string
+	DefaultCurrency          string
+	FreeCreditsExpiryDays    int
+	InvoicePrefix            string
+	ReconcileIntervalMinutes int
+	PaymentAbandonHours      int
+	LowCreditsThreshold      int
+	FrontendURL              string
+	MailTransport            string
+	MailFrom                 string
+	SMTPHost                 string
+	SMTPPort                 int
+	SMTPUsername             string
+	SMTPPassword             string
+	MailCaptureDir           string
+	UnsubscribeSecret        string
+	AutoMigrate              bool
+	MediaRetentionDays       map[string]int // plan -> days source media is kept; plans not listed keep it forever
+	FFmpegPath               string
+	FFprobePath              string
 }
 
 var AppConfig *Config
@@ -47,7 +67,25 @@ func Load() {
 		MercadoPagoAccessToken:   getEnv("MERCADOPAGO_ACCESS_TOKEN", ""),
 		MercadoPagoWebhookSecret: getEnv("MERCADOPAGO_WEBHOOK_SECRET", ""),
 		WebhookURL:               getEnv("WEBHOOK_URL", "http://localhost:8080"),
+		DefaultCurrency:          getEnv("DEFAULT_CURRENCY", "USD"),
+		FreeCreditsExpiryDays:    getEnvInt("FREE_CREDITS_EXPIRY_DAYS", 0),
+		InvoicePrefix:            getEnv("INVOICE_PREFIX", "LW"),
+		ReconcileIntervalMinutes: getEnvInt("RECONCILE_INTERVAL_MINUTES", 60),
+		PaymentAbandonHours:      getEnvInt("PAYMENT_ABANDON_HOURS", 48),
+		LowCreditsThreshold:      getEnvInt("LOW_CREDITS_THRESHOLD", 30),
 		FrontendURL:              getEnv("FRONTEND_URL", "http://localhost:5173"),
+		MailTransport:            getEnv("MAIL_TRANSPORT", "capture"),
+		MailFrom:                 getEnv("MAIL_FROM", "Litwick <no-reply@litwick.com>"),
+		SMTPHost:                 getEnv("SMTP_HOST", ""),
+		SMTPPort:                 getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:             getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:             getEnv("SMTP_PASSWORD", ""),
+		MailCaptureDir:           getEnv("MAIL_CAPTURE_DIR", ""),
+		UnsubscribeSecret:        getEnv("UNSUBSCRIBE_SECRET", ""),
+		AutoMigrate:              getEnvBool("AUTO_MIGRATE", true),
+		MediaRetentionDays:       getEnvIntMap("MEDIA_RETENTION_DAYS"),
+		FFmpegPath:               getEnv("FFMPEG_PATH", "ffmpeg"),
+		FFprobePath:              getEnv("FFPROBE_PATH", "ffprobe"),
 	}
 }
 
@@ -57,3 +95,49 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvIntMap parses "key=value" pairs separated by commas, such as
+// "free=30,pro=180". Invalid pairs are logged and skipped.
+func getEnvIntMap(key string) map[string]int {
+	result := map[string]int{}
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil {
+			log.Printf("Invalid entry in %s: %q, ignoring it", key, pair)
+			continue
+		}
+		result[strings.TrimSpace(name)] = parsed
+	}
+	return result
+}
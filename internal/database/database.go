@@ -33,24 +33,64 @@ func Connect() error {
 	return nil
 }
 
+// Migrate applies pending schema migrations and seeds reference data
 func Migrate() error {
 	log.Println("Running database migrations...")
 
-	err := DB.AutoMigrate(
-		&models.User{},
-		&models.Transcription{},
-		&models.CreditTransaction{},
-		&models.Payment{},
-	)
+	if _, err := MigrateUp(DB, 0); err != nil {
+		return err
+	}
 
-	if err != nil {
-		return fmt.Errorf("failed to migrate database: %w", err)
+	if err := Seed(); err != nil {
+		return err
 	}
 
 	log.Println("Database migrations completed successfully")
 	return nil
 }
 
+// PendingMigrations counts the migrations not applied yet
+func PendingMigrations() (int, error) {
+	states, err := MigrationStatus(DB)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, state := range states {
+		if !state.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Seed loads reference data the app needs, without touching existing rows
+func Seed() error {
+	if err := seedCreditPackages(); err != nil {
+		return fmt.Errorf("failed to seed credit packages: %w", err)
+	}
+	return nil
+}
+
+// seedCreditPackages loads the default catalog when the packages table is empty
+func seedCreditPackages() error {
+	var count int64
+	if err := DB.Model(&models.CreditPackage{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	packages := models.DefaultCreditPackages()
+	if err := DB.Create(&packages).Error; err != nil {
+		return err
+	}
+
+	log.Printf("Seeded %d default credit packages", len(packages))
+	return nil
+}
+
 func Close() error {
 	sqlDB, err := DB.DB()
 	if err != nil {
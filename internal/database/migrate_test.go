@@ -0,0 +1,282 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		versions []int64
+		err      string
+	}{
+		{
+			name: "ordered by version, not by file name",
+			files: fstest.MapFS{
+				"10_ten.up.sql":     file("SELECT 10;"),
+				"10_ten.down.sql":   file("SELECT -10;"),
+				"2_two.up.sql":      file("SELECT 2;"),
+				"2_two.down.sql":    file("SELECT -2;"),
+				"0001_one.up.sql":   file("SELECT 1;"),
+				"0001_one.down.sql": file("-- nothing to undo"),
+			},
+			versions: []int64{1, 2, 10},
+		},
+		{
+			name: "missing down file",
+			files: fstest.MapFS{
+				"0001_one.up.sql": file("SELECT 1;"),
+			},
+			err: "needs both an up and a down file",
+		},
+		{
+			name: "version with two names",
+			files: fstest.MapFS{
+				"0001_one.up.sql":   file("SELECT 1;"),
+				"0001_uno.down.sql": file("SELECT -1;"),
+			},
+			err: "has two names",
+		},
+		{
+			name: "unexpected file",
+			files: fstest.MapFS{
+				"0001_one.up.sql":   file("SELECT 1;"),
+				"0001_one.down.sql": file("SELECT -1;"),
+				"README.md":         file("notes"),
+			},
+			err: "unexpected migration file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var versions []int64
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+			}
+			if fmt.Sprint(versions) != fmt.Sprint(tt.versions) {
+				t.Errorf("versions = %v, want %v", versions, tt.versions)
+			}
+		})
+	}
+}
+
+func TestLoadMigrationsChecksum(t *testing.T) {
+	files := fstest.MapFS{
+		"0001_one.up.sql":   file("SELECT 1;"),
+		"0001_one.down.sql": file("SELECT -1;"),
+	}
+	migrations, err := loadMigrations(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sha256 of "SELECT 1;"
+	want := "17db4fd369edb9244b9f91d9aeed145c3d04ad8ba6e95d06247f07a63527d11a"
+	if got := migrations[0].Checksum; got != want {
+		t.Errorf("checksum = %q, want %q", got, want)
+	}
+
+	// The down file is not part of the checksum
+	files["0001_one.down.sql"] = file("SELECT -2;")
+	again, err := loadMigrations(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again[0].Checksum != migrations[0].Checksum {
+		t.Error("checksum changed with the down file")
+	}
+
+	files["0001_one.up.sql"] = file("SELECT 2;")
+	changed, err := loadMigrations(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed[0].Checksum == migrations[0].Checksum {
+		t.Error("checksum did not change with the up file")
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "one", Checksum: migrationChecksum("SELECT 1;")},
+		{Version: 2, Name: "two", Checksum: migrationChecksum("SELECT 2;")},
+	}
+
+	tests := []struct {
+		name    string
+		applied map[int64]SchemaMigration
+		wantErr bool
+	}{
+		{"nothing applied", map[int64]SchemaMigration{}, false},
+		{"matching checksum", map[int64]SchemaMigration{1: {Version: 1, Checksum: migrations[0].Checksum}}, false},
+		{"recorded without checksum", map[int64]SchemaMigration{1: {Version: 1}}, false},
+		{"edited after applying", map[int64]SchemaMigration{
+			1: {Version: 1, Checksum: migrations[0].Checksum},
+			2: {Version: 2, Checksum: migrationChecksum("SELECT 3;")},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyChecksums(migrations, tt.applied)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d_%s, want version %d: versions must have no gaps", m.Version, m.Name, i+1)
+		}
+		if !hasStatements(m.Up) {
+			t.Errorf("migration %d_%s has an empty up file", m.Version, m.Name)
+		}
+	}
+}
+
+// openTestDB connects to TEST_DATABASE_URL inside a schema of its own, so
+// tests start from an empty database. Tests using it are skipped when the
+// variable isn't set.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if strings.Contains(dsn, "://") {
+		if strings.Contains(dsn, "?") {
+			dsn += "&search_path=" + schema
+		} else {
+			dsn += "?search_path=" + schema
+		}
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func assertSchemaMatchesModels(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	tables, err := CheckSchema(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range tables {
+		if !table.OK() {
+			t.Errorf("table %s: exists=%v, missing columns %v", table.Table, table.Exists, table.MissingColumns)
+		}
+	}
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	assertSchemaMatchesModels(t, db)
+
+	states, err := MigrationStatus(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range states {
+		if !state.Applied || state.Modified {
+			t.Errorf("migration %d_%s: applied=%v modified=%v", state.Version, state.Name, state.Applied, state.Modified)
+		}
+	}
+}
+
+// Databases created by AutoMigrate before versioned migrations already have
+// the four original tables, which the CREATE TABLE IF NOT EXISTS in 0001
+// skip
+func TestMigrateFromBaselineSchema(t *testing.T) {
+	db := openTestDB(t)
+
+	baseline, err := os.ReadFile("testdata/baseline_schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(string(baseline)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	assertSchemaMatchesModels(t, db)
+
+	var lots int64
+	db.Table("credit_lots").Where("remaining = ?", 120).Count(&lots)
+	if lots != 1 {
+		t.Errorf("existing balance moved into %d lots, want 1", lots)
+	}
+	var versions int64
+	db.Table("transcript_versions").Where("version = 1").Count(&versions)
+	if versions != 1 {
+		t.Errorf("existing transcript recorded as %d versions, want 1", versions)
+	}
+
+	var defaultCredits *string
+	db.Raw(`SELECT column_default FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'credits_remaining'`).Scan(&defaultCredits)
+	if defaultCredits == nil || *defaultCredits != "0" {
+		t.Errorf("users.credits_remaining default = %v, want 0", defaultCredits)
+	}
+}
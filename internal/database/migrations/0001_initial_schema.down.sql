@@ -0,0 +1,25 @@
+-- Drops every table, and all data with it
+DROP TABLE IF EXISTS "webhook_attempts" CASCADE;
+DROP TABLE IF EXISTS "webhook_deliveries" CASCADE;
+DROP TABLE IF EXISTS "webhook_endpoints" CASCADE;
+DROP TABLE IF EXISTS "api_keys" CASCADE;
+DROP TABLE IF EXISTS "reconciliation_runs" CASCADE;
+DROP TABLE IF EXISTS "invoices" CASCADE;
+DROP TABLE IF EXISTS "billing_profiles" CASCADE;
+DROP TABLE IF EXISTS "promo_codes" CASCADE;
+DROP TABLE IF EXISTS "credit_package_prices" CASCADE;
+DROP TABLE IF EXISTS "credit_packages" CASCADE;
+DROP TABLE IF EXISTS "payments" CASCADE;
+DROP TABLE IF EXISTS "credit_transactions" CASCADE;
+DROP TABLE IF EXISTS "credit_lots" CASCADE;
+DROP TABLE IF EXISTS "impersonation_sessions" CASCADE;
+DROP TABLE IF EXISTS "admin_audit_logs" CASCADE;
+DROP TABLE IF EXISTS "email_campaigns" CASCADE;
+DROP TABLE IF EXISTS "email_consent_events" CASCADE;
+DROP TABLE IF EXISTS "share_links" CASCADE;
+DROP TABLE IF EXISTS "transcript_versions" CASCADE;
+DROP TABLE IF EXISTS "transcriptions" CASCADE;
+DROP TABLE IF EXISTS "transcription_batches" CASCADE;
+DROP TABLE IF EXISTS "organization_members" CASCADE;
+DROP TABLE IF EXISTS "organizations" CASCADE;
+DROP TABLE IF EXISTS "users" CASCADE;
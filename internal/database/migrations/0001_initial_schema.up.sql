@@ -0,0 +1,503 @@
+-- Schema as created by GORM AutoMigrate before versioned migrations.
+-- IF NOT EXISTS lets databases that were auto-migrated adopt it as is; the
+-- ALTERs at the end add the columns that users, transcriptions, payments and
+-- credit_transactions gained since their table was first auto-migrated.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "supabase_user_id" text NOT NULL,
+    "email" text NOT NULL,
+    "credits_remaining" bigint DEFAULT 0,
+    "plan" text DEFAULT 'free',
+    "role" text DEFAULT 'user',
+    "stripe_customer_id" text,
+    "default_language" text DEFAULT 'es',
+    "default_export_format" text DEFAULT 'srt',
+    "include_timestamps" boolean DEFAULT true,
+    "detect_speakers" boolean DEFAULT true,
+    "email_notifications" boolean DEFAULT true,
+    "promotional_emails" boolean DEFAULT false,
+    "locale" text DEFAULT 'es',
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_supabase_user_id" ON "users" ("supabase_user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+
+CREATE TABLE IF NOT EXISTS "organizations" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "name" text NOT NULL,
+    "credits_remaining" bigint DEFAULT 0,
+    "created_by_id" uuid NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "organization_members" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "organization_id" uuid NOT NULL,
+    "user_id" uuid NOT NULL,
+    "role" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_organization_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_organizations_members" FOREIGN KEY ("organization_id") REFERENCES "organizations"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_organization_members_user_id" ON "organization_members" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_org_member" ON "organization_members" ("organization_id","user_id");
+
+CREATE TABLE IF NOT EXISTS "transcription_batches" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "organization_id" uuid,
+    "name" text,
+    "language" text NOT NULL,
+    "speaker_labels" boolean NOT NULL DEFAULT false,
+    "total_files" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_transcription_batches_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_transcription_batches_organization_id" ON "transcription_batches" ("organization_id");
+CREATE INDEX IF NOT EXISTS "idx_transcription_batches_user_id" ON "transcription_batches" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "transcriptions" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "organization_id" uuid,
+    "batch_id" uuid,
+    "file_name" text NOT NULL,
+    "file_url" text NOT NULL,
+    "source_url" text,
+    "file_size" bigint,
+    "duration" bigint,
+    "status" text DEFAULT 'pending',
+    "assembly_ai_id" text,
+    "transcript_text" text,
+    "transcript_json" jsonb,
+    "srt_content" text,
+    "vtt_content" text,
+    "error_message" text,
+    "language" text DEFAULT 'es',
+    "speaker_labels" boolean NOT NULL DEFAULT false,
+    "version" bigint NOT NULL DEFAULT 1,
+    "credits_used" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "completed_at" timestamptz,
+    "cancelled_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_transcriptions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_transcription_batches_transcriptions" FOREIGN KEY ("batch_id") REFERENCES "transcription_batches"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_transcriptions_batch_id" ON "transcriptions" ("batch_id");
+CREATE INDEX IF NOT EXISTS "idx_transcriptions_organization_id" ON "transcriptions" ("organization_id");
+CREATE INDEX IF NOT EXISTS "idx_transcriptions_user_id" ON "transcriptions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "transcript_versions" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "transcription_id" uuid NOT NULL,
+    "version" bigint NOT NULL,
+    "reason" text NOT NULL,
+    "author_id" uuid,
+    "author_email" text,
+    "restored_from" bigint,
+    "language" text,
+    "speaker_labels" boolean NOT NULL DEFAULT false,
+    "assembly_ai_id" text,
+    "transcript_text" text,
+    "srt_content" text,
+    "vtt_content" text,
+    "duration" bigint,
+    "completed_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_transcriptions_versions" FOREIGN KEY ("transcription_id") REFERENCES "transcriptions"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_transcription_version" ON "transcript_versions" ("transcription_id","version");
+
+CREATE TABLE IF NOT EXISTS "share_links" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "transcription_id" uuid NOT NULL,
+    "created_by_id" uuid NOT NULL,
+    "token" text NOT NULL,
+    "password_hash" text,
+    "formats" text NOT NULL,
+    "expires_at" timestamptz,
+    "revoked_at" timestamptz,
+    "view_count" bigint NOT NULL DEFAULT 0,
+    "last_viewed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_share_links_transcription" FOREIGN KEY ("transcription_id") REFERENCES "transcriptions"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_share_links_token" ON "share_links" ("token");
+CREATE INDEX IF NOT EXISTS "idx_share_links_transcription_id" ON "share_links" ("transcription_id");
+
+CREATE TABLE IF NOT EXISTS "email_consent_events" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "list" text NOT NULL,
+    "granted" boolean,
+    "source" text NOT NULL,
+    "ip_address" text,
+    "user_agent" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_email_consent_events_user_id" ON "email_consent_events" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_email_consent_events_list" ON "email_consent_events" ("list");
+
+CREATE TABLE IF NOT EXISTS "email_campaigns" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "subject" text NOT NULL,
+    "body" text NOT NULL,
+    "locale" text,
+    "created_by_id" uuid NOT NULL,
+    "status" text NOT NULL DEFAULT 'sending',
+    "recipients" bigint,
+    "sent" bigint,
+    "failed" bigint,
+    "finished_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "admin_audit_logs" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "admin_id" uuid NOT NULL,
+    "action" text NOT NULL,
+    "path" text NOT NULL,
+    "target_type" text,
+    "target_id" uuid,
+    "details" jsonb,
+    "status_code" bigint,
+    "ip_address" text,
+    "user_agent" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_admin_audit_logs_admin" FOREIGN KEY ("admin_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_admin_audit_logs_created_at" ON "admin_audit_logs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_admin_audit_logs_target_id" ON "admin_audit_logs" ("target_id");
+CREATE INDEX IF NOT EXISTS "idx_admin_audit_logs_target_type" ON "admin_audit_logs" ("target_type");
+CREATE INDEX IF NOT EXISTS "idx_admin_audit_logs_action" ON "admin_audit_logs" ("action");
+CREATE INDEX IF NOT EXISTS "idx_admin_audit_logs_admin_id" ON "admin_audit_logs" ("admin_id");
+
+CREATE TABLE IF NOT EXISTS "impersonation_sessions" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "admin_id" uuid NOT NULL,
+    "user_id" uuid NOT NULL,
+    "token_hash" text NOT NULL,
+    "reason" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "ended_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_impersonation_sessions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_impersonation_sessions_admin" FOREIGN KEY ("admin_id") REFERENCES "users"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_impersonation_sessions_token_hash" ON "impersonation_sessions" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_impersonation_sessions_user_id" ON "impersonation_sessions" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_impersonation_sessions_admin_id" ON "impersonation_sessions" ("admin_id");
+
+CREATE TABLE IF NOT EXISTS "credit_lots" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "organization_id" uuid,
+    "source" text NOT NULL,
+    "payment_id" uuid,
+    "amount" bigint NOT NULL,
+    "remaining" bigint NOT NULL,
+    "description" text,
+    "purchased_at" timestamptz NOT NULL,
+    "expires_at" timestamptz,
+    "expired_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_credit_lots_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_credit_lots_payment_id" ON "credit_lots" ("payment_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_lots_organization_id" ON "credit_lots" ("organization_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_lots_user_id" ON "credit_lots" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_lots_expires_at" ON "credit_lots" ("expires_at");
+
+CREATE TABLE IF NOT EXISTS "credit_transactions" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "organization_id" uuid,
+    "transcription_id" uuid,
+    "credit_lot_id" uuid,
+    "type" text NOT NULL,
+    "amount" bigint NOT NULL,
+    "balance_before" bigint,
+    "balance_after" bigint,
+    "description" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_credit_transactions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"),
+    CONSTRAINT "fk_credit_transactions_credit_lot" FOREIGN KEY ("credit_lot_id") REFERENCES "credit_lots"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_credit_transactions_credit_lot_id" ON "credit_transactions" ("credit_lot_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_transactions_organization_id" ON "credit_transactions" ("organization_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_transactions_user_id" ON "credit_transactions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "payments" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "organization_id" uuid,
+    "mercado_pago_payment_id" text,
+    "preference_id" text,
+    "status" text DEFAULT 'pending',
+    "amount" decimal,
+    "currency" text DEFAULT 'ARS',
+    "credits_amount" bigint,
+    "credits_expire_days" bigint,
+    "package_name" text,
+    "package_id" text,
+    "original_amount" decimal,
+    "discount_amount" decimal,
+    "promo_code_id" uuid,
+    "promo_code" text,
+    "payment_method" text,
+    "payment_details" jsonb,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "completed_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_payments_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_payments_organization_id" ON "payments" ("organization_id");
+CREATE INDEX IF NOT EXISTS "idx_payments_user_id" ON "payments" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_payments_promo_code_id" ON "payments" ("promo_code_id");
+CREATE INDEX IF NOT EXISTS "idx_payments_package_id" ON "payments" ("package_id");
+CREATE INDEX IF NOT EXISTS "idx_payments_mercado_pago_payment_id" ON "payments" ("mercado_pago_payment_id");
+
+CREATE TABLE IF NOT EXISTS "credit_packages" (
+    "id" text,
+    "name" text NOT NULL,
+    "description" text,
+    "credits" bigint NOT NULL,
+    "expire_days" bigint DEFAULT 0,
+    "popular" boolean DEFAULT false,
+    "discount" bigint DEFAULT 0,
+    "sort_order" bigint DEFAULT 0,
+    "active" boolean NOT NULL,
+    "active_from" timestamptz,
+    "active_until" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "credit_package_prices" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "package_id" text NOT NULL,
+    "currency" text NOT NULL,
+    "amount" decimal NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_credit_packages_prices" FOREIGN KEY ("package_id") REFERENCES "credit_packages"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_package_currency" ON "credit_package_prices" ("package_id","currency");
+
+CREATE TABLE IF NOT EXISTS "promo_codes" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "code" text NOT NULL,
+    "description" text,
+    "discount_percent" bigint DEFAULT 0,
+    "discount_amount" decimal DEFAULT 0,
+    "currency" text,
+    "package_id" text,
+    "max_uses" bigint,
+    "max_uses_per_user" bigint,
+    "active" boolean NOT NULL,
+    "valid_from" timestamptz,
+    "valid_until" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_promo_codes_code" ON "promo_codes" ("code");
+
+CREATE TABLE IF NOT EXISTS "billing_profiles" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "legal_name" text,
+    "tax_id" text,
+    "address_line1" text,
+    "address_line2" text,
+    "city" text,
+    "state" text,
+    "postal_code" text,
+    "country" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_billing_profiles_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_billing_profiles_user_id" ON "billing_profiles" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "invoices" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "number" bigint NOT NULL,
+    "display_number" text NOT NULL,
+    "payment_id" uuid NOT NULL,
+    "user_id" uuid NOT NULL,
+    "email" text,
+    "legal_name" text,
+    "tax_id" text,
+    "address" text,
+    "description" text,
+    "credits_amount" bigint,
+    "subtotal" decimal,
+    "discount" decimal,
+    "total" decimal,
+    "currency" text,
+    "issued_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_payments_invoice" FOREIGN KEY ("payment_id") REFERENCES "payments"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_invoices_payment_id" ON "invoices" ("payment_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_invoices_number" ON "invoices" ("number");
+CREATE INDEX IF NOT EXISTS "idx_invoices_user_id" ON "invoices" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "reconciliation_runs" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "trigger" text NOT NULL,
+    "dry_run" boolean,
+    "checked" bigint,
+    "settled" bigint,
+    "discrepancies" bigint,
+    "errors" bigint,
+    "report" jsonb,
+    "started_at" timestamptz,
+    "finished_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "name" text NOT NULL,
+    "prefix" text NOT NULL,
+    "key_hash" text NOT NULL,
+    "scopes" text NOT NULL,
+    "last_used_at" timestamptz,
+    "last_used_ip" text,
+    "expires_at" timestamptz,
+    "rotated_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_api_keys_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key_hash" ON "api_keys" ("key_hash");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_endpoints" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "url" text NOT NULL,
+    "description" text,
+    "events" text NOT NULL,
+    "secret" text NOT NULL,
+    "active" boolean NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhook_endpoints_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_endpoints_user_id" ON "webhook_endpoints" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "endpoint_id" uuid NOT NULL,
+    "user_id" uuid NOT NULL,
+    "event" text NOT NULL,
+    "payload" jsonb NOT NULL,
+    "status" text DEFAULT 'pending',
+    "attempts" bigint,
+    "next_attempt_at" timestamptz,
+    "last_response_code" bigint,
+    "last_error" text,
+    "redelivery_of" uuid,
+    "delivered_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhook_deliveries_endpoint" FOREIGN KEY ("endpoint_id") REFERENCES "webhook_endpoints"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_endpoint_id" ON "webhook_deliveries" ("endpoint_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_next_attempt_at" ON "webhook_deliveries" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_status" ON "webhook_deliveries" ("status");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_user_id" ON "webhook_deliveries" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_attempts" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "delivery_id" uuid NOT NULL,
+    "response_code" bigint,
+    "response_body" text,
+    "error" text,
+    "duration_ms" bigint,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_webhook_deliveries_attempt_log" FOREIGN KEY ("delivery_id") REFERENCES "webhook_deliveries"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_attempts_delivery_id" ON "webhook_attempts" ("delivery_id");
+
+-- Tables that predate the new columns are skipped by the CREATEs above
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "role" text DEFAULT 'user';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "locale" text DEFAULT 'es';
+ALTER TABLE "users" ALTER COLUMN "credits_remaining" SET DEFAULT 0;
+
+ALTER TABLE "transcriptions" ADD COLUMN IF NOT EXISTS "organization_id" uuid;
+ALTER TABLE "transcriptions" ADD COLUMN IF NOT EXISTS "batch_id" uuid;
+ALTER TABLE "transcriptions" ADD COLUMN IF NOT EXISTS "source_url" text;
+ALTER TABLE "transcriptions" ADD COLUMN IF NOT EXISTS "speaker_labels" boolean NOT NULL DEFAULT false;
+ALTER TABLE "transcriptions" ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
+ALTER TABLE "transcriptions" ADD COLUMN IF NOT EXISTS "cancelled_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_transcriptions_batch_id" ON "transcriptions" ("batch_id");
+CREATE INDEX IF NOT EXISTS "idx_transcriptions_organization_id" ON "transcriptions" ("organization_id");
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_transcription_batches_transcriptions') THEN
+        ALTER TABLE "transcriptions" ADD CONSTRAINT "fk_transcription_batches_transcriptions"
+            FOREIGN KEY ("batch_id") REFERENCES "transcription_batches"("id");
+    END IF;
+END $$;
+
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "organization_id" uuid;
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "credits_expire_days" bigint;
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "package_id" text;
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "original_amount" decimal;
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "discount_amount" decimal;
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "promo_code_id" uuid;
+ALTER TABLE "payments" ADD COLUMN IF NOT EXISTS "promo_code" text;
+CREATE INDEX IF NOT EXISTS "idx_payments_organization_id" ON "payments" ("organization_id");
+CREATE INDEX IF NOT EXISTS "idx_payments_promo_code_id" ON "payments" ("promo_code_id");
+CREATE INDEX IF NOT EXISTS "idx_payments_package_id" ON "payments" ("package_id");
+
+ALTER TABLE "credit_transactions" ADD COLUMN IF NOT EXISTS "organization_id" uuid;
+ALTER TABLE "credit_transactions" ADD COLUMN IF NOT EXISTS "credit_lot_id" uuid;
+CREATE INDEX IF NOT EXISTS "idx_credit_transactions_credit_lot_id" ON "credit_transactions" ("credit_lot_id");
+CREATE INDEX IF NOT EXISTS "idx_credit_transactions_organization_id" ON "credit_transactions" ("organization_id");
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_credit_transactions_credit_lot') THEN
+        ALTER TABLE "credit_transactions" ADD CONSTRAINT "fk_credit_transactions_credit_lot"
+            FOREIGN KEY ("credit_lot_id") REFERENCES "credit_lots"("id");
+    END IF;
+END $$;
@@ -0,0 +1,2 @@
+-- Irreversible: the dropped columns are not used by any release that could
+-- be rolled back to, and the NOT NULL constraints would reject current rows
@@ -0,0 +1,15 @@
+-- Columns left over from the first payments integration, previously removed
+-- by hand with scripts/cleanup_payments.go and scripts/fix_payments*.go
+ALTER TABLE payments DROP COLUMN IF EXISTS mercado_pago_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS subscription_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS plan_type;
+ALTER TABLE payments DROP COLUMN IF EXISTS payment_type;
+ALTER TABLE payments DROP COLUMN IF EXISTS payment_method_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS external_reference;
+ALTER TABLE payments DROP COLUMN IF EXISTS mercado_pago_details;
+ALTER TABLE payments DROP COLUMN IF EXISTS processed_at;
+
+-- Payments are created before the checkout, so these are only known later
+ALTER TABLE payments ALTER COLUMN mercado_pago_payment_id DROP NOT NULL;
+ALTER TABLE payments ALTER COLUMN preference_id DROP NOT NULL;
+ALTER TABLE payments ALTER COLUMN payment_method DROP NOT NULL;
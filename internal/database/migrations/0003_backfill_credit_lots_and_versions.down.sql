@@ -0,0 +1 @@
+-- Nothing to undo: the backfilled rows are indistinguishable from live data
@@ -0,0 +1,17 @@
+-- Users created before lot accounting get a single non-expiring lot holding
+-- their existing balance
+INSERT INTO credit_lots (id, user_id, source, amount, remaining, description, purchased_at, created_at, updated_at)
+SELECT gen_random_uuid(), u.id, 'free_grant', u.credits_remaining, u.credits_remaining, 'Saldo previo', u.created_at, NOW(), NOW()
+FROM users u
+WHERE u.credits_remaining > 0
+AND NOT EXISTS (SELECT 1 FROM credit_lots l WHERE l.user_id = u.id);
+
+-- Transcripts created before versioning get their current content recorded
+-- as their current version
+INSERT INTO transcript_versions (id, transcription_id, version, reason, language, speaker_labels, assembly_ai_id,
+    transcript_text, srt_content, vtt_content, duration, completed_at, created_at)
+SELECT gen_random_uuid(), t.id, t.version, 'transcription', t.language, t.speaker_labels, t.assembly_ai_id,
+    t.transcript_text, t.srt_content, t.vtt_content, t.duration, t.completed_at, COALESCE(t.completed_at, t.updated_at)
+FROM transcriptions t
+WHERE t.transcript_text IS NOT NULL
+AND NOT EXISTS (SELECT 1 FROM transcript_versions v WHERE v.transcription_id = t.id AND v.version = t.version);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_transcriptions_requeued_at";
+ALTER TABLE "transcriptions" DROP COLUMN IF EXISTS "requeued_at";
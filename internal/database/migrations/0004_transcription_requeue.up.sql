@@ -0,0 +1,2 @@
+ALTER TABLE "transcriptions" ADD COLUMN IF NOT EXISTS "requeued_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_transcriptions_requeued_at" ON "transcriptions" ("requeued_at");
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "media_deletions";
+ALTER TABLE "transcriptions" DROP COLUMN IF EXISTS "media_purged_at";
@@ -0,0 +1,22 @@
+ALTER TABLE "transcriptions" ADD COLUMN IF NOT EXISTS "media_purged_at" timestamptz;
+
+CREATE TABLE IF NOT EXISTS "media_deletions" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "key" text NOT NULL,
+    "reason" text NOT NULL,
+    "transcription_id" uuid,
+    "user_id" uuid,
+    "size" bigint,
+    "status" text DEFAULT 'pending',
+    "attempts" bigint,
+    "next_attempt_at" timestamptz,
+    "last_error" text,
+    "completed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_media_deletions_key" ON "media_deletions" ("key");
+CREATE INDEX IF NOT EXISTS "idx_media_deletions_next_attempt_at" ON "media_deletions" ("next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_media_deletions_status" ON "media_deletions" ("status");
+CREATE INDEX IF NOT EXISTS "idx_media_deletions_user_id" ON "media_deletions" ("user_id");
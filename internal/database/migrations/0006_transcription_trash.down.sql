@@ -0,0 +1,5 @@
+-- Trashed transcriptions would reappear as live ones, so they are deleted
+-- for good; their media then shows up in the orphaned media report
+DELETE FROM "transcriptions" WHERE "deleted_at" IS NOT NULL;
+DROP INDEX IF EXISTS "idx_transcriptions_deleted_at";
+ALTER TABLE "transcriptions" DROP COLUMN IF EXISTS "deleted_at";
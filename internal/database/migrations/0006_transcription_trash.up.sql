@@ -0,0 +1,2 @@
+ALTER TABLE "transcriptions" ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_transcriptions_deleted_at" ON "transcriptions" ("deleted_at");
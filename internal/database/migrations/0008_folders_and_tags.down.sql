@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "transcription_tags";
+ALTER TABLE "transcriptions" DROP COLUMN IF EXISTS "folder_id";
+DROP TABLE IF EXISTS "folders";
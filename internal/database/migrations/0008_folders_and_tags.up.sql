@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS "folders" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "organization_id" uuid,
+    "name" text NOT NULL,
+    "description" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_folders_organization_id" ON "folders" ("organization_id");
+CREATE INDEX IF NOT EXISTS "idx_folders_user_id" ON "folders" ("user_id");
+
+ALTER TABLE "transcriptions" ADD COLUMN IF NOT EXISTS "folder_id" uuid;
+ALTER TABLE "transcriptions" ADD CONSTRAINT "fk_transcriptions_folder" FOREIGN KEY ("folder_id") REFERENCES "folders"("id") ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS "idx_transcriptions_folder_id" ON "transcriptions" ("folder_id");
+
+CREATE TABLE IF NOT EXISTS "transcription_tags" (
+    "transcription_id" uuid,
+    "tag" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("transcription_id","tag"),
+    CONSTRAINT "fk_transcriptions_tags" FOREIGN KEY ("transcription_id") REFERENCES "transcriptions"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_transcription_tags_tag" ON "transcription_tags" ("tag");
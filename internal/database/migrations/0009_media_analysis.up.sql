@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS "media_analyses" (
+    "transcription_id" uuid,
+    "status" text NOT NULL,
+    "attempts" bigint,
+    "error" text,
+    "container" text,
+    "duration_ms" bigint,
+    "bitrate" bigint,
+    "audio_codec" text,
+    "audio_channels" bigint,
+    "audio_sample_rate" bigint,
+    "audio_bitrate" bigint,
+    "video_codec" text,
+    "video_width" bigint,
+    "video_height" bigint,
+    "video_frame_rate" decimal,
+    "waveform_key" text,
+    "peaks_per_second" bigint,
+    "poster_key" text,
+    "analyzed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("transcription_id"),
+    CONSTRAINT "fk_transcriptions_media" FOREIGN KEY ("transcription_id") REFERENCES "transcriptions"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_media_analyses_status" ON "media_analyses" ("status");
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"sort"
+
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+// schemaModels are the models whose tables the migrations create. A model
+// added here needs a migration too.
+var schemaModels = []interface{}{
+	&models.User{},
+	&models.Organization{},
+	&models.OrganizationMember{},
+	&models.TranscriptionBatch{},
+	&models.Folder{},
+	&models.Transcription{},
+	&models.TranscriptVersion{},
+	&models.TranscriptionTag{},
+	&models.MediaAnalysis{},
+	&models.MediaDeletion{},
+	&models.ShareLink{},
+	&models.EmailConsentEvent{},
+	&models.EmailCampaign{},
+	&models.AdminAuditLog{},
+	&models.ImpersonationSession{},
+	&models.CreditTransaction{},
+	&models.Payment{},
+	&models.CreditPackage{},
+	&models.CreditPackagePrice{},
+	&models.PromoCode{},
+	&models.CreditLot{},
+	&models.BillingProfile{},
+	&models.Invoice{},
+	&models.ReconciliationRun{},
+	&models.APIKey{},
+	&models.WebhookEndpoint{},
+	&models.WebhookDelivery{},
+	&models.WebhookAttempt{},
+}
+
+// TableCheck compares a model with its table in the database
+type TableCheck struct {
+	Table          string   `json:"table"`
+	Exists         bool     `json:"exists"`
+	MissingColumns []string `json:"missing_columns,omitempty"` // in the model, not in the table
+	ExtraColumns   []string `json:"extra_columns,omitempty"`   // in the table, not in the model
+}
+
+// OK reports whether the table matches the model
+func (t TableCheck) OK() bool {
+	return t.Exists && len(t.MissingColumns) == 0
+}
+
+// CheckSchema compares every model with the live database. Extra columns are
+// reported but harmless; missing tables or columns mean a migration is
+// missing or wasn't applied.
+func CheckSchema(db *gorm.DB) ([]TableCheck, error) {
+	var checks []TableCheck
+	for _, model := range schemaModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+
+		check := TableCheck{Table: stmt.Schema.Table}
+		if !db.Migrator().HasTable(stmt.Schema.Table) {
+			checks = append(checks, check)
+			continue
+		}
+		check.Exists = true
+
+		columnTypes, err := db.Migrator().ColumnTypes(stmt.Schema.Table)
+		if err != nil {
+			return nil, err
+		}
+		live := map[string]bool{}
+		for _, column := range columnTypes {
+			live[column.Name()] = true
+		}
+
+		expected := map[string]bool{}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			expected[field.DBName] = true
+			if !live[field.DBName] {
+				check.MissingColumns = append(check.MissingColumns, field.DBName)
+			}
+		}
+		for name := range live {
+			if !expected[name] {
+				check.ExtraColumns = append(check.ExtraColumns, name)
+			}
+		}
+		sort.Strings(check.MissingColumns)
+		sort.Strings(check.ExtraColumns)
+
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
@@ -0,0 +1,94 @@
+-- The tables GORM AutoMigrate created before versioned migrations, with the
+-- leftovers of the first payments integration
+CREATE TABLE "users" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "supabase_user_id" text NOT NULL,
+    "email" text NOT NULL,
+    "credits_remaining" bigint DEFAULT 300,
+    "plan" text DEFAULT 'free',
+    "stripe_customer_id" text,
+    "default_language" text DEFAULT 'es',
+    "default_export_format" text DEFAULT 'srt',
+    "include_timestamps" boolean DEFAULT true,
+    "detect_speakers" boolean DEFAULT true,
+    "email_notifications" boolean DEFAULT true,
+    "promotional_emails" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_users_supabase_user_id" ON "users" ("supabase_user_id");
+CREATE UNIQUE INDEX "idx_users_email" ON "users" ("email");
+
+CREATE TABLE "transcriptions" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "file_name" text NOT NULL,
+    "file_url" text NOT NULL,
+    "file_size" bigint,
+    "duration" bigint,
+    "status" text DEFAULT 'pending',
+    "assembly_ai_id" text,
+    "transcript_text" text,
+    "transcript_json" jsonb,
+    "srt_content" text,
+    "vtt_content" text,
+    "error_message" text,
+    "language" text DEFAULT 'es',
+    "credits_used" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "completed_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_transcriptions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_transcriptions_user_id" ON "transcriptions" ("user_id");
+
+CREATE TABLE "credit_transactions" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "transcription_id" uuid,
+    "type" text NOT NULL,
+    "amount" bigint NOT NULL,
+    "balance_before" bigint,
+    "balance_after" bigint,
+    "description" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_credit_transactions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_credit_transactions_user_id" ON "credit_transactions" ("user_id");
+
+CREATE TABLE "payments" (
+    "id" uuid DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "mercado_pago_id" text,
+    "mercado_pago_payment_id" text NOT NULL,
+    "preference_id" text NOT NULL,
+    "subscription_id" text,
+    "plan_type" text,
+    "payment_type" text,
+    "payment_method_id" text,
+    "external_reference" text,
+    "mercado_pago_details" jsonb,
+    "processed_at" timestamptz,
+    "status" text DEFAULT 'pending',
+    "amount" decimal,
+    "currency" text DEFAULT 'ARS',
+    "credits_amount" bigint,
+    "package_name" text,
+    "payment_method" text NOT NULL,
+    "payment_details" jsonb,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "completed_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_payments_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_payments_user_id" ON "payments" ("user_id");
+CREATE INDEX "idx_payments_mercado_pago_payment_id" ON "payments" ("mercado_pago_payment_id");
+
+INSERT INTO "users" ("id", "supabase_user_id", "email", "credits_remaining", "created_at", "updated_at")
+VALUES ('00000000-0000-0000-0000-000000000001', 'sb-1', 'ana@example.com', 120, now(), now());
+INSERT INTO "transcriptions" ("user_id", "file_name", "file_url", "duration", "status", "assembly_ai_id", "transcript_text", "credits_used", "created_at", "updated_at", "completed_at")
+VALUES ('00000000-0000-0000-0000-000000000001', 'clip.mp3', 'https://example.com/clip.mp3', 90, 'completed', 'aai-1', 'hola', 2, now(), now(), now());
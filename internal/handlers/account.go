@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+	"github.com/valyala/fasthttp"
+	"gorm.io/gorm"
+)
+
+// exportFile is one JSON file of a data export
+type exportFile struct {
+	name string
+	data interface{}
+}
+
+// loadAccountExport collects everything about the user except the
+// transcripts themselves, which are streamed one at a time
+func loadAccountExport(user *models.User) ([]exportFile, error) {
+	personal := services.PersonalAccount(user.ID)
+	owned := func() *gorm.DB { return database.DB.Where("user_id = ?", user.ID).Order("created_at") }
+
+	var (
+		consents     []models.EmailConsentEvent
+		payments     []models.Payment
+		transactions []models.CreditTransaction
+		lots         []models.CreditLot
+		apiKeys      []models.APIKey
+		webhooks     []models.WebhookEndpoint
+		memberships  []models.OrganizationMember
+		profiles     []models.BillingProfile
+		folders      []models.Folder
+	)
+	files := []exportFile{
+		{"profile.json", user},
+		{"consents.json", &consents},
+		{"payments.json", &payments},
+		{"credit_transactions.json", &transactions},
+		{"credit_lots.json", &lots},
+		{"api_keys.json", &apiKeys},
+		{"webhooks.json", &webhooks},
+		{"organizations.json", &memberships},
+		{"billing_profile.json", &profiles},
+		{"folders.json", &folders},
+	}
+
+	for _, err := range []error{
+		owned().Find(&consents).Error,
+		owned().Preload("Invoice").Find(&payments).Error,
+		database.DB.Scopes(personal.Scope).Order("created_at").Find(&transactions).Error,
+		database.DB.Scopes(personal.Scope).Order("created_at").Find(&lots).Error,
+		owned().Find(&apiKeys).Error,
+		owned().Find(&webhooks).Error,
+		owned().Preload("Organization").Find(&memberships).Error,
+		owned().Find(&profiles).Error,
+		database.DB.Scopes(personal.Scope).Order("created_at").Find(&folders).Error,
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// ExportAccountData downloads a ZIP with everything stored about the user:
+// profile and settings, consent history, payments with their receipts,
+// credit history, API keys, webhooks, organization memberships, folders and every
+// personal transcription (trashed ones included) in txt, srt, vtt and the
+// provider's JSON, with its version history.
+func ExportAccountData(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	files, err := loadAccountExport(user)
+	if err != nil {
+		log.Printf("Failed to export account %s: %v", user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to export account data",
+		})
+	}
+
+	var transcriptions []models.Transcription
+	if err := database.DB.Unscoped().Scopes(services.PersonalAccount(user.ID).Scope).
+		Omit("transcript_text", "transcript_json", "srt_content", "vtt_content").
+		Preload("Tags").
+		Preload("Media").
+		Order("created_at").
+		Find(&transcriptions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch transcriptions",
+		})
+	}
+	files = append(files, exportFile{"transcriptions.json", transcriptions})
+
+	filename := fmt.Sprintf("litwick-datos-%s.zip", time.Now().Format("2006-01-02"))
+	c.Set("Content-Type", "application/zip")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		archive := zip.NewWriter(w)
+
+		for _, file := range files {
+			if err := writeArchiveJSON(archive, file.name, file.data); err != nil {
+				log.Printf("Failed to add %s to account export: %v", file.name, err)
+				return
+			}
+		}
+
+		used := map[string]int{}
+		for _, meta := range transcriptions {
+			var t models.Transcription
+			if err := database.DB.Unscoped().Where("id = ?", meta.ID).First(&t).Error; err != nil {
+				log.Printf("Failed to load %s for account export: %v", meta.ID, err)
+				return
+			}
+			folder := "transcriptions/" + uniqueArchiveName(used, strings.TrimSuffix(path.Base(t.FileName), path.Ext(t.FileName)))
+			if err := writeTranscriptionExport(archive, folder, &t); err != nil {
+				log.Printf("Failed to add %s to account export: %v", t.ID, err)
+				return
+			}
+		}
+
+		if err := archive.Close(); err != nil {
+			log.Printf("Failed to finish account export: %v", err)
+			return
+		}
+		w.Flush()
+	}))
+
+	return nil
+}
+
+// writeTranscriptionExport adds a transcription's formats and versions under
+// folder
+func writeTranscriptionExport(archive *zip.Writer, folder string, t *models.Transcription) error {
+	for _, format := range []string{"txt", "srt", "vtt"} {
+		content, _, _ := exportTranscript(t, format)
+		if content == "" {
+			continue
+		}
+		entry, err := archive.Create(folder + "/transcript." + format)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+
+	if t.TranscriptJSON != nil {
+		entry, err := archive.Create(folder + "/transcript.json")
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write([]byte(*t.TranscriptJSON)); err != nil {
+			return err
+		}
+	}
+
+	var versions []models.TranscriptVersion
+	if err := database.DB.Where("transcription_id = ?", t.ID).Order("version").Find(&versions).Error; err != nil {
+		return err
+	}
+	return writeArchiveJSON(archive, folder+"/versions.json", versions)
+}
+
+func writeArchiveJSON(archive *zip.Writer, name string, data interface{}) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(entry)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// DeleteAccount erases the user's account after they confirm it by typing
+// their email. See services.EraseAccount for what is deleted and what is
+// kept anonymized.
+func DeleteAccount(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	type DeleteAccountRequest struct {
+		ConfirmEmail string `json:"confirm_email"`
+	}
+
+	var req DeleteAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(req.ConfirmEmail), user.Email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "confirm_email must match your account email",
+		})
+	}
+
+	return eraseAccount(c, user)
+}
+
+// eraseAccount runs the erasure and reports it, listing the organizations
+// that block it if any
+func eraseAccount(c *fiber.Ctx, user *models.User) error {
+	result, err := services.EraseAccount(c.Context(), database.DB, user.ID)
+	if errors.Is(err, services.ErrOrganizationsNeedOwner) {
+		orgs, _ := services.OrganizationsOwnedAlone(database.DB, user.ID)
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":         err.Error(),
+			"organizations": orgs,
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to erase account %s: %v", user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete account",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "account deleted",
+		"result":  result,
+	})
+}
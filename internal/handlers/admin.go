@@ -0,0 +1,597 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+)
+
+const (
+	maxImpersonationMinutes     = 120
+	defaultImpersonationMinutes = 30
+)
+
+// adminPage reads page and limit, capping the page size
+func adminPage(c *fiber.Ctx) (int, int) {
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	return page, limit
+}
+
+func adminPagination(page, limit int, total int64) fiber.Map {
+	return fiber.Map{
+		"page":       page,
+		"limit":      limit,
+		"total":      total,
+		"totalPages": (total + int64(limit) - 1) / int64(limit),
+	}
+}
+
+// adminFindUser loads the user in the :id param
+func adminFindUser(c *fiber.Ctx) (*models.User, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user ID",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+	return &user, nil
+}
+
+// AdminSearchUsers finds users by email fragment or exact ID
+func AdminSearchUsers(c *fiber.Ctx) error {
+	page, limit := adminPage(c)
+
+	query := database.DB.Model(&models.User{})
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		if id, err := uuid.Parse(q); err == nil {
+			query = query.Where("id = ? OR supabase_user_id = ?", id, q)
+		} else {
+			query = query.Where("email ILIKE ?", "%"+strings.ToLower(q)+"%")
+		}
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var users []models.User
+	if err := query.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&users).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch users",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"users":      users,
+		"pagination": adminPagination(page, limit, total),
+	})
+}
+
+// AdminGetUser returns a user with their organizations, job counts and
+// latest ledger entries
+func AdminGetUser(c *fiber.Ctx) error {
+	user, err := adminFindUser(c)
+	if user == nil {
+		return err
+	}
+
+	var memberships []models.OrganizationMember
+	database.DB.Preload("Organization").Where("user_id = ?", user.ID).Find(&memberships)
+
+	type statusCount struct {
+		Status string `json:"status"`
+		Count  int64  `json:"count"`
+	}
+	var jobs []statusCount
+	database.DB.Model(&models.Transcription{}).
+		Select("status, COUNT(*) AS count").
+		Where("user_id = ?", user.ID).
+		Group("status").
+		Scan(&jobs)
+
+	var transactions []models.CreditTransaction
+	database.DB.Scopes(services.PersonalAccount(user.ID).Scope).Order("created_at DESC").Limit(20).Find(&transactions)
+
+	var payments []models.Payment
+	database.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(10).Find(&payments)
+
+	return c.JSON(fiber.Map{
+		"user":                user,
+		"organizations":       memberships,
+		"transcriptions":      jobs,
+		"recent_transactions": transactions,
+		"recent_payments":     payments,
+	})
+}
+
+// AdminAdjustCredits grants or removes minutes from a user's balance, or
+// from one of their organizations' pools, through the credit ledger. A
+// reason is required and becomes the transaction description.
+func AdminAdjustCredits(c *fiber.Ctx) error {
+	user, err := adminFindUser(c)
+	if user == nil {
+		return err
+	}
+
+	type AdjustCreditsRequest struct {
+		Amount         int        `json:"amount"` // positive grants, negative removes
+		Reason         string     `json:"reason"`
+		OrganizationID *uuid.UUID `json:"organization_id"`
+		ExpiresInDays  int        `json:"expires_in_days"`
+	}
+
+	var req AdjustCreditsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Amount == 0 || req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "a non-zero amount and a reason are required",
+		})
+	}
+
+	account := services.PersonalAccount(user.ID)
+	if req.OrganizationID != nil {
+		var member models.OrganizationMember
+		if err := database.DB.Where("organization_id = ? AND user_id = ?", *req.OrganizationID, user.ID).First(&member).Error; err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "user is not a member of this organization",
+			})
+		}
+		account = services.AccountFor(user.ID, req.OrganizationID)
+	}
+
+	admin := middleware.GetUser(c)
+
+	var expiresAt *time.Time
+	if req.Amount > 0 && req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	transactions, err := services.AdjustCredits(database.DB, account, req.Amount, req.Reason, expiresAt)
+	if errors.Is(err, services.ErrInsufficientCredits) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "the balance is lower than the amount to remove",
+		})
+	}
+	if err != nil {
+		log.Printf("Admin credit adjustment for %s failed: %v", user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to adjust credits",
+		})
+	}
+
+	balance, _ := services.AccountBalance(database.DB, account)
+
+	middleware.SetAuditTarget(c, "user", &user.ID, map[string]interface{}{
+		"amount":          req.Amount,
+		"reason":          req.Reason,
+		"organization_id": req.OrganizationID,
+		"balance_after":   balance,
+	})
+	log.Printf("Admin %s adjusted credits of %s by %d: %s", admin.ID, user.ID, req.Amount, req.Reason)
+
+	return c.JSON(fiber.Map{
+		"credits_remaining": balance,
+		"transactions":      transactions,
+	})
+}
+
+// AdminListTranscriptions lists jobs across all accounts, filtered by status,
+// user or organization
+func AdminListTranscriptions(c *fiber.Ctx) error {
+	page, limit := adminPage(c)
+
+	query := database.DB.Model(&models.Transcription{}).
+		Omit("transcript_text", "transcript_json", "srt_content", "vtt_content")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	for param, column := range map[string]string{"user_id": "user_id", "organization_id": "organization_id"} {
+		if value := c.Query(param); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid " + param,
+				})
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+	if c.QueryBool("trashed", false) {
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	// Jobs processing for longer than this many minutes are probably stuck
+	if minutes := c.QueryInt("stuck_minutes", 0); minutes > 0 {
+		query = query.Where("status = ? AND updated_at < ?", models.StatusProcessing, time.Now().Add(-time.Duration(minutes)*time.Minute))
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var transcriptions []models.Transcription
+	if err := query.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&transcriptions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch transcriptions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"transcriptions": transcriptions,
+		"pagination":     adminPagination(page, limit, total),
+	})
+}
+
+func adminFindTranscription(c *fiber.Ctx) (*models.Transcription, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid transcription ID",
+		})
+	}
+
+	// Trashed jobs are included so support can look at them
+	var transcription models.Transcription
+	if err := database.DB.Unscoped().Where("id = ?", id).First(&transcription).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcription not found",
+		})
+	}
+	return &transcription, nil
+}
+
+// AdminGetTranscription returns a job with its ledger entries and versions
+func AdminGetTranscription(c *fiber.Ctx) error {
+	transcription, err := adminFindTranscription(c)
+	if transcription == nil {
+		return err
+	}
+
+	var transactions []models.CreditTransaction
+	database.DB.Where("transcription_id = ?", transcription.ID).Order("created_at").Find(&transactions)
+
+	var versions []models.TranscriptVersion
+	database.DB.Omit("transcript_text", "srt_content", "vtt_content").
+		Where("transcription_id = ?", transcription.ID).Order("version").Find(&versions)
+
+	runningTranscriptions.Lock()
+	_, running := runningTranscriptions.cancels[transcription.ID]
+	runningTranscriptions.Unlock()
+
+	return c.JSON(fiber.Map{
+		"transcription":       transcription,
+		"transactions":        transactions,
+		"versions":            versions,
+		"running_in_instance": running,
+	})
+}
+
+// AdminRetryTranscription restarts a failed, cancelled or pending job on the
+// owner's account. A job stuck in processing can be restarted with
+// ?force=true when this instance isn't running it.
+func AdminRetryTranscription(c *fiber.Ctx) error {
+	transcription, err := adminFindTranscription(c)
+	if transcription == nil {
+		return err
+	}
+
+	if transcription.DeletedAt.Valid {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "transcription is in the trash",
+		})
+	}
+
+	previous := transcription.Status
+	switch transcription.Status {
+	case models.StatusFailed, models.StatusCancelled, models.StatusPending:
+	case models.StatusProcessing:
+		runningTranscriptions.Lock()
+		_, running := runningTranscriptions.cancels[transcription.ID]
+		runningTranscriptions.Unlock()
+		if running || !c.QueryBool("force", false) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "transcription is processing; pass force=true to restart a stuck job",
+			})
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "completed transcriptions can't be retried",
+		})
+	}
+
+	if !transcription.HasMedia() {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error": errMediaPurged,
+		})
+	}
+
+	if err := startTranscription(transcription, []models.TranscriptionStatus{previous}, nil); err != nil {
+		return startTranscriptionError(c, err)
+	}
+
+	middleware.SetAuditTarget(c, "transcription", &transcription.ID, map[string]interface{}{
+		"previous_status": previous,
+		"user_id":         transcription.UserID,
+	})
+
+	return c.JSON(fiber.Map{
+		"message":       "transcription restarted",
+		"transcription": transcription,
+	})
+}
+
+// AdminListPayments lists payments across all users
+func AdminListPayments(c *fiber.Ctx) error {
+	page, limit := adminPage(c)
+
+	query := database.DB.Model(&models.Payment{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid user_id",
+			})
+		}
+		query = query.Where("user_id = ?", id)
+	}
+	if mpID := c.Query("mercadopago_payment_id"); mpID != "" {
+		query = query.Where("mercado_pago_payment_id = ?", mpID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var payments []models.Payment
+	if err := query.Omit("payment_details").Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&payments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch payments",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"payments":   payments,
+		"pagination": adminPagination(page, limit, total),
+	})
+}
+
+// AdminGetPayment returns a payment with the stored provider details, its
+// ledger entries and, with ?live=true, what MercadoPago reports right now
+func AdminGetPayment(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid payment ID",
+		})
+	}
+
+	var payment models.Payment
+	if err := database.DB.Preload("Invoice").Where("id = ?", id).First(&payment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "payment not found",
+		})
+	}
+
+	var lots []models.CreditLot
+	database.DB.Where("payment_id = ?", payment.ID).Find(&lots)
+
+	result := fiber.Map{
+		"payment":     payment,
+		"credit_lots": lots,
+	}
+
+	if c.QueryBool("live", false) {
+		provider, err := services.NewMercadoPagoService().FindPaymentsByReference(c.Context(), payment.ID.String())
+		if err != nil {
+			result["provider_error"] = err.Error()
+		} else {
+			result["provider_payments"] = provider
+		}
+	}
+
+	middleware.SetAuditTarget(c, "payment", &payment.ID, map[string]interface{}{
+		"live": c.QueryBool("live", false),
+	})
+
+	return c.JSON(result)
+}
+
+// AdminEraseUser deletes a user's account on their behalf, such as for a
+// request received by email. Calling it again on an erased account retries
+// the Supabase auth user delete.
+func AdminEraseUser(c *fiber.Ctx) error {
+	user, err := adminFindUser(c)
+	if user == nil {
+		return err
+	}
+
+	if user.IsAdmin() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "remove the admin role before deleting the account",
+		})
+	}
+
+	middleware.SetAuditTarget(c, "user", &user.ID, map[string]interface{}{
+		"already_erased": user.IsErased(),
+	})
+
+	return eraseAccount(c, user)
+}
+
+// AdminImpersonateUser starts a read-only session as a user for support. The
+// returned token is used as a Bearer token and is shown once.
+func AdminImpersonateUser(c *fiber.Ctx) error {
+	user, err := adminFindUser(c)
+	if user == nil {
+		return err
+	}
+
+	admin := middleware.GetUser(c)
+	if user.ID == admin.ID || user.IsAdmin() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "admins can't be impersonated",
+		})
+	}
+	if user.IsErased() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "the account was deleted",
+		})
+	}
+
+	type ImpersonateRequest struct {
+		Reason  string `json:"reason"`
+		Minutes int    `json:"minutes"`
+	}
+
+	var req ImpersonateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "reason is required",
+		})
+	}
+	if req.Minutes <= 0 {
+		req.Minutes = defaultImpersonationMinutes
+	}
+	if req.Minutes > maxImpersonationMinutes {
+		req.Minutes = maxImpersonationMinutes
+	}
+
+	token, hash, err := services.GenerateImpersonationToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to start impersonation",
+		})
+	}
+
+	session := models.ImpersonationSession{
+		AdminID:   admin.ID,
+		UserID:    user.ID,
+		TokenHash: hash,
+		Reason:    req.Reason,
+		ExpiresAt: time.Now().Add(time.Duration(req.Minutes) * time.Minute),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to start impersonation",
+		})
+	}
+
+	middleware.SetAuditTarget(c, "user", &user.ID, map[string]interface{}{
+		"session_id": session.ID,
+		"reason":     req.Reason,
+		"expires_at": session.ExpiresAt,
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"session": session,
+		"token":   token,
+	})
+}
+
+// AdminEndImpersonation ends an impersonation session before it expires
+func AdminEndImpersonation(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid session ID",
+		})
+	}
+
+	var session models.ImpersonationSession
+	if err := database.DB.Where("id = ?", id).First(&session).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "impersonation session not found",
+		})
+	}
+
+	if session.EndedAt == nil {
+		now := time.Now()
+		session.EndedAt = &now
+		if err := database.DB.Model(&session).Update("ended_at", now).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to end impersonation",
+			})
+		}
+	}
+
+	middleware.SetAuditTarget(c, "user", &session.UserID, map[string]interface{}{
+		"session_id": session.ID,
+	})
+
+	return c.JSON(session)
+}
+
+// AdminListAuditLog returns admin actions, newest first, filtered by admin,
+// target or action
+func AdminListAuditLog(c *fiber.Ctx) error {
+	page, limit := adminPage(c)
+
+	query := database.DB.Model(&models.AdminAuditLog{})
+	for param, column := range map[string]string{"admin_id": "admin_id", "target_id": "target_id"} {
+		if value := c.Query(param); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "invalid " + param,
+				})
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []models.AdminAuditLog
+	if err := query.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&entries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch audit log",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"entries":    entries,
+		"pagination": adminPagination(page, limit, total),
+	})
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+)
+
+const maxAPIKeysPerUser = 20
+
+// apiKeyResponse exposes scopes as a list; the plaintext key is only set on
+// create and rotate
+type apiKeyResponse struct {
+	models.APIKey
+	Scopes []string `json:"scopes"`
+	Key    string   `json:"key,omitempty"`
+}
+
+func newAPIKeyResponse(key models.APIKey, plaintext string) apiKeyResponse {
+	return apiKeyResponse{APIKey: key, Scopes: key.ScopeList(), Key: plaintext}
+}
+
+// normalizeScopes validates and deduplicates requested scopes
+func normalizeScopes(scopes []string) (string, bool) {
+	seen := map[string]bool{}
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !models.IsValidScope(scope) {
+			return "", false
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return strings.Join(result, ","), len(result) > 0
+}
+
+func findUserAPIKey(c *fiber.Ctx, userID uuid.UUID) (*models.APIKey, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid API key ID",
+		})
+	}
+
+	var key models.APIKey
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&key).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "API key not found",
+		})
+	}
+	return &key, nil
+}
+
+func ListAPIKeys(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var keys []models.APIKey
+	if err := database.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch API keys",
+		})
+	}
+
+	result := make([]apiKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, newAPIKeyResponse(key, ""))
+	}
+
+	return c.JSON(fiber.Map{
+		"api_keys": result,
+		"scopes":   models.ValidScopes,
+	})
+}
+
+// CreateAPIKey issues a new key. The plaintext key is returned only in this response.
+func CreateAPIKey(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	type CreateAPIKeyRequest struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name is required",
+		})
+	}
+
+	scopes, ok := normalizeScopes(req.Scopes)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "scopes must be one or more of: read, upload, billing",
+		})
+	}
+
+	var active int64
+	database.DB.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).Count(&active)
+	if active >= maxAPIKeysPerUser {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "too many active API keys, revoke one first",
+		})
+	}
+
+	plaintext, prefix, hash, err := services.GenerateAPIKey()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to generate API key",
+		})
+	}
+
+	key := models.APIKey{
+		UserID:  user.ID,
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.Create(&key).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create API key",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(newAPIKeyResponse(key, plaintext))
+}
+
+// UpdateAPIKey renames a key or changes its scopes
+func UpdateAPIKey(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	key, err := findUserAPIKey(c, user.ID)
+	if key == nil {
+		return err
+	}
+
+	type UpdateAPIKeyRequest struct {
+		Name   *string  `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+
+	var req UpdateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "name is required",
+			})
+		}
+		key.Name = name
+	}
+	if req.Scopes != nil {
+		scopes, ok := normalizeScopes(req.Scopes)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "scopes must be one or more of: read, upload, billing",
+			})
+		}
+		key.Scopes = scopes
+	}
+
+	if err := database.DB.Save(key).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update API key",
+		})
+	}
+
+	return c.JSON(newAPIKeyResponse(*key, ""))
+}
+
+// RotateAPIKey replaces a key's secret. The old secret stops working
+// immediately; name, scopes and usage history are kept.
+func RotateAPIKey(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	key, err := findUserAPIKey(c, user.ID)
+	if key == nil {
+		return err
+	}
+
+	if key.RevokedAt != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "revoked API keys can't be rotated",
+		})
+	}
+
+	plaintext, prefix, hash, err := services.GenerateAPIKey()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to generate API key",
+		})
+	}
+
+	now := time.Now()
+	key.Prefix = prefix
+	key.KeyHash = hash
+	key.RotatedAt = &now
+
+	if err := database.DB.Save(key).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to rotate API key",
+		})
+	}
+
+	return c.JSON(newAPIKeyResponse(*key, plaintext))
+}
+
+func RevokeAPIKey(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	key, err := findUserAPIKey(c, user.ID)
+	if key == nil {
+		return err
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := database.DB.Save(key).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to revoke API key",
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "API key revoked",
+	})
+}
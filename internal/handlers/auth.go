@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/matills/litwick/internal/database"
 	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
 )
 
 func GetMe(c *fiber.Ctx) error {
@@ -34,6 +38,7 @@ func UpdateSettings(c *fiber.Ctx) error {
 		DetectSpeakers      *bool   `json:"detect_speakers"`
 		EmailNotifications  *bool   `json:"email_notifications"`
 		PromotionalEmails   *bool   `json:"promotional_emails"`
+		Locale              *string `json:"locale"`
 	}
 
 	var req SettingsRequest
@@ -55,6 +60,36 @@ func UpdateSettings(c *fiber.Ctx) error {
 	if req.DetectSpeakers != nil {
 		user.DetectSpeakers = *req.DetectSpeakers
 	}
+	if req.Locale != nil {
+		if !services.IsSupportedLocale(*req.Locale) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "locale must be one of: " + strings.Join(services.SupportedLocales, ", "),
+			})
+		}
+		user.Locale = *req.Locale
+	}
+
+	if err := database.DB.Omit("email_notifications", "promotional_emails").Save(user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update settings",
+		})
+	}
+
+	// Email consent goes through SetMailConsent so every change is audited
+	consents := map[string]*bool{
+		services.MailListNotifications: req.EmailNotifications,
+		services.MailListPromotional:   req.PromotionalEmails,
+	}
+	for list, granted := range consents {
+		if granted == nil {
+			continue
+		}
+		if _, err := services.SetMailConsent(database.DB, user.ID, list, *granted, consentContext(c, models.ConsentSourceSettings)); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to update settings",
+			})
+		}
+	}
 	if req.EmailNotifications != nil {
 		user.EmailNotifications = *req.EmailNotifications
 	}
@@ -62,20 +97,108 @@ func UpdateSettings(c *fiber.Ctx) error {
 		user.PromotionalEmails = *req.PromotionalEmails
 	}
 
-	if err := database.DB.Save(user).Error; err != nil {
+	return c.JSON(fiber.Map{
+		"user": user,
+	})
+}
+
+// GetEmailConsents returns the user's history of email consent changes
+func GetEmailConsents(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	var events []models.EmailConsentEvent
+	if err := database.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(200).Find(&events).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to update settings",
+			"error": "failed to fetch consent history",
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"user": user,
+		"email_notifications": user.EmailNotifications,
+		"promotional_emails":  user.PromotionalEmails,
+		"events":              events,
+	})
+}
+
+func GetBillingProfile(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	profile := models.BillingProfile{UserID: user.ID}
+	if err := database.DB.Where("user_id = ?", user.ID).Limit(1).Find(&profile).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch billing details",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"billing": profile,
+	})
+}
+
+// UpdateBillingProfile stores the details printed on future receipts.
+// Receipts already issued keep the details they were issued with.
+func UpdateBillingProfile(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	type BillingRequest struct {
+		LegalName    string `json:"legal_name"`
+		TaxID        string `json:"tax_id"`
+		AddressLine1 string `json:"address_line1"`
+		AddressLine2 string `json:"address_line2"`
+		City         string `json:"city"`
+		State        string `json:"state"`
+		PostalCode   string `json:"postal_code"`
+		Country      string `json:"country"`
+	}
+
+	var req BillingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	profile := models.BillingProfile{UserID: user.ID}
+	database.DB.Where("user_id = ?", user.ID).Limit(1).Find(&profile)
+
+	profile.LegalName = strings.TrimSpace(req.LegalName)
+	profile.TaxID = strings.TrimSpace(req.TaxID)
+	profile.AddressLine1 = strings.TrimSpace(req.AddressLine1)
+	profile.AddressLine2 = strings.TrimSpace(req.AddressLine2)
+	profile.City = strings.TrimSpace(req.City)
+	profile.State = strings.TrimSpace(req.State)
+	profile.PostalCode = strings.TrimSpace(req.PostalCode)
+	profile.Country = strings.TrimSpace(req.Country)
+
+	if err := database.DB.Save(&profile).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update billing details",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"billing": profile,
 	})
 }
 
 func HealthCheck(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
-		"status": "ok",
+		"status":  "ok",
 		"message": "Litwick API is running",
 	})
 }
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+	"github.com/valyala/fasthttp"
+)
+
+const maxBulkTranscriptions = 100
+
+// bulkSkipped reports a transcription a bulk operation left untouched
+type bulkSkipped struct {
+	ID     uuid.UUID `json:"id"`
+	Reason string    `json:"reason"`
+}
+
+// parseBulkSelection turns a list of IDs and/or a batch ID into a filter.
+// Unknown or foreign IDs simply don't match anything.
+func parseBulkSelection(ids []string, batchID string) ([]uuid.UUID, *uuid.UUID, error) {
+	if len(ids) == 0 && batchID == "" {
+		return nil, nil, fmt.Errorf("ids or batch_id is required")
+	}
+	if len(ids) > maxBulkTranscriptions {
+		return nil, nil, fmt.Errorf("too many transcriptions (max %d)", maxBulkTranscriptions)
+	}
+
+	parsed := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		tid, err := uuid.Parse(strings.TrimSpace(id))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid transcription ID: %s", id)
+		}
+		parsed = append(parsed, tid)
+	}
+
+	if batchID == "" {
+		return parsed, nil, nil
+	}
+	bid, err := uuid.Parse(batchID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid batch ID")
+	}
+	return parsed, &bid, nil
+}
+
+// findBulkTranscriptions loads the account's transcriptions matching a selection
+func findBulkTranscriptions(account services.Account, ids []uuid.UUID, batchID *uuid.UUID) ([]models.Transcription, error) {
+	query := database.DB.Scopes(account.Scope)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	if batchID != nil {
+		query = query.Where("batch_id = ?", *batchID)
+	}
+
+	var transcriptions []models.Transcription
+	err := query.Order("created_at ASC").Limit(maxBulkTranscriptions).Find(&transcriptions).Error
+	return transcriptions, err
+}
+
+type bulkRequest struct {
+	IDs     []string `json:"ids"`
+	BatchID string   `json:"batch_id"`
+}
+
+// BulkDeleteTranscriptions moves the selected transcriptions to the trash.
+// Jobs still processing are skipped.
+func BulkDeleteTranscriptions(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var req bulkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	ids, batchID, err := parseBulkSelection(req.IDs, req.BatchID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	transcriptions, err := findBulkTranscriptions(middleware.GetAccount(c), ids, batchID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch transcriptions",
+		})
+	}
+
+	deleted := []uuid.UUID{}
+	skipped := []bulkSkipped{}
+	for i := range transcriptions {
+		t := &transcriptions[i]
+		if t.Status == models.StatusProcessing {
+			skipped = append(skipped, bulkSkipped{t.ID, "transcription is processing"})
+			continue
+		}
+		if err := trashTranscription(t); err != nil {
+			skipped = append(skipped, bulkSkipped{t.ID, "failed to delete transcription"})
+			continue
+		}
+		deleted = append(deleted, t.ID)
+	}
+
+	return c.JSON(fiber.Map{
+		"deleted": deleted,
+		"skipped": skipped,
+	})
+}
+
+// BulkReprocessTranscriptions restarts the selected jobs that failed or were
+// cancelled, like RetryTranscription
+func BulkReprocessTranscriptions(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var req bulkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	ids, batchID, err := parseBulkSelection(req.IDs, req.BatchID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	transcriptions, err := findBulkTranscriptions(middleware.GetAccount(c), ids, batchID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch transcriptions",
+		})
+	}
+
+	started := []uuid.UUID{}
+	skipped := []bulkSkipped{}
+	for i := range transcriptions {
+		t := &transcriptions[i]
+		if t.Status != models.StatusFailed && t.Status != models.StatusCancelled && t.Status != models.StatusPending {
+			skipped = append(skipped, bulkSkipped{t.ID, fmt.Sprintf("transcription already %s", t.Status)})
+			continue
+		}
+		if !t.HasMedia() {
+			skipped = append(skipped, bulkSkipped{t.ID, errMediaPurged})
+			continue
+		}
+		if err := startTranscription(t, []models.TranscriptionStatus{models.StatusFailed, models.StatusCancelled, models.StatusPending}, nil); err != nil {
+			skipped = append(skipped, bulkSkipped{t.ID, err.Error()})
+			continue
+		}
+		started = append(started, t.ID)
+	}
+
+	return c.JSON(fiber.Map{
+		"started": started,
+		"skipped": skipped,
+	})
+}
+
+// BulkExportTranscriptions streams a ZIP with the selected completed
+// transcripts in one format (txt, srt or vtt)
+func BulkExportTranscriptions(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	format := c.Query("format", "txt")
+	if format != "txt" && format != "srt" && format != "vtt" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be txt, srt or vtt",
+		})
+	}
+
+	var rawIDs []string
+	if ids := c.Query("ids"); ids != "" {
+		rawIDs = strings.Split(ids, ",")
+	}
+
+	ids, batchID, err := parseBulkSelection(rawIDs, c.Query("batch_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	transcriptions, err := findBulkTranscriptions(middleware.GetAccount(c), ids, batchID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch transcriptions",
+		})
+	}
+
+	completed := make([]models.Transcription, 0, len(transcriptions))
+	for _, t := range transcriptions {
+		if t.Status == models.StatusCompleted {
+			completed = append(completed, t)
+		}
+	}
+	if len(completed) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "no completed transcriptions selected",
+		})
+	}
+
+	filename := fmt.Sprintf("transcripciones-%s.zip", time.Now().Format("2006-01-02"))
+	c.Set("Content-Type", "application/zip")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		archive := zip.NewWriter(w)
+		used := map[string]int{}
+
+		for i := range completed {
+			content, _, name := exportTranscript(&completed[i], format)
+			name = uniqueArchiveName(used, path.Base(name))
+
+			entry, err := archive.Create(name)
+			if err != nil {
+				log.Printf("Failed to add %s to export: %v", completed[i].ID, err)
+				return
+			}
+			if _, err := entry.Write([]byte(content)); err != nil {
+				return
+			}
+		}
+
+		if err := archive.Close(); err != nil {
+			log.Printf("Failed to finish export archive: %v", err)
+			return
+		}
+		w.Flush()
+	}))
+
+	return nil
+}
+
+// uniqueArchiveName appends " (2)", " (3)"... to repeated file names
+func uniqueArchiveName(used map[string]int, name string) string {
+	used[name]++
+	if used[name] == 1 {
+		return name
+	}
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), used[name], ext)
+}
+
+// GetTranscriptionBatch returns a batch with its jobs and a count per status
+func GetTranscriptionBatch(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	bid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid batch ID",
+		})
+	}
+
+	var batch models.TranscriptionBatch
+	if err := database.DB.Preload("Transcriptions").Scopes(middleware.GetAccount(c).Scope).Where("id = ?", bid).First(&batch).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "batch not found",
+		})
+	}
+
+	counts := map[models.TranscriptionStatus]int{}
+	for _, t := range batch.Transcriptions {
+		counts[t.Status]++
+	}
+
+	return c.JSON(fiber.Map{
+		"batch":  batch,
+		"status": counts,
+	})
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+)
+
+// audienceMember is one row of the promotional audience export
+type audienceMember struct {
+	ID        uuid.UUID  `json:"user_id"`
+	Email     string     `json:"email"`
+	Locale    string     `json:"locale"`
+	OptedInAt *time.Time `json:"opted_in_at"` // latest recorded opt-in, empty for consent given before auditing
+}
+
+// AdminExportAudience exports the users currently opted in to promotional
+// email, as CSV or with ?format=json
+func AdminExportAudience(c *fiber.Ctx) error {
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "json" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be csv or json",
+		})
+	}
+
+	var members []audienceMember
+	if err := services.PromotionalAudience(database.DB).
+		Select("users.id, users.email, users.locale, MAX(email_consent_events.created_at) AS opted_in_at").
+		Joins("LEFT JOIN email_consent_events ON email_consent_events.user_id = users.id AND email_consent_events.list = ? AND email_consent_events.granted", services.MailListPromotional).
+		Group("users.id").
+		Order("users.created_at").
+		Scan(&members).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch audience",
+		})
+	}
+
+	if format == "json" {
+		middleware.SetAuditTarget(c, "audience", nil, map[string]interface{}{"total": len(members)})
+		return c.JSON(fiber.Map{
+			"audience": members,
+			"total":    len(members),
+		})
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"user_id", "email", "locale", "opted_in_at"})
+	for _, m := range members {
+		optedInAt := ""
+		if m.OptedInAt != nil {
+			optedInAt = m.OptedInAt.Format(time.RFC3339)
+		}
+		w.Write([]string{m.ID.String(), m.Email, m.Locale, optedInAt})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to export audience",
+		})
+	}
+
+	middleware.SetAuditTarget(c, "audience", nil, map[string]interface{}{"total": len(members)})
+
+	c.Set("Content-Type", "text/csv; charset=utf-8")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"audience-%s.csv\"", time.Now().Format("2006-01-02")))
+	return c.Send(buf.Bytes())
+}
+
+func AdminListCampaigns(c *fiber.Ctx) error {
+	var campaigns []models.EmailCampaign
+	if err := database.DB.Omit("body").Order("created_at DESC").Limit(50).Find(&campaigns).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch campaigns",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"campaigns": campaigns,
+	})
+}
+
+func AdminGetCampaign(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid campaign ID",
+		})
+	}
+
+	var campaign models.EmailCampaign
+	if err := database.DB.Where("id = ?", id).First(&campaign).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "campaign not found",
+		})
+	}
+
+	return c.JSON(campaign)
+}
+
+// AdminCreateCampaign sends a promotional email to everyone opted in. With
+// ?dry_run=true it only counts the audience.
+func AdminCreateCampaign(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+
+	type CreateCampaignRequest struct {
+		Subject string `json:"subject"`
+		Body    string `json:"body"`
+		Locale  string `json:"locale"`
+	}
+
+	var req CreateCampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	req.Subject = strings.TrimSpace(req.Subject)
+	if req.Subject == "" || strings.TrimSpace(req.Body) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "subject and body are required",
+		})
+	}
+	if req.Locale != "" && !services.IsSupportedLocale(req.Locale) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "locale must be one of: " + strings.Join(services.SupportedLocales, ", "),
+		})
+	}
+
+	campaign := models.EmailCampaign{
+		Subject:     req.Subject,
+		Body:        req.Body,
+		Locale:      req.Locale,
+		CreatedByID: user.ID,
+		Status:      models.CampaignStatusSending,
+	}
+
+	audience, err := services.CountCampaignAudience(database.DB, &campaign)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to count audience",
+		})
+	}
+
+	if c.QueryBool("dry_run", false) {
+		return c.JSON(fiber.Map{
+			"audience": audience,
+		})
+	}
+
+	if audience == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "no users are opted in to promotional email",
+		})
+	}
+
+	if err := database.DB.Create(&campaign).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create campaign",
+		})
+	}
+
+	// The sender updates its own copy while this response is written
+	sending := campaign
+	go services.SendCampaign(database.DB, &sending)
+
+	middleware.SetAuditTarget(c, "campaign", &campaign.ID, map[string]interface{}{"audience": audience})
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"campaign": campaign,
+		"audience": audience,
+	})
+}
+
+// AdminGetUserConsents returns a user's email consent audit trail
+func AdminGetUserConsents(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user ID",
+		})
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ?", id).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	var events []models.EmailConsentEvent
+	if err := database.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch consent history",
+		})
+	}
+
+	middleware.SetAuditTarget(c, "user", &user.ID, nil)
+
+	return c.JSON(fiber.Map{
+		"user_id":             user.ID,
+		"email_notifications": user.EmailNotifications,
+		"promotional_emails":  user.PromotionalEmails,
+		"events":              events,
+	})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+type creditPackageRequest struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Credits     int        `json:"credits"`
+	ExpireDays  int        `json:"expire_days"`
+	Popular     bool       `json:"popular"`
+	Discount    int        `json:"discount"`
+	SortOrder   int        `json:"sort_order"`
+	Active      bool       `json:"active"`
+	ActiveFrom  *time.Time `json:"active_from"`
+	ActiveUntil *time.Time `json:"active_until"`
+	Prices      []struct {
+		Currency string  `json:"currency"`
+		Amount   float64 `json:"amount"`
+	} `json:"prices"`
+}
+
+func (r *creditPackageRequest) validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name is required")
+	}
+	if r.Credits <= 0 {
+		return errors.New("credits must be positive")
+	}
+	if r.ActiveFrom != nil && r.ActiveUntil != nil && !r.ActiveUntil.After(*r.ActiveFrom) {
+		return errors.New("active_until must be after active_from")
+	}
+	if r.ExpireDays < 0 {
+		return errors.New("expire_days can't be negative")
+	}
+	if len(r.Prices) == 0 {
+		return errors.New("at least one price is required")
+	}
+
+	seen := map[string]bool{}
+	for _, p := range r.Prices {
+		currency := strings.ToUpper(strings.TrimSpace(p.Currency))
+		if len(currency) != 3 {
+			return errors.New("currency must be a 3-letter ISO code")
+		}
+		if seen[currency] {
+			return errors.New("duplicate price for currency " + currency)
+		}
+		seen[currency] = true
+		if p.Amount <= 0 {
+			return errors.New("price amount must be positive")
+		}
+	}
+	return nil
+}
+
+func (r *creditPackageRequest) apply(pkg *models.CreditPackage) {
+	pkg.Name = r.Name
+	pkg.Description = r.Description
+	pkg.Credits = r.Credits
+	pkg.ExpireDays = r.ExpireDays
+	pkg.Popular = r.Popular
+	pkg.Discount = r.Discount
+	pkg.SortOrder = r.SortOrder
+	pkg.Active = r.Active
+	pkg.ActiveFrom = r.ActiveFrom
+	pkg.ActiveUntil = r.ActiveUntil
+
+	pkg.Prices = make([]models.CreditPackagePrice, 0, len(r.Prices))
+	for _, p := range r.Prices {
+		pkg.Prices = append(pkg.Prices, models.CreditPackagePrice{
+			PackageID: pkg.ID,
+			Currency:  strings.ToUpper(strings.TrimSpace(p.Currency)),
+			Amount:    p.Amount,
+		})
+	}
+}
+
+// AdminListCreditPackages returns every package, including inactive ones
+func AdminListCreditPackages(c *fiber.Ctx) error {
+	var packages []models.CreditPackage
+	if err := database.DB.Preload("Prices").Order("sort_order ASC").Find(&packages).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch packages",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"packages": packages,
+	})
+}
+
+func AdminCreateCreditPackage(c *fiber.Ctx) error {
+	var req creditPackageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	req.ID = strings.ToLower(strings.TrimSpace(req.ID))
+	if req.ID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "id is required",
+		})
+	}
+	if err := req.validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var existing int64
+	database.DB.Model(&models.CreditPackage{}).Where("id = ?", req.ID).Count(&existing)
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "package ID already exists",
+		})
+	}
+
+	pkg := models.CreditPackage{ID: req.ID}
+	req.apply(&pkg)
+
+	if err := database.DB.Create(&pkg).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create package",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(pkg)
+}
+
+func AdminUpdateCreditPackage(c *fiber.Ctx) error {
+	var pkg models.CreditPackage
+	if err := database.DB.Where("id = ?", c.Params("id")).First(&pkg).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "package not found",
+		})
+	}
+
+	var req creditPackageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if err := req.validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	req.apply(&pkg)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("package_id = ?", pkg.ID).Delete(&models.CreditPackagePrice{}).Error; err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&pkg).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update package",
+		})
+	}
+
+	return c.JSON(pkg)
+}
+
+// AdminDeleteCreditPackage removes a package from the catalog. Past payments
+// keep their own copy of the package name, price and credits.
+func AdminDeleteCreditPackage(c *fiber.Ctx) error {
+	result := database.DB.Where("id = ?", c.Params("id")).Delete(&models.CreditPackage{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete package",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "package not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "package deleted successfully",
+	})
+}
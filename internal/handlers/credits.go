@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+	"gorm.io/gorm"
+)
+
+// accountCredits returns the balance of the account the request charges to
+func accountCredits(c *fiber.Ctx) int {
+	if org := middleware.GetOrganization(c); org != nil {
+		return org.CreditsRemaining
+	}
+	return middleware.GetUser(c).CreditsRemaining
+}
+
+// accountHasCredits checks the balance of the account the request charges to
+func accountHasCredits(c *fiber.Ctx, minutes int) bool {
+	if org := middleware.GetOrganization(c); org != nil {
+		return org.HasCredits(minutes)
+	}
+	return middleware.GetUser(c).HasCredits(minutes)
+}
+
+// GetCreditLots returns the account's credit lots, usable ones first in the
+// order they will be consumed
+func GetCreditLots(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	query := database.DB.Scopes(middleware.GetAccount(c).Scope)
+	if !c.QueryBool("include_spent", false) {
+		query = query.Where("remaining > 0 AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+	}
+
+	var lots []models.CreditLot
+	if err := query.Order("expires_at ASC NULLS LAST, purchased_at ASC").Find(&lots).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch credit lots",
+		})
+	}
+
+	// Minutes that will expire in the next 30 days
+	soon := time.Now().AddDate(0, 0, 30)
+	expiringSoon := 0
+	for _, lot := range lots {
+		if lot.IsUsable(time.Now()) && lot.ExpiresAt != nil && lot.ExpiresAt.Before(soon) {
+			expiringSoon += lot.Remaining
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"lots":              lots,
+		"credits_remaining": accountCredits(c),
+		"expiring_soon":     expiringSoon,
+	})
+}
+
+// parseDateFilter accepts RFC3339 timestamps or plain YYYY-MM-DD dates
+func parseDateFilter(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// GetCreditTransactions returns the account's credit ledger, newest first, with
+// the lot each entry was drawn from or added to
+func GetCreditTransactions(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.CreditTransaction{}).Scopes(middleware.GetAccount(c).Scope)
+
+	if txType := c.Query("type"); txType != "" {
+		query = query.Where("type = ?", txType)
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseDateFilter(from)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid from date",
+			})
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseDateFilter(to)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid to date",
+			})
+		}
+		query = query.Where("created_at < ?", t)
+	}
+	if id := c.Query("transcription_id"); id != "" {
+		tid, err := uuid.Parse(id)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid transcription ID",
+			})
+		}
+		query = query.Where("transcription_id = ?", tid)
+	}
+	if id := c.Query("lot_id"); id != "" {
+		lid, err := uuid.Parse(id)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid lot ID",
+			})
+		}
+		query = query.Where("credit_lot_id = ?", lid)
+	}
+
+	var total int64
+	query.Session(&gorm.Session{}).Count(&total)
+
+	var transactions []models.CreditTransaction
+	if err := query.Preload("CreditLot").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&transactions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch transactions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"transactions": transactions,
+		"pagination": fiber.Map{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetUsageStatements lists the months with credit activity
+func GetUsageStatements(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var months []string
+	if err := database.DB.Model(&models.CreditTransaction{}).
+		Select("DISTINCT to_char(created_at, 'YYYY-MM') AS month").
+		Scopes(middleware.GetAccount(c).Scope).
+		Order("month DESC").
+		Pluck("month", &months).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch statements",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"months": months,
+	})
+}
+
+// GetUsageStatement returns the statement for a month as JSON, or as a file
+// download with ?format=csv|pdf
+func GetUsageStatement(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	start, err := services.ParseStatementMonth(c.Params("month"), time.Local)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	statement, err := services.BuildUsageStatement(database.DB, middleware.GetAccount(c), start)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to build statement",
+		})
+	}
+
+	filename := fmt.Sprintf("litwick-statement-%s", statement.Month)
+
+	switch c.Query("format", "json") {
+	case "csv":
+		content, err := statement.CSV()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to render statement",
+			})
+		}
+		c.Set("Content-Type", "text/csv")
+		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", filename))
+		return c.Send(content)
+	case "pdf":
+		c.Set("Content-Type", "application/pdf")
+		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.pdf\"", filename))
+		account := user.Email
+		if org := middleware.GetOrganization(c); org != nil {
+			account = org.Name
+		}
+		return c.Send(statement.PDF(account))
+	default:
+		return c.JSON(statement)
+	}
+}
@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/matills/litwick/internal/database"
 	"github.com/matills/litwick/internal/middleware"
 	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
 )
 
-// GetDashboard returns user's transcriptions and stats
+// GetDashboard returns the account's transcriptions and stats
 func GetDashboard(c *fiber.Ctx) error {
 	user := middleware.GetUser(c)
 	if user == nil {
@@ -16,9 +18,9 @@ func GetDashboard(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get all transcriptions for user
+	// Get all transcriptions for the account
 	var transcriptions []models.Transcription
-	if err := database.DB.Where("user_id = ?", user.ID).
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).
 		Order("created_at DESC").
 		Find(&transcriptions).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -27,10 +29,11 @@ func GetDashboard(c *fiber.Ctx) error {
 	}
 
 	// Calculate stats
-	stats := calculateStats(transcriptions, *user)
+	stats := calculateStats(transcriptions, accountCredits(c))
 
 	return c.JSON(fiber.Map{
 		"user":           user,
+		"organization":   middleware.GetOrganization(c),
 		"transcriptions": transcriptions,
 		"stats":          stats,
 	})
@@ -54,9 +57,28 @@ func GetTranscriptions(c *fiber.Ctx) error {
 	var transcriptions []models.Transcription
 	var total int64
 
-	database.DB.Model(&models.Transcription{}).Where("user_id = ?", user.ID).Count(&total)
+	query := database.DB.Model(&models.Transcription{}).Scopes(middleware.GetAccount(c).Scope)
+	if batchID := c.Query("batch_id"); batchID != "" {
+		bid, err := uuid.Parse(batchID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid batch ID",
+			})
+		}
+		query = query.Where("batch_id = ?", bid)
+	}
+
+	query, err := filterTranscriptions(c, query)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	query.Count(&total)
 
-	if err := database.DB.Where("user_id = ?", user.ID).
+	if err := query.
+		Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("tag") }).
 		Order("created_at DESC").
 		Limit(limit).
 		Offset(offset).
@@ -86,10 +108,10 @@ type DashboardStats struct {
 	CreditsRemaining    int `json:"credits_remaining"`
 }
 
-func calculateStats(transcriptions []models.Transcription, user models.User) DashboardStats {
+func calculateStats(transcriptions []models.Transcription, creditsRemaining int) DashboardStats {
 	stats := DashboardStats{
 		TotalTranscriptions: len(transcriptions),
-		CreditsRemaining:    user.CreditsRemaining,
+		CreditsRemaining:    creditsRemaining,
 	}
 
 	for _, t := range transcriptions {
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/services"
+	"github.com/valyala/fasthttp"
+)
+
+const sseHeartbeatInterval = 25 * time.Second
+
+// StreamTranscriptionEvents streams the account's job status changes and
+// credit balance updates as server-sent events until the client disconnects.
+// Events missed while disconnected are not replayed; clients refetch state
+// after reconnecting.
+func StreamTranscriptionEvents(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	events, unsubscribe := services.Events.Subscribe(middleware.GetAccount(c).OwnerID())
+	creditsRemaining := accountCredits(c)
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		heartbeat := time.NewTicker(sseHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		fmt.Fprintf(w, "retry: 5000\n\n")
+		if err := writeSSEEvent(w, services.LiveEvent{
+			Type:      services.LiveCreditsUpdated,
+			Data:      fiber.Map{"credits_remaining": creditsRemaining},
+			CreatedAt: time.Now(),
+		}); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := writeSSEEvent(w, event); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				// A comment line keeps proxies from closing the idle connection
+				// and tells us when the client has gone away
+				fmt.Fprintf(w, ": ping\n\n")
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	}))
+
+	return nil
+}
+
+func writeSSEEvent(w *bufio.Writer, event services.LiveEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return w.Flush()
+}
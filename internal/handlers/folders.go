@@ -0,0 +1,650 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxFoldersPerAccount    = 200
+	maxTagsPerTranscription = 20
+	maxTagLength            = 50
+	maxFolderNameLength     = 100
+)
+
+// normalizeTags lowercases, trims and deduplicates tags, rejecting empty or
+// overlong ones
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len([]rune(tag)) > maxTagLength || strings.Contains(tag, ",") {
+			return nil, fmt.Errorf("tags must be 1 to %d characters without commas", maxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// addTags tags transcriptions, skipping tags they already have, without going
+// over maxTagsPerTranscription
+func addTags(tx *gorm.DB, ids []uuid.UUID, tags []string) error {
+	if len(ids) == 0 || len(tags) == 0 {
+		return nil
+	}
+
+	rows := make([]models.TranscriptionTag, 0, len(ids)*len(tags))
+	for _, id := range ids {
+		for _, tag := range tags {
+			rows = append(rows, models.TranscriptionTag{TranscriptionID: id, Tag: tag})
+		}
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return err
+	}
+
+	var over int64
+	if err := tx.Model(&models.TranscriptionTag{}).
+		Where("transcription_id IN ?", ids).
+		Group("transcription_id").
+		Having("COUNT(*) > ?", maxTagsPerTranscription).
+		Count(&over).Error; err != nil {
+		return err
+	}
+	if over > 0 {
+		return errTooManyTags
+	}
+	return nil
+}
+
+var errTooManyTags = fmt.Errorf("a transcription can have at most %d tags", maxTagsPerTranscription)
+
+// findAccountFolder loads a folder of the request's account
+func findAccountFolder(c *fiber.Ctx, id uuid.UUID) (*models.Folder, error) {
+	var folder models.Folder
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", id).First(&folder).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "folder not found",
+		})
+	}
+	return &folder, nil
+}
+
+// parseFolderName validates a folder name and checks it isn't taken in the
+// account by another folder
+func parseFolderName(c *fiber.Ctx, name string, except uuid.UUID) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > maxFolderNameLength {
+		return "", c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("name must be 1 to %d characters", maxFolderNameLength),
+		})
+	}
+
+	var taken int64
+	database.DB.Model(&models.Folder{}).Scopes(middleware.GetAccount(c).Scope).
+		Where("LOWER(name) = LOWER(?) AND id <> ?", name, except).
+		Count(&taken)
+	if taken > 0 {
+		return "", c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "a folder with that name already exists",
+		})
+	}
+	return name, nil
+}
+
+// folderResponse is a folder with how many transcriptions it holds
+type folderResponse struct {
+	models.Folder
+	TranscriptionCount int64 `json:"transcription_count"`
+}
+
+// ListFolders lists the account's folders by name, with their transcription
+// counts and how many transcriptions aren't in any folder
+func ListFolders(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	account := middleware.GetAccount(c)
+
+	var folders []models.Folder
+	if err := database.DB.Scopes(account.Scope).Order("LOWER(name)").Find(&folders).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch folders",
+		})
+	}
+
+	var counts []struct {
+		FolderID *uuid.UUID
+		Count    int64
+	}
+	if err := database.DB.Model(&models.Transcription{}).Scopes(account.Scope).
+		Select("folder_id, COUNT(*) AS count").
+		Group("folder_id").
+		Scan(&counts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to count transcriptions",
+		})
+	}
+
+	byFolder := map[uuid.UUID]int64{}
+	var unfiled int64
+	for _, count := range counts {
+		if count.FolderID == nil {
+			unfiled = count.Count
+			continue
+		}
+		byFolder[*count.FolderID] = count.Count
+	}
+
+	result := make([]folderResponse, len(folders))
+	for i, folder := range folders {
+		result[i] = folderResponse{folder, byFolder[folder.ID]}
+	}
+
+	return c.JSON(fiber.Map{
+		"folders": result,
+		"unfiled": unfiled,
+	})
+}
+
+// CreateFolder adds a folder to the account
+func CreateFolder(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	type CreateFolderRequest struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	var req CreateFolderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	name, err := parseFolderName(c, req.Name, uuid.Nil)
+	if name == "" {
+		return err
+	}
+
+	account := middleware.GetAccount(c)
+
+	var count int64
+	database.DB.Model(&models.Folder{}).Scopes(account.Scope).Count(&count)
+	if count >= maxFoldersPerAccount {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "too many folders, delete one first",
+		})
+	}
+
+	folder := models.Folder{
+		UserID:         user.ID,
+		OrganizationID: account.OrganizationID,
+		Name:           name,
+		Description:    strings.TrimSpace(req.Description),
+	}
+	if err := database.DB.Create(&folder).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create folder",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(folder)
+}
+
+// UpdateFolder renames a folder or changes its description
+func UpdateFolder(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid folder ID",
+		})
+	}
+
+	folder, err := findAccountFolder(c, id)
+	if folder == nil {
+		return err
+	}
+
+	type UpdateFolderRequest struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+
+	var req UpdateFolderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Name != nil {
+		name, err := parseFolderName(c, *req.Name, folder.ID)
+		if name == "" {
+			return err
+		}
+		folder.Name = name
+	}
+	if req.Description != nil {
+		folder.Description = strings.TrimSpace(*req.Description)
+	}
+
+	if err := database.DB.Model(folder).Select("name", "description").Updates(folder).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update folder",
+		})
+	}
+
+	return c.JSON(folder)
+}
+
+// DeleteFolder deletes a folder. Its transcriptions stay, outside any folder.
+func DeleteFolder(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid folder ID",
+		})
+	}
+
+	folder, err := findAccountFolder(c, id)
+	if folder == nil {
+		return err
+	}
+
+	// The foreign key unfiles the transcriptions, trashed ones included
+	if err := database.DB.Delete(folder).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete folder",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "folder deleted",
+	})
+}
+
+// parseFolderTarget reads the folder to move transcriptions to: a folder of
+// the account, or nil to take them out of their folder
+func parseFolderTarget(c *fiber.Ctx, folderID *string) (*uuid.UUID, bool, error) {
+	if folderID == nil || *folderID == "" {
+		return nil, true, nil
+	}
+	id, err := uuid.Parse(*folderID)
+	if err != nil {
+		return nil, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid folder ID",
+		})
+	}
+	folder, err := findAccountFolder(c, id)
+	if folder == nil {
+		return nil, false, err
+	}
+	return &folder.ID, true, nil
+}
+
+// MoveTranscription puts a transcription in a folder, or takes it out with
+// a null folder_id
+func MoveTranscription(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	tid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid transcription ID",
+		})
+	}
+
+	type MoveRequest struct {
+		FolderID *string `json:"folder_id"`
+	}
+
+	var req MoveRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	folderID, ok, err := parseFolderTarget(c, req.FolderID)
+	if !ok {
+		return err
+	}
+
+	var transcription models.Transcription
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", tid).First(&transcription).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcription not found",
+		})
+	}
+
+	if err := database.DB.Model(&transcription).Update("folder_id", folderID).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to move transcription",
+		})
+	}
+	transcription.FolderID = folderID
+
+	return c.JSON(transcription)
+}
+
+// SetTranscriptionTags replaces a transcription's tags
+func SetTranscriptionTags(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	tid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid transcription ID",
+		})
+	}
+
+	type TagsRequest struct {
+		Tags []string `json:"tags"`
+	}
+
+	var req TagsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if len(tags) > maxTagsPerTranscription {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": errTooManyTags.Error(),
+		})
+	}
+
+	var transcription models.Transcription
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", tid).First(&transcription).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcription not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("transcription_id = ?", transcription.ID).Delete(&models.TranscriptionTag{}).Error; err != nil {
+			return err
+		}
+		return addTags(tx, []uuid.UUID{transcription.ID}, tags)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update tags",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"id":   transcription.ID,
+		"tags": tags,
+	})
+}
+
+// ListTags lists the tags used in the account with how many transcriptions
+// carry each
+func ListTags(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var tags []struct {
+		Tag   string `json:"tag"`
+		Count int64  `json:"count"`
+	}
+	if err := database.DB.Model(&models.TranscriptionTag{}).
+		Select("transcription_tags.tag, COUNT(*) AS count").
+		Where("transcription_id IN (?)", database.DB.Model(&models.Transcription{}).Scopes(middleware.GetAccount(c).Scope).Select("id")).
+		Group("transcription_tags.tag").
+		Order("transcription_tags.tag").
+		Scan(&tags).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch tags",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"tags": tags,
+	})
+}
+
+// BulkMoveTranscriptions moves the selected transcriptions to a folder, or
+// out of their folder with a null folder_id
+func BulkMoveTranscriptions(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	type BulkMoveRequest struct {
+		bulkRequest
+		FolderID *string `json:"folder_id"`
+	}
+
+	var req BulkMoveRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	ids, batchID, err := parseBulkSelection(req.IDs, req.BatchID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	folderID, ok, err := parseFolderTarget(c, req.FolderID)
+	if !ok {
+		return err
+	}
+
+	transcriptions, err := findBulkTranscriptions(middleware.GetAccount(c), ids, batchID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch transcriptions",
+		})
+	}
+
+	moved := transcriptionIDs(transcriptions)
+	if len(moved) > 0 {
+		if err := database.DB.Model(&models.Transcription{}).Where("id IN ?", moved).Update("folder_id", folderID).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to move transcriptions",
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"moved":     moved,
+		"folder_id": folderID,
+	})
+}
+
+// BulkTagTranscriptions adds and removes tags on the selected transcriptions
+func BulkTagTranscriptions(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	type BulkTagRequest struct {
+		bulkRequest
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+	}
+
+	var req BulkTagRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	ids, batchID, err := parseBulkSelection(req.IDs, req.BatchID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	add, err := normalizeTags(req.Add)
+	if err == nil {
+		req.Remove, err = normalizeTags(req.Remove)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if len(add) == 0 && len(req.Remove) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "add or remove is required",
+		})
+	}
+
+	transcriptions, err := findBulkTranscriptions(middleware.GetAccount(c), ids, batchID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch transcriptions",
+		})
+	}
+
+	tagged := transcriptionIDs(transcriptions)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(tagged) == 0 {
+			return nil
+		}
+		if len(req.Remove) > 0 {
+			if err := tx.Where("transcription_id IN ? AND tag IN ?", tagged, req.Remove).Delete(&models.TranscriptionTag{}).Error; err != nil {
+				return err
+			}
+		}
+		return addTags(tx, tagged, add)
+	})
+	if err == errTooManyTags {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update tags",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"tagged":  tagged,
+		"added":   add,
+		"removed": req.Remove,
+	})
+}
+
+func transcriptionIDs(transcriptions []models.Transcription) []uuid.UUID {
+	ids := make([]uuid.UUID, len(transcriptions))
+	for i, t := range transcriptions {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// likeEscaper escapes LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// filterTranscriptions applies the transcription list filters: folder_id
+// ("none" for unfiled), tag (comma separated, all must match), status and q,
+// which searches file names and transcript text
+func filterTranscriptions(c *fiber.Ctx, query *gorm.DB) (*gorm.DB, error) {
+	if folder := c.Query("folder_id"); folder != "" {
+		if folder == "none" {
+			query = query.Where("folder_id IS NULL")
+		} else {
+			fid, err := uuid.Parse(folder)
+			if err != nil {
+				return nil, fmt.Errorf("invalid folder ID")
+			}
+			query = query.Where("folder_id = ?", fid)
+		}
+	}
+
+	if tagParam := c.Query("tag"); tagParam != "" {
+		tags, err := normalizeTags(strings.Split(tagParam, ","))
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			query = query.Where("EXISTS (SELECT 1 FROM transcription_tags WHERE transcription_tags.transcription_id = transcriptions.id AND transcription_tags.tag = ?)", tag)
+		}
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + likeEscaper.Replace(q) + "%"
+		query = query.Where("(file_name ILIKE ? OR transcript_text ILIKE ?)", pattern, pattern)
+	}
+
+	return query, nil
+}
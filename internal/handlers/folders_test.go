@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		want    []string
+		wantErr bool
+	}{
+		{"none", nil, []string{}, false},
+		{"lowercased and trimmed", []string{" Entrevistas ", "PODCAST"}, []string{"entrevistas", "podcast"}, false},
+		{"duplicates keep the first", []string{"a", "b", "A"}, []string{"a", "b"}, false},
+		{"longest allowed", []string{strings.Repeat("ñ", maxTagLength)}, []string{strings.Repeat("ñ", maxTagLength)}, false},
+		{"too long", []string{strings.Repeat("a", maxTagLength+1)}, nil, true},
+		{"empty", []string{"ok", "  "}, nil, true},
+		{"comma", []string{"a,b"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeTags(tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeTags(%q) = %q, want %q", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+)
+
+// setMediaURLs points a transcription's analysis at the endpoints serving
+// its waveform and poster
+func setMediaURLs(t *models.Transcription) {
+	if t.Media == nil {
+		return
+	}
+	if t.Media.WaveformKey != "" {
+		t.Media.WaveformURL = fmt.Sprintf("/api/transcriptions/%s/waveform", t.ID)
+	}
+	if t.Media.PosterKey != "" {
+		t.Media.PosterURL = fmt.Sprintf("/api/transcriptions/%s/poster", t.ID)
+	}
+}
+
+// sendStoredObject streams a stored object back, forwarding the Range header
+// so media players can seek
+func sendStoredObject(c *fiber.Ctx, storage *services.StorageService, key string) error {
+	resp, err := storage.OpenFile(c.Context(), key, c.Get("Range"))
+	if err != nil {
+		log.Printf("Failed to open %s: %v", key, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "failed to load media",
+		})
+	}
+
+	for _, header := range []string{"Content-Type", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag"} {
+		if value := resp.Header.Get(header); value != "" {
+			c.Set(header, value)
+		}
+	}
+
+	size := -1
+	if length, err := strconv.Atoi(resp.Header.Get("Content-Length")); err == nil {
+		size = length
+	}
+
+	// The body is closed by fasthttp once it has been sent
+	return c.Status(resp.StatusCode).SendStream(resp.Body, size)
+}
+
+// findMediaAnalysis loads the analysis of one of the account's transcriptions
+func findMediaAnalysis(c *fiber.Ctx) (*models.MediaAnalysis, error) {
+	tid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid transcription ID",
+		})
+	}
+
+	var transcription models.Transcription
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Preload("Media").
+		Omit("transcript_text", "transcript_json", "srt_content", "vtt_content").
+		Where("id = ?", tid).First(&transcription).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcription not found",
+		})
+	}
+	if transcription.Media == nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "media not analyzed yet",
+		})
+	}
+	return transcription.Media, nil
+}
+
+// GetTranscriptionWaveform serves the waveform peaks of a transcription's
+// media, in audiowaveform's JSON format
+func GetTranscriptionWaveform(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	analysis, err := findMediaAnalysis(c)
+	if analysis == nil {
+		return err
+	}
+	if analysis.WaveformKey == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "waveform not available",
+		})
+	}
+
+	storage, err := services.NewStorageService(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to initialize storage",
+		})
+	}
+	return sendStoredObject(c, storage, analysis.WaveformKey)
+}
+
+// GetTranscriptionPoster serves the poster frame of a transcription's video
+func GetTranscriptionPoster(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	analysis, err := findMediaAnalysis(c)
+	if analysis == nil {
+		return err
+	}
+	if analysis.PosterKey == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "poster not available",
+		})
+	}
+
+	storage, err := services.NewStorageService(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to initialize storage",
+		})
+	}
+	return sendStoredObject(c, storage, analysis.PosterKey)
+}
+
+// AnalyzeTranscription runs the media analysis again, such as after it
+// failed on every attempt
+func AnalyzeTranscription(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	tid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid transcription ID",
+		})
+	}
+
+	var transcription models.Transcription
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Preload("Media").
+		Omit("transcript_text", "transcript_json", "srt_content", "vtt_content").
+		Where("id = ?", tid).First(&transcription).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcription not found",
+		})
+	}
+
+	if !services.MediaToolsAvailable() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "media analysis is not available",
+		})
+	}
+	if !transcription.HasMedia() {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error": errMediaPurged,
+		})
+	}
+
+	storage, err := services.NewStorageService(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to initialize storage",
+		})
+	}
+	if !storage.IsStoredFile(transcription.FileURL) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "only media stored with us can be analyzed",
+		})
+	}
+	if transcription.Media != nil && transcription.Media.Status == models.MediaAnalysisProcessing {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "media analysis already running",
+		})
+	}
+
+	if err := services.ResetMediaAnalysis(database.DB, transcription.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to restart media analysis",
+		})
+	}
+	services.AnalyzeMediaInBackground(database.DB, transcription.ID)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "media analysis started",
+	})
+}
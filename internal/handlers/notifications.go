@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+	"gorm.io/gorm"
+)
+
+// consentContext describes the request behind a consent change
+func consentContext(c *fiber.Ctx, source string) services.ConsentContext {
+	return services.ConsentContext{
+		Source:    source,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}
+}
+
+// Unsubscribe opts a user out of a mailing list with the signed token from
+// an email. GET is the link in the footer; POST is the one-click
+// List-Unsubscribe request mail clients send. Both are recorded in the
+// consent audit trail.
+func Unsubscribe(c *fiber.Ctx) error {
+	userID, list, err := services.ParseUnsubscribeToken(c.Query("token"))
+	if err != nil || !services.IsMailList(list) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid unsubscribe link",
+		})
+	}
+
+	source := models.ConsentSourceUnsubscribeLink
+	if c.Method() == fiber.MethodPost {
+		source = models.ConsentSourceOneClick
+	}
+
+	if _, err := services.SetMailConsent(database.DB, userID, list, false, consentContext(c, source)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid unsubscribe link",
+			})
+		}
+		log.Printf("Failed to unsubscribe %s from %s: %v", userID, list, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to unsubscribe",
+		})
+	}
+
+	if c.Method() == fiber.MethodPost {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.SendString(`<!doctype html><html><head><meta charset="utf-8"><title>Litwick</title></head>` +
+		`<body><p>Listo, no vas a recibir más estos emails. Podés volver a activarlos desde la configuración de tu cuenta.</p>` +
+		`<p>Done, you won't receive these emails anymore. You can turn them back on from your account settings.</p></body></html>`)
+}
@@ -0,0 +1,521 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxOrganizationMembers = 100
+
+var (
+	errLastOwner          = errors.New("an organization needs at least one owner")
+	errOrganizationInUse  = errors.New("organization still has transcriptions or credits")
+	errAlreadyMember      = errors.New("user is already a member")
+	errTooManyMembers     = errors.New("too many members")
+	errOwnerRoleForbidden = errors.New("only owners can grant, change or remove the owner role")
+)
+
+// organizationResponse is an organization with the requesting user's role
+type organizationResponse struct {
+	models.Organization
+	Role string `json:"role"`
+}
+
+// findOrganizationMembership loads the user's membership in the organization
+// in the :id param, with the organization, and checks the minimum role
+func findOrganizationMembership(c *fiber.Ctx, userID uuid.UUID, role string) (*models.OrganizationMember, error) {
+	orgID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid organization ID",
+		})
+	}
+
+	var member models.OrganizationMember
+	if err := database.DB.Preload("Organization").Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "organization not found",
+		})
+	}
+
+	if !member.HasRole(role) {
+		return nil, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "this action requires the " + role + " role in the organization",
+		})
+	}
+	return &member, nil
+}
+
+// lockOrganization serializes membership changes so the last owner can't be
+// removed by two concurrent requests
+func lockOrganization(tx *gorm.DB, orgID uuid.UUID) error {
+	var org models.Organization
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orgID).First(&org).Error
+}
+
+// countOwners counts the organization's owners, optionally leaving one member out
+func countOwners(tx *gorm.DB, orgID uuid.UUID, except uuid.UUID) (int64, error) {
+	var owners int64
+	err := tx.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", orgID, models.OrgRoleOwner, except).
+		Count(&owners).Error
+	return owners, err
+}
+
+// ListOrganizations returns the organizations the user belongs to
+func ListOrganizations(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var memberships []models.OrganizationMember
+	if err := database.DB.Preload("Organization").Where("user_id = ?", user.ID).Order("created_at ASC").Find(&memberships).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch organizations",
+		})
+	}
+
+	organizations := make([]organizationResponse, 0, len(memberships))
+	for _, m := range memberships {
+		organizations = append(organizations, organizationResponse{Organization: m.Organization, Role: m.Role})
+	}
+
+	return c.JSON(fiber.Map{
+		"organizations": organizations,
+	})
+}
+
+// CreateOrganization creates an organization with the user as its owner. The
+// credit pool starts empty; purchases made in the organization fill it.
+func CreateOrganization(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	type CreateOrganizationRequest struct {
+		Name string `json:"name"`
+	}
+
+	var req CreateOrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name is required",
+		})
+	}
+
+	org := models.Organization{
+		Name:        name,
+		CreatedByID: user.ID,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         user.ID,
+			Role:           models.OrgRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create organization",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(organizationResponse{Organization: org, Role: models.OrgRoleOwner})
+}
+
+// GetOrganization returns an organization with its members
+func GetOrganization(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	member, err := findOrganizationMembership(c, user.ID, models.OrgRoleViewer)
+	if member == nil {
+		return err
+	}
+
+	org := member.Organization
+	if err := database.DB.Preload("User").Where("organization_id = ?", org.ID).Order("created_at ASC").Find(&org.Members).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch members",
+		})
+	}
+
+	return c.JSON(organizationResponse{Organization: org, Role: member.Role})
+}
+
+// UpdateOrganization renames an organization
+func UpdateOrganization(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	member, err := findOrganizationMembership(c, user.ID, models.OrgRoleAdmin)
+	if member == nil {
+		return err
+	}
+
+	type UpdateOrganizationRequest struct {
+		Name string `json:"name"`
+	}
+
+	var req UpdateOrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name is required",
+		})
+	}
+
+	org := member.Organization
+	if err := database.DB.Model(&org).Update("name", name).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update organization",
+		})
+	}
+
+	return c.JSON(organizationResponse{Organization: org, Role: member.Role})
+}
+
+// DeleteOrganization deletes an empty organization. Transcriptions and
+// unspent credits must be removed or used first; payments and the credit
+// ledger are kept for the records.
+func DeleteOrganization(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	member, err := findOrganizationMembership(c, user.ID, models.OrgRoleOwner)
+	if member == nil {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var org models.Organization
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", member.OrganizationID).First(&org).Error; err != nil {
+			return err
+		}
+
+		var transcriptions int64
+		if err := tx.Model(&models.Transcription{}).Where("organization_id = ?", org.ID).Count(&transcriptions).Error; err != nil {
+			return err
+		}
+		if transcriptions > 0 || org.CreditsRemaining > 0 {
+			return errOrganizationInUse
+		}
+
+		if err := tx.Where("organization_id = ?", org.ID).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&org).Error
+	})
+	if errors.Is(err, errOrganizationInUse) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete organization",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "organization deleted successfully",
+	})
+}
+
+// AddOrganizationMember adds an existing user, by email, with a role. Only
+// owners can add other owners.
+func AddOrganizationMember(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	member, err := findOrganizationMembership(c, user.ID, models.OrgRoleAdmin)
+	if member == nil {
+		return err
+	}
+
+	type AddMemberRequest struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+
+	var req AddMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	role := strings.ToLower(strings.TrimSpace(req.Role))
+	if role == "" {
+		role = models.OrgRoleViewer
+	}
+	if !models.IsValidOrgRole(role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "role must be one of: owner, admin, editor, viewer",
+		})
+	}
+	if role == models.OrgRoleOwner && !member.HasRole(models.OrgRoleOwner) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": errOwnerRoleForbidden.Error(),
+		})
+	}
+
+	// Members must have signed in once so their account exists
+	var invitee models.User
+	if err := database.DB.Where("email = ?", strings.TrimSpace(req.Email)).First(&invitee).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "no user with that email",
+		})
+	}
+
+	added := models.OrganizationMember{
+		OrganizationID: member.OrganizationID,
+		UserID:         invitee.ID,
+		Role:           role,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, member.OrganizationID); err != nil {
+			return err
+		}
+
+		var existing int64
+		tx.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", member.OrganizationID, invitee.ID).Count(&existing)
+		if existing > 0 {
+			return errAlreadyMember
+		}
+
+		var members int64
+		tx.Model(&models.OrganizationMember{}).Where("organization_id = ?", member.OrganizationID).Count(&members)
+		if members >= maxOrganizationMembers {
+			return errTooManyMembers
+		}
+
+		return tx.Create(&added).Error
+	})
+	if errors.Is(err, errAlreadyMember) || errors.Is(err, errTooManyMembers) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to add member",
+		})
+	}
+
+	added.User = invitee
+	return c.Status(fiber.StatusCreated).JSON(added)
+}
+
+// UpdateOrganizationMember changes a member's role. Only owners can change
+// the owner role, and the last owner can't be demoted.
+func UpdateOrganizationMember(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	member, err := findOrganizationMembership(c, user.ID, models.OrgRoleAdmin)
+	if member == nil {
+		return err
+	}
+
+	targetID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user ID",
+		})
+	}
+
+	type UpdateMemberRequest struct {
+		Role string `json:"role"`
+	}
+
+	var req UpdateMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	role := strings.ToLower(strings.TrimSpace(req.Role))
+	if !models.IsValidOrgRole(role) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "role must be one of: owner, admin, editor, viewer",
+		})
+	}
+
+	var target models.OrganizationMember
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, member.OrganizationID); err != nil {
+			return err
+		}
+		if err := tx.Preload("User").Where("organization_id = ? AND user_id = ?", member.OrganizationID, targetID).First(&target).Error; err != nil {
+			return err
+		}
+
+		if (role == models.OrgRoleOwner || target.Role == models.OrgRoleOwner) && !member.HasRole(models.OrgRoleOwner) {
+			return errOwnerRoleForbidden
+		}
+		if target.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+			owners, err := countOwners(tx, member.OrganizationID, target.UserID)
+			if err != nil {
+				return err
+			}
+			if owners == 0 {
+				return errLastOwner
+			}
+		}
+
+		target.Role = role
+		return tx.Model(&target).Update("role", role).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "member not found",
+		})
+	}
+	if errors.Is(err, errOwnerRoleForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, errLastOwner) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update member",
+		})
+	}
+
+	return c.JSON(target)
+}
+
+// RemoveOrganizationMember removes a member. Admins can remove non-owners
+// and anyone can leave; the last owner can't.
+func RemoveOrganizationMember(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	targetID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid user ID",
+		})
+	}
+
+	role := models.OrgRoleAdmin
+	if targetID == user.ID {
+		role = models.OrgRoleViewer
+	}
+
+	member, err := findOrganizationMembership(c, user.ID, role)
+	if member == nil {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, member.OrganizationID); err != nil {
+			return err
+		}
+
+		var target models.OrganizationMember
+		if err := tx.Where("organization_id = ? AND user_id = ?", member.OrganizationID, targetID).First(&target).Error; err != nil {
+			return err
+		}
+
+		if target.Role == models.OrgRoleOwner {
+			if !member.HasRole(models.OrgRoleOwner) {
+				return errOwnerRoleForbidden
+			}
+			owners, err := countOwners(tx, member.OrganizationID, target.UserID)
+			if err != nil {
+				return err
+			}
+			if owners == 0 {
+				return errLastOwner
+			}
+		}
+
+		return tx.Delete(&target).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "member not found",
+		})
+	}
+	if errors.Is(err, errOwnerRoleForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, errLastOwner) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to remove member",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "member removed successfully",
+	})
+}
@@ -5,28 +5,192 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/mercadopago/sdk-go/pkg/config"
-	"github.com/mercadopago/sdk-go/pkg/payment"
 	appconfig "github.com/matills/litwick/internal/config"
 	"github.com/matills/litwick/internal/database"
 	"github.com/matills/litwick/internal/middleware"
 	"github.com/matills/litwick/internal/models"
 	"github.com/matills/litwick/internal/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var errFailedToCreatePayment = errors.New("failed to create payment")
+
+// creditPackageView is a package priced in a single currency, as shown in the store
+type creditPackageView struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Credits     int     `json:"credits"`
+	Price       float64 `json:"price"`
+	Currency    string  `json:"currency"`
+	Popular     bool    `json:"popular"`
+	Discount    int     `json:"discount"`
+}
+
+func requestedCurrency(currency string) string {
+	if currency == "" {
+		currency = appconfig.AppConfig.DefaultCurrency
+	}
+	return strings.ToUpper(currency)
+}
+
 func GetCreditPackages(c *fiber.Ctx) error {
-	packages := models.GetCreditPackages()
+	currency := requestedCurrency(c.Query("currency"))
+
+	var packages []models.CreditPackage
+	if err := database.DB.Preload("Prices").Where("active = ?", true).Order("sort_order ASC").Find(&packages).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch packages",
+		})
+	}
+
+	now := time.Now()
+	views := []creditPackageView{}
+	for _, pkg := range packages {
+		if !pkg.IsAvailable(now) {
+			continue
+		}
+		price := pkg.PriceFor(currency)
+		if price == nil {
+			continue
+		}
+		views = append(views, creditPackageView{
+			ID:          pkg.ID,
+			Name:        pkg.Name,
+			Description: pkg.Description,
+			Credits:     pkg.Credits,
+			Price:       price.Amount,
+			Currency:    price.Currency,
+			Popular:     pkg.Popular,
+			Discount:    pkg.Discount,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"packages": views,
+		"currency": currency,
+	})
+}
+
+// findAvailablePackage loads a package that can currently be bought in the given currency
+func findAvailablePackage(tx *gorm.DB, packageID, currency string) (*models.CreditPackage, *models.CreditPackagePrice, error) {
+	var pkg models.CreditPackage
+	if err := tx.Preload("Prices").Where("id = ?", packageID).First(&pkg).Error; err != nil {
+		return nil, nil, errors.New("invalid package ID")
+	}
+	if !pkg.IsAvailable(time.Now()) {
+		return nil, nil, errors.New("package is not available")
+	}
+	price := pkg.PriceFor(currency)
+	if price == nil {
+		return nil, nil, fmt.Errorf("package not available in %s", currency)
+	}
+	return &pkg, price, nil
+}
+
+// findRedeemablePromoCode loads a promo code and checks that the account can apply it
+// to the given package. The row is locked so concurrent payments can't exceed MaxUses.
+func findRedeemablePromoCode(tx *gorm.DB, code string, account services.Account, packageID, currency string) (*models.PromoCode, error) {
+	var promo models.PromoCode
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", models.NormalizePromoCode(code)).
+		First(&promo).Error; err != nil {
+		return nil, errors.New("invalid promo code")
+	}
+
+	if !promo.IsValid(time.Now()) {
+		return nil, errors.New("promo code expired or inactive")
+	}
+	if !promo.AppliesTo(packageID, currency) {
+		return nil, errors.New("promo code does not apply to this package")
+	}
+
+	// Pending payments count as uses until they are rejected or cancelled
+	redeemed := tx.Model(&models.Payment{}).
+		Where("promo_code_id = ? AND status IN ?", promo.ID, []models.PaymentStatus{models.PaymentPending, models.PaymentApproved})
+
+	if promo.MaxUses != nil {
+		var uses int64
+		if err := redeemed.Session(&gorm.Session{}).Count(&uses).Error; err != nil {
+			return nil, err
+		}
+		if uses >= int64(*promo.MaxUses) {
+			return nil, errors.New("promo code usage limit reached")
+		}
+	}
+
+	// The credits go to the account, so MaxUsesPerUser counts per account:
+	// an organization redeems a code once however many members it has, and
+	// a member's personal uses don't count against it
+	if promo.MaxUsesPerUser != nil {
+		var uses int64
+		if err := redeemed.Session(&gorm.Session{}).Scopes(account.Scope).Count(&uses).Error; err != nil {
+			return nil, err
+		}
+		if uses >= int64(*promo.MaxUsesPerUser) {
+			return nil, errors.New("promo code already used")
+		}
+	}
+
+	return &promo, nil
+}
+
+// ValidatePromoCode previews the discount a promo code gives on a package
+func ValidatePromoCode(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	type ValidatePromoRequest struct {
+		Code      string `json:"code"`
+		PackageID string `json:"package_id"`
+		Currency  string `json:"currency"`
+	}
+
+	var req ValidatePromoRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	currency := requestedCurrency(req.Currency)
+
+	_, price, err := findAvailablePackage(database.DB, req.PackageID, currency)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	promo, err := findRedeemablePromoCode(database.DB, req.Code, middleware.GetAccount(c), req.PackageID, currency)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"valid": false,
+			"error": err.Error(),
+		})
+	}
+
+	discount := promo.DiscountFor(price.Amount)
 	return c.JSON(fiber.Map{
-		"packages": packages,
+		"valid":           true,
+		"code":            promo.Code,
+		"original_amount": price.Amount,
+		"discount_amount": discount,
+		"amount":          price.Amount - discount,
+		"currency":        currency,
 	})
 }
 
@@ -40,6 +204,8 @@ func CreatePayment(c *fiber.Ctx) error {
 
 	type CreatePaymentRequest struct {
 		PackageID string `json:"package_id"`
+		Currency  string `json:"currency"`
+		PromoCode string `json:"promo_code"`
 	}
 
 	var req CreatePaymentRequest
@@ -49,42 +215,92 @@ func CreatePayment(c *fiber.Ctx) error {
 		})
 	}
 
-	packages := models.GetCreditPackages()
+	currency := requestedCurrency(req.Currency)
+
 	var selectedPackage *models.CreditPackage
-	for _, pkg := range packages {
-		if pkg.ID == req.PackageID {
-			selectedPackage = &pkg
-			break
+	var payment models.Payment
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		pkg, price, err := findAvailablePackage(tx, req.PackageID, currency)
+		if err != nil {
+			return err
+		}
+		selectedPackage = pkg
+
+		payment = models.Payment{
+			UserID:            user.ID,
+			OrganizationID:    middleware.GetAccount(c).OrganizationID,
+			Status:            models.PaymentPending,
+			Amount:            price.Amount,
+			OriginalAmount:    price.Amount,
+			Currency:          price.Currency,
+			CreditsAmount:     pkg.Credits,
+			CreditsExpireDays: pkg.ExpireDays,
+			PackageName:       pkg.Name,
+			PackageID:         pkg.ID,
 		}
-	}
 
-	if selectedPackage == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid package ID",
+		if strings.TrimSpace(req.PromoCode) != "" {
+			promo, err := findRedeemablePromoCode(tx, req.PromoCode, middleware.GetAccount(c), pkg.ID, price.Currency)
+			if err != nil {
+				return err
+			}
+			payment.PromoCodeID = &promo.ID
+			payment.PromoCode = &promo.Code
+			payment.DiscountAmount = promo.DiscountFor(price.Amount)
+			payment.Amount = price.Amount - payment.DiscountAmount
+		}
+
+		if err := tx.Create(&payment).Error; err != nil {
+			log.Printf("Failed to create payment: %v", err)
+			return errFailedToCreatePayment
+		}
+		return nil
+	})
+	if err != nil {
+		status := fiber.StatusBadRequest
+		if errors.Is(err, errFailedToCreatePayment) {
+			status = fiber.StatusInternalServerError
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
 
-	payment := models.Payment{
-		UserID:        user.ID,
-		Status:        models.PaymentPending,
-		Amount:        selectedPackage.Price,
-		Currency:      selectedPackage.Currency,
-		CreditsAmount: selectedPackage.Credits,
-		PackageName:   selectedPackage.Name,
-	}
+	// MercadoPago rejects preferences for nothing, so a code that covers the
+	// whole price settles the order right away
+	if payment.Amount <= 0 {
+		settled, err := services.SettlePayment(database.DB, payment.ID, services.ProviderPaymentUpdate{
+			Status:        "approved",
+			Amount:        payment.Amount,
+			Currency:      payment.Currency,
+			PaymentMethod: services.SettlementPromoCode,
+			Source:        services.SettlementPromoCode,
+		})
+		if err != nil {
+			log.Printf("Failed to settle fully discounted payment %s: %v", payment.ID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to complete payment",
+			})
+		}
 
-	if err := database.DB.Create(&payment).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to create payment",
+		return c.JSON(fiber.Map{
+			"payment_id":      settled.ID,
+			"status":          settled.Status,
+			"amount":          settled.Amount,
+			"currency":        settled.Currency,
+			"discount_amount": settled.DiscountAmount,
 		})
 	}
 
 	mpService := services.NewMercadoPagoService()
 	ctx := context.Background()
 
-	prefResp, err := mpService.CreatePreference(ctx, *selectedPackage, user.Email, payment.ID.String())
+	prefResp, err := mpService.CreatePreference(ctx, *selectedPackage, payment, user.Email)
 	if err != nil {
 		log.Printf("Failed to create MercadoPago preference: %v", err)
+		// Nobody can pay it, so it must not hold a promo code use
+		database.DB.Model(&payment).Update("status", models.PaymentCancelled)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to create payment preference",
 		})
@@ -97,9 +313,12 @@ func CreatePayment(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"payment_id":   payment.ID,
-		"init_point":   prefResp.InitPoint,
-		"preference_id": prefResp.PreferenceID,
+		"payment_id":      payment.ID,
+		"init_point":      prefResp.InitPoint,
+		"preference_id":   prefResp.PreferenceID,
+		"amount":          payment.Amount,
+		"currency":        payment.Currency,
+		"discount_amount": payment.DiscountAmount,
 	})
 }
 
@@ -178,9 +397,9 @@ func WebhookMercadoPago(c *fiber.Ctx) error {
 	}
 
 	type WebhookData struct {
-		Action      string `json:"action"`
-		APIVersion  string `json:"api_version"`
-		Data        struct {
+		Action     string `json:"action"`
+		APIVersion string `json:"api_version"`
+		Data       struct {
 			ID string `json:"id"`
 		} `json:"data"`
 		DateCreated string `json:"date_created"`
@@ -205,29 +424,14 @@ func WebhookMercadoPago(c *fiber.Ctx) error {
 	}
 
 	// Get payment details from MercadoPago
-	cfg, err := config.New(appconfig.AppConfig.MercadoPagoAccessToken)
-	if err != nil {
-		log.Printf("Failed to create MercadoPago config: %v", err)
-		return c.SendStatus(fiber.StatusInternalServerError)
-	}
-
-	paymentClient := payment.NewClient(cfg)
-	ctx := context.Background()
-
-	// Convert payment ID from string to int
-	paymentID, err := strconv.Atoi(webhook.Data.ID)
-	if err != nil {
-		log.Printf("Invalid payment ID format: %v", err)
-		return c.SendStatus(fiber.StatusBadRequest)
-	}
-
-	mpPayment, err := paymentClient.Get(ctx, paymentID)
+	mpService := services.NewMercadoPagoService()
+	mpPayment, err := mpService.GetPayment(context.Background(), webhook.Data.ID)
 	if err != nil {
 		log.Printf("Failed to get payment from MercadoPago: %v", err)
 		return c.SendStatus(fiber.StatusInternalServerError)
 	}
 
-	log.Printf("Retrieved payment from MercadoPago: id=%s, status=%s, external_reference=%s",
+	log.Printf("Retrieved payment from MercadoPago: id=%d, status=%s, external_reference=%s",
 		mpPayment.ID, mpPayment.Status, mpPayment.ExternalReference)
 
 	// Find our payment record using external_reference (which is our payment ID)
@@ -242,106 +446,28 @@ func WebhookMercadoPago(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusBadRequest)
 	}
 
-	var ourPayment models.Payment
-	if err := database.DB.Where("id = ?", paymentUUID).First(&ourPayment).Error; err != nil {
-		log.Printf("Payment not found in database: %s", paymentUUID)
-		return c.SendStatus(fiber.StatusNotFound)
-	}
-
-	// Check if already processed to avoid double-processing
-	if ourPayment.Status != models.PaymentPending {
-		log.Printf("Payment already processed with status: %s", ourPayment.Status)
-		return c.SendStatus(fiber.StatusOK)
-	}
-
-	// Update payment based on MercadoPago status
-	mpPaymentID := fmt.Sprintf("%d", mpPayment.ID)
-	now := time.Now()
-	ourPayment.MercadoPagoPaymentID = &mpPaymentID
-
-	switch mpPayment.Status {
-	case "approved":
-		log.Printf("Payment approved - adding %d credits to user %s", ourPayment.CreditsAmount, ourPayment.UserID)
-
-		ourPayment.Status = models.PaymentApproved
-		ourPayment.CompletedAt = &now
-
-		// Get user and add credits
-		var user models.User
-		if err := database.DB.Where("id = ?", ourPayment.UserID).First(&user).Error; err != nil {
-			log.Printf("Failed to find user: %v", err)
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
-
-		// Add credits to user
-		balanceBefore := user.CreditsRemaining
-		user.CreditsRemaining += ourPayment.CreditsAmount
-
-		if err := database.DB.Save(&user).Error; err != nil {
-			log.Printf("Failed to update user credits: %v", err)
-			return c.SendStatus(fiber.StatusInternalServerError)
-		}
-
-		// Create credit transaction record
-		transaction := models.CreditTransaction{
-			UserID:        user.ID,
-			Type:          models.TransactionCredit,
-			Amount:        ourPayment.CreditsAmount,
-			BalanceBefore: balanceBefore,
-			BalanceAfter:  user.CreditsRemaining,
-			Description:   fmt.Sprintf("Compra de paquete %s (Webhook)", ourPayment.PackageName),
+	update := services.ProviderUpdateFromMercadoPago(mpPayment, services.SettlementWebhook)
+	ourPayment, err := services.SettlePayment(database.DB, paymentUUID, update)
+	if err != nil {
+		if errors.Is(err, services.ErrPaymentAlreadyProcessed) {
+			log.Printf("Payment already processed with status: %s", ourPayment.Status)
+			return c.SendStatus(fiber.StatusOK)
 		}
-		if err := database.DB.Create(&transaction).Error; err != nil {
-			log.Printf("Failed to create credit transaction: %v", err)
-		}
-
-		log.Printf("Successfully added %d credits to user %s. New balance: %d",
-			ourPayment.CreditsAmount, user.ID, user.CreditsRemaining)
-
-	case "rejected", "cancelled":
-		log.Printf("Payment %s - status: %s", mpPayment.Status, mpPayment.Status)
-		if mpPayment.Status == "rejected" {
-			ourPayment.Status = models.PaymentRejected
-		} else {
-			ourPayment.Status = models.PaymentCancelled
+		if errors.Is(err, services.ErrPaymentAmountMismatch) {
+			// Retrying won't change the amount; the reconciler reports it
+			log.Printf("Payment %s held for review: %v", paymentUUID, err)
+			return c.SendStatus(fiber.StatusOK)
 		}
+		log.Printf("Failed to settle payment %s: %v", paymentUUID, err)
+		return c.SendStatus(fiber.StatusInternalServerError)
+	}
 
-	case "pending", "in_process", "in_mediation":
+	if !update.IsFinal() {
 		log.Printf("Payment still pending - status: %s", mpPayment.Status)
-		// Keep as pending, don't update status yet
 		return c.SendStatus(fiber.StatusOK)
-
-	default:
-		log.Printf("Unknown payment status: %s", mpPayment.Status)
-		return c.SendStatus(fiber.StatusOK)
-	}
-
-	// Save payment method if available
-	if mpPayment.PaymentMethodID != "" {
-		ourPayment.PaymentMethod = &mpPayment.PaymentMethodID
-	}
-
-	// Save payment details as JSON
-	detailsMap := map[string]interface{}{
-		"mercadopago_payment_id": mpPayment.ID,
-		"status":                 mpPayment.Status,
-		"status_detail":          mpPayment.StatusDetail,
-		"payment_type":           mpPayment.PaymentTypeID,
-		"payment_method":         mpPayment.PaymentMethodID,
-		"transaction_amount":     mpPayment.TransactionAmount,
-		"processed_at":           time.Now().Format(time.RFC3339),
 	}
-	detailsJSON, _ := json.Marshal(detailsMap)
-	detailsStr := string(detailsJSON)
-	ourPayment.PaymentDetails = &detailsStr
 
-	// Save updated payment
-	if err := database.DB.Save(&ourPayment).Error; err != nil {
-		log.Printf("Failed to save payment: %v", err)
-		return c.SendStatus(fiber.StatusInternalServerError)
-	}
-
-	log.Printf("Webhook processed successfully for payment %s", ourPayment.ID)
+	log.Printf("Webhook processed successfully for payment %s: %s", ourPayment.ID, ourPayment.Status)
 	return c.SendStatus(fiber.StatusOK)
 }
 
@@ -354,7 +480,7 @@ func GetPaymentHistory(c *fiber.Ctx) error {
 	}
 
 	var payments []models.Payment
-	if err := database.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&payments).Error; err != nil {
+	if err := database.DB.Preload("Invoice").Scopes(middleware.GetAccount(c).Scope).Order("created_at DESC").Find(&payments).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to fetch payment history",
 		})
@@ -388,7 +514,7 @@ func ProcessPaymentSuccess(c *fiber.Ctx) error {
 	}
 
 	var payment models.Payment
-	if err := database.DB.Where("id = ? AND user_id = ?", externalReference, user.ID).First(&payment).Error; err != nil {
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", externalReference).First(&payment).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "payment not found",
 		})
@@ -401,61 +527,105 @@ func ProcessPaymentSuccess(c *fiber.Ctx) error {
 		})
 	}
 
-	now := time.Now()
-	payment.MercadoPagoPaymentID = &paymentID
-	payment.PreferenceID = &preferenceID
-
-	if status == "approved" {
-		payment.Status = models.PaymentApproved
-		payment.CompletedAt = &now
-
-		user.CreditsRemaining += payment.CreditsAmount
-		if err := database.DB.Save(user).Error; err != nil {
-			log.Printf("Failed to add credits to user: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "failed to add credits",
-			})
-		}
+	if preferenceID != "" && payment.PreferenceID == nil {
+		payment.PreferenceID = &preferenceID
+		database.DB.Model(&payment).Update("preference_id", preferenceID)
+	}
 
-		transaction := models.CreditTransaction{
-			UserID:        user.ID,
-			Type:          models.TransactionCredit,
-			Amount:        payment.CreditsAmount,
-			BalanceBefore: user.CreditsRemaining - payment.CreditsAmount,
-			BalanceAfter:  user.CreditsRemaining,
-			Description:   fmt.Sprintf("Compra de paquete %s", payment.PackageName),
-		}
-		database.DB.Create(&transaction)
-
-		log.Printf("Payment approved: user_id=%s, credits_added=%d", user.ID, payment.CreditsAmount)
-	} else if status == "rejected" || status == "cancelled" {
-		if status == "rejected" {
-			payment.Status = models.PaymentRejected
-		} else {
-			payment.Status = models.PaymentCancelled
-		}
+	// The redirect query string is client-controlled, so the status is always
+	// confirmed with MercadoPago before settling. If it can't be confirmed the
+	// payment stays pending for the webhook or the reconciler.
+	if paymentID == "" {
+		return c.JSON(fiber.Map{
+			"payment": payment,
+			"user":    user,
+		})
 	}
 
-	if paymentID != "" {
-		details := map[string]string{
-			"payment_id":    paymentID,
-			"status":        status,
-			"preference_id": preferenceID,
-		}
-		detailsJSON, _ := json.Marshal(details)
-		detailsStr := string(detailsJSON)
-		payment.PaymentDetails = &detailsStr
+	mpPayment, err := services.NewMercadoPagoService().GetPayment(context.Background(), paymentID)
+	if err != nil || mpPayment.ExternalReference != payment.ID.String() {
+		log.Printf("Could not confirm payment %s with MercadoPago: %v", paymentID, err)
+		return c.JSON(fiber.Map{
+			"payment": payment,
+			"user":    user,
+		})
 	}
 
-	if err := database.DB.Save(&payment).Error; err != nil {
-		log.Printf("Failed to save payment: %v", err)
+	settled, err := services.SettlePayment(database.DB, payment.ID, services.ProviderUpdateFromMercadoPago(mpPayment, services.SettlementCallback))
+	if errors.Is(err, services.ErrPaymentAmountMismatch) {
+		log.Printf("Payment %s held for review: %v", payment.ID, err)
+		return c.JSON(fiber.Map{
+			"payment": payment,
+			"user":    user,
+		})
+	}
+	if err != nil && !errors.Is(err, services.ErrPaymentAlreadyProcessed) {
+		log.Printf("Failed to settle payment %s: %v", payment.ID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to save payment",
 		})
 	}
 
+	if settled.Status == models.PaymentApproved {
+		log.Printf("Payment approved: user_id=%s, credits_added=%d", user.ID, settled.CreditsAmount)
+		database.DB.Where("id = ?", user.ID).First(user)
+	}
+
 	return c.JSON(fiber.Map{
-		"payment": payment,
-		"user": user,
+		"payment": settled,
+		"user":    user,
 	})
 }
+
+// GetPaymentReceipt downloads the PDF receipt of an approved payment. Payments
+// approved before invoicing existed get their invoice issued on first download.
+func GetPaymentReceipt(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	paymentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid payment ID",
+		})
+	}
+
+	var payment models.Payment
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", paymentID).First(&payment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "payment not found",
+		})
+	}
+
+	if payment.Status != models.PaymentApproved {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "receipts are only available for approved payments",
+		})
+	}
+
+	var invoice *models.Invoice
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		invoice, err = services.IssueInvoice(tx, &payment)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to issue invoice for payment %s: %v", payment.ID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to issue invoice",
+		})
+	}
+
+	promoCode := ""
+	if payment.PromoCode != nil {
+		promoCode = *payment.PromoCode
+	}
+
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"recibo-%s.pdf\"", invoice.DisplayNumber))
+
+	return c.Send(services.InvoicePDF(invoice, promoCode))
+}
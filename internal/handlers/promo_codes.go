@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/models"
+)
+
+type promoCodeRequest struct {
+	Code            string     `json:"code"`
+	Description     string     `json:"description"`
+	DiscountPercent int        `json:"discount_percent"`
+	DiscountAmount  float64    `json:"discount_amount"`
+	Currency        string     `json:"currency"`
+	PackageID       *string    `json:"package_id"`
+	MaxUses         *int       `json:"max_uses"`
+	MaxUsesPerUser  *int       `json:"max_uses_per_user"`
+	Active          bool       `json:"active"`
+	ValidFrom       *time.Time `json:"valid_from"`
+	ValidUntil      *time.Time `json:"valid_until"`
+}
+
+func (r *promoCodeRequest) validate() error {
+	if models.NormalizePromoCode(r.Code) == "" {
+		return errors.New("code is required")
+	}
+	if r.DiscountPercent < 0 || r.DiscountPercent > 100 {
+		return errors.New("discount_percent must be between 0 and 100")
+	}
+	if r.DiscountAmount < 0 {
+		return errors.New("discount_amount can't be negative")
+	}
+	if r.DiscountPercent == 0 && r.DiscountAmount == 0 {
+		return errors.New("a discount_percent or discount_amount is required")
+	}
+	if r.DiscountAmount > 0 && len(strings.TrimSpace(r.Currency)) != 3 {
+		return errors.New("currency is required for fixed amount discounts")
+	}
+	if r.MaxUses != nil && *r.MaxUses < 1 {
+		return errors.New("max_uses must be positive")
+	}
+	if r.MaxUsesPerUser != nil && *r.MaxUsesPerUser < 1 {
+		return errors.New("max_uses_per_user must be positive")
+	}
+	if r.ValidFrom != nil && r.ValidUntil != nil && !r.ValidUntil.After(*r.ValidFrom) {
+		return errors.New("valid_until must be after valid_from")
+	}
+	return nil
+}
+
+func (r *promoCodeRequest) apply(promo *models.PromoCode) {
+	promo.Code = models.NormalizePromoCode(r.Code)
+	promo.Description = r.Description
+	promo.DiscountPercent = r.DiscountPercent
+	promo.DiscountAmount = r.DiscountAmount
+	promo.Currency = strings.ToUpper(strings.TrimSpace(r.Currency))
+	promo.PackageID = r.PackageID
+	promo.MaxUses = r.MaxUses
+	promo.MaxUsesPerUser = r.MaxUsesPerUser
+	promo.Active = r.Active
+	promo.ValidFrom = r.ValidFrom
+	promo.ValidUntil = r.ValidUntil
+}
+
+// promoCodeWithUsage adds the redemption count to a promo code
+type promoCodeWithUsage struct {
+	models.PromoCode
+	TimesUsed int64 `json:"times_used"`
+}
+
+func AdminListPromoCodes(c *fiber.Ctx) error {
+	var promos []models.PromoCode
+	if err := database.DB.Order("created_at DESC").Find(&promos).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch promo codes",
+		})
+	}
+
+	type usageRow struct {
+		PromoCodeID uuid.UUID
+		Uses        int64
+	}
+	var rows []usageRow
+	database.DB.Model(&models.Payment{}).
+		Select("promo_code_id, COUNT(*) AS uses").
+		Where("promo_code_id IS NOT NULL AND status IN ?", []models.PaymentStatus{models.PaymentPending, models.PaymentApproved}).
+		Group("promo_code_id").
+		Scan(&rows)
+
+	uses := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		uses[row.PromoCodeID] = row.Uses
+	}
+
+	result := make([]promoCodeWithUsage, 0, len(promos))
+	for _, promo := range promos {
+		result = append(result, promoCodeWithUsage{PromoCode: promo, TimesUsed: uses[promo.ID]})
+	}
+
+	return c.JSON(fiber.Map{
+		"promo_codes": result,
+	})
+}
+
+func AdminCreatePromoCode(c *fiber.Ctx) error {
+	var req promoCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if err := req.validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var existing int64
+	database.DB.Model(&models.PromoCode{}).Where("code = ?", models.NormalizePromoCode(req.Code)).Count(&existing)
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "promo code already exists",
+		})
+	}
+
+	var promo models.PromoCode
+	req.apply(&promo)
+
+	if err := database.DB.Create(&promo).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create promo code",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(promo)
+}
+
+func AdminUpdatePromoCode(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid promo code ID",
+		})
+	}
+
+	var promo models.PromoCode
+	if err := database.DB.Where("id = ?", id).First(&promo).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "promo code not found",
+		})
+	}
+
+	var req promoCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+	if err := req.validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// The code itself is what payments reference by name, so it can't change
+	req.Code = promo.Code
+	req.apply(&promo)
+
+	if err := database.DB.Save(&promo).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update promo code",
+		})
+	}
+
+	return c.JSON(promo)
+}
+
+// AdminDeletePromoCode deactivates a promo code. Codes already recorded on
+// payments are kept so the payment history stays consistent.
+func AdminDeletePromoCode(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid promo code ID",
+		})
+	}
+
+	result := database.DB.Model(&models.PromoCode{}).Where("id = ?", id).Update("active", false)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete promo code",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "promo code not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "promo code deactivated",
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/jobs"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+)
+
+// AdminListReconciliationRuns returns recent reconciliation runs without their full reports
+func AdminListReconciliationRuns(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var runs []models.ReconciliationRun
+	if err := database.DB.Omit("report").Order("started_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch reconciliation runs",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"runs": runs,
+	})
+}
+
+func AdminGetReconciliationRun(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid run ID",
+		})
+	}
+
+	var run models.ReconciliationRun
+	if err := database.DB.Where("id = ?", id).First(&run).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "reconciliation run not found",
+		})
+	}
+
+	return c.JSON(run)
+}
+
+// AdminRunReconciliation runs the payment reconciler immediately. With
+// ?dry_run=true it only reports what it would change.
+func AdminRunReconciliation(c *fiber.Ctx) error {
+	opts := jobs.DefaultReconcileOptions("manual")
+	opts.DryRun = c.QueryBool("dry_run", false)
+
+	run, report, err := services.ReconcilePayments(c.Context(), database.DB, services.NewMercadoPagoService(), opts)
+	if err != nil {
+		log.Printf("Manual reconciliation failed: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "reconciliation failed",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"run":    run,
+		"report": report,
+	})
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	appconfig "github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+	"gorm.io/gorm"
+)
+
+const maxShareLinksPerTranscription = 20
+
+// shareLinkResponse exposes the allowed formats as a list, with the public URL
+type shareLinkResponse struct {
+	models.ShareLink
+	Formats          []string `json:"formats"`
+	PasswordRequired bool     `json:"password_required"`
+	URL              string   `json:"url"`
+}
+
+func newShareLinkResponse(link models.ShareLink) shareLinkResponse {
+	return shareLinkResponse{
+		ShareLink:        link,
+		Formats:          link.FormatList(),
+		PasswordRequired: link.HasPassword(),
+		URL:              fmt.Sprintf("%s/share/%s", strings.TrimRight(appconfig.AppConfig.FrontendURL, "/"), link.Token),
+	}
+}
+
+// normalizeShareFormats validates and deduplicates requested formats. No
+// formats means every format.
+func normalizeShareFormats(formats []string) (string, bool) {
+	if len(formats) == 0 {
+		return strings.Join(models.ShareFormats, ","), true
+	}
+
+	seen := map[string]bool{}
+	var result []string
+	for _, format := range formats {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if !models.IsValidShareFormat(format) {
+			return "", false
+		}
+		if !seen[format] {
+			seen[format] = true
+			result = append(result, format)
+		}
+	}
+	return strings.Join(result, ","), true
+}
+
+// CreateShareLink creates a read-only public link to a completed transcription
+func CreateShareLink(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	transcription, err := findAccountTranscription(c, middleware.GetAccount(c))
+	if transcription == nil {
+		return err
+	}
+
+	if transcription.Status != models.StatusCompleted {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "transcription not completed",
+		})
+	}
+
+	type CreateShareLinkRequest struct {
+		ExpiresInDays int      `json:"expires_in_days"`
+		Password      string   `json:"password"`
+		Formats       []string `json:"formats"`
+	}
+
+	var req CreateShareLinkRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+	}
+
+	formats, ok := normalizeShareFormats(req.Formats)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "formats must be one or more of: txt, srt, vtt",
+		})
+	}
+
+	var active int64
+	database.DB.Model(&models.ShareLink{}).Where("transcription_id = ? AND revoked_at IS NULL", transcription.ID).Count(&active)
+	if active >= maxShareLinksPerTranscription {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "too many share links, revoke one first",
+		})
+	}
+
+	token, err := services.GenerateShareToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to generate share link",
+		})
+	}
+
+	link := models.ShareLink{
+		TranscriptionID: transcription.ID,
+		CreatedByID:     user.ID,
+		Token:           token,
+		Formats:         formats,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		link.ExpiresAt = &expiresAt
+	}
+	if req.Password != "" {
+		hash, err := services.HashSharePassword(req.Password)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid password",
+			})
+		}
+		link.PasswordHash = hash
+	}
+
+	if err := database.DB.Create(&link).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create share link",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(newShareLinkResponse(link))
+}
+
+// ListShareLinks returns a transcription's share links, including revoked
+// and expired ones
+func ListShareLinks(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	transcription, err := findAccountTranscription(c, middleware.GetAccount(c))
+	if transcription == nil {
+		return err
+	}
+
+	var links []models.ShareLink
+	if err := database.DB.Where("transcription_id = ?", transcription.ID).Order("created_at DESC").Find(&links).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch share links",
+		})
+	}
+
+	result := make([]shareLinkResponse, 0, len(links))
+	for _, link := range links {
+		result = append(result, newShareLinkResponse(link))
+	}
+
+	return c.JSON(fiber.Map{
+		"share_links": result,
+	})
+}
+
+// RevokeShareLink disables a share link right away
+func RevokeShareLink(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	transcription, err := findAccountTranscription(c, middleware.GetAccount(c))
+	if transcription == nil {
+		return err
+	}
+
+	shareID, err := uuid.Parse(c.Params("shareId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid share link ID",
+		})
+	}
+
+	var link models.ShareLink
+	if err := database.DB.Where("id = ? AND transcription_id = ?", shareID, transcription.ID).First(&link).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "share link not found",
+		})
+	}
+
+	if link.RevokedAt == nil {
+		now := time.Now()
+		link.RevokedAt = &now
+		if err := database.DB.Model(&link).Update("revoked_at", now).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to revoke share link",
+			})
+		}
+	}
+
+	return c.JSON(newShareLinkResponse(link))
+}
+
+// findSharedTranscription loads the share link in the :token param with its
+// transcription. Revoked, expired and unknown links look the same. The
+// password, when set, comes in the X-Share-Password header or the password
+// query parameter, so media elements and download links can pass it too.
+func findSharedTranscription(c *fiber.Ctx) (*models.ShareLink, error) {
+	var link models.ShareLink
+	if err := database.DB.Preload("Transcription").Where("token = ?", c.Params("token")).First(&link).Error; err != nil || !link.IsActive(time.Now()) {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "share link not found or expired",
+		})
+	}
+
+	if link.Transcription.Status != models.StatusCompleted {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcript not available",
+		})
+	}
+
+	// The password only travels in a header, never in URLs that end up in
+	// logs and browser history. Media and downloads can't send headers, so
+	// they take the access token issued with the transcript instead.
+	if link.HasPassword() && !services.CheckShareAccessToken(c.Query("access"), link.Token, link.PasswordHash, time.Now()) {
+		password := c.Get("X-Share-Password")
+		if password == "" || !services.CheckSharePassword(link.PasswordHash, password) {
+			return nil, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":             "password required",
+				"password_required": true,
+			})
+		}
+	}
+
+	return &link, nil
+}
+
+// GetSharedTranscript renders a shared transcript: its text, timed cues for
+// the player and what can be downloaded. Nothing about the owner or the
+// storage location is included.
+func GetSharedTranscript(c *fiber.Ctx) error {
+	link, err := findSharedTranscription(c)
+	if link == nil {
+		return err
+	}
+
+	now := time.Now()
+	database.DB.Model(&models.ShareLink{}).Where("id = ?", link.ID).UpdateColumns(map[string]interface{}{
+		"view_count":     gorm.Expr("view_count + 1"),
+		"last_viewed_at": now,
+	})
+
+	t := link.Transcription
+
+	text := ""
+	if t.TranscriptText != nil {
+		text = *t.TranscriptText
+	}
+	cues := []services.CaptionCue{}
+	if t.SRTContent != nil {
+		cues = services.ParseSRTCues(*t.SRTContent)
+	}
+
+	access := ""
+	var accessExpiresAt *time.Time
+	if link.HasPassword() {
+		expiresAt := now.Add(services.ShareAccessTTL)
+		if link.ExpiresAt != nil && link.ExpiresAt.Before(expiresAt) {
+			expiresAt = *link.ExpiresAt
+		}
+		access = services.ShareAccessToken(link.Token, link.PasswordHash, expiresAt)
+		accessExpiresAt = &expiresAt
+	}
+
+	mediaURL := ""
+	if storage, err := services.NewStorageService(c.Context()); err == nil && t.HasMedia() && storage.IsStoredFile(t.FileURL) {
+		mediaURL = fmt.Sprintf("/api/share/%s/media", link.Token)
+		if access != "" {
+			mediaURL += "?access=" + url.QueryEscape(access)
+		}
+	}
+
+	response := fiber.Map{
+		"file_name":       t.FileName,
+		"language":        t.Language,
+		"duration":        t.Duration,
+		"speaker_labels":  t.SpeakerLabels,
+		"completed_at":    t.CompletedAt,
+		"transcript_text": text,
+		"cues":            cues,
+		"formats":         link.FormatList(),
+		"media_url":       mediaURL,
+		"expires_at":      link.ExpiresAt,
+	}
+	if access != "" {
+		response["access_token"] = access
+		response["access_expires_at"] = accessExpiresAt
+	}
+	return c.JSON(response)
+}
+
+// DownloadSharedTranscript downloads a shared transcript in one of the
+// formats the link allows
+func DownloadSharedTranscript(c *fiber.Ctx) error {
+	link, err := findSharedTranscription(c)
+	if link == nil {
+		return err
+	}
+
+	format := c.Query("format", "txt")
+	if !link.AllowsFormat(format) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format not available for this link",
+		})
+	}
+
+	content, contentType, filename := exportTranscript(&link.Transcription, format)
+
+	c.Set("Content-Type", contentType)
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	return c.SendString(content)
+}
+
+// StreamSharedMedia proxies the shared transcription's media from storage,
+// passing Range requests through so the player can seek. Media that was
+// transcribed from a remote URL without copying isn't available.
+func StreamSharedMedia(c *fiber.Ctx) error {
+	link, err := findSharedTranscription(c)
+	if link == nil {
+		return err
+	}
+
+	storage, err := services.NewStorageService(c.Context())
+	if err != nil || !link.Transcription.HasMedia() || !storage.IsStoredFile(link.Transcription.FileURL) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "media not available",
+		})
+	}
+
+	return sendStoredObject(c, storage, storage.ExtractFilePathFromURL(link.Transcription.FileURL))
+}
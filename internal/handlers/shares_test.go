@@ -0,0 +1,28 @@
+package handlers
+
+import "testing"
+
+func TestNormalizeShareFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		formats []string
+		want    string
+		ok      bool
+	}{
+		{"none means all", nil, "txt,srt,vtt", true},
+		{"one", []string{"srt"}, "srt", true},
+		{"case and spaces", []string{" TXT ", "Vtt"}, "txt,vtt", true},
+		{"duplicates", []string{"srt", "SRT", "txt"}, "srt,txt", true},
+		{"unknown", []string{"txt", "docx"}, "", false},
+		{"empty name", []string{""}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := normalizeShareFormats(tt.formats)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("normalizeShareFormats(%q) = %q, %v, want %q, %v", tt.formats, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
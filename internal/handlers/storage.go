@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	appconfig "github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+)
+
+// orphanMinAge reads ?min_age_hours, defaulting to a day so uploads still
+// being registered aren't reported
+func orphanMinAge(c *fiber.Ctx) time.Duration {
+	hours := c.QueryInt("min_age_hours", 24)
+	if hours < 1 {
+		hours = 1
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// AdminListOrphanedMedia reports stored uploads that no transcription points
+// to and that have no delete pending
+func AdminListOrphanedMedia(c *fiber.Ctx) error {
+	storage, err := services.NewStorageService(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to initialize storage",
+		})
+	}
+
+	objects, err := services.FindOrphanedMedia(c.Context(), database.DB, storage, orphanMinAge(c))
+	if err != nil {
+		log.Printf("Failed to find orphaned media: %v", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "failed to list storage",
+		})
+	}
+
+	var totalBytes int64
+	for _, object := range objects {
+		totalBytes += object.Size
+	}
+	if objects == nil {
+		objects = []services.StoredObject{}
+	}
+
+	return c.JSON(fiber.Map{
+		"objects":     objects,
+		"count":       len(objects),
+		"total_bytes": totalBytes,
+	})
+}
+
+// AdminPurgeOrphanedMedia queues the delete of every orphaned upload. The
+// deletes run in the background and are retried like any other.
+func AdminPurgeOrphanedMedia(c *fiber.Ctx) error {
+	storage, err := services.NewStorageService(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to initialize storage",
+		})
+	}
+
+	objects, err := services.FindOrphanedMedia(c.Context(), database.DB, storage, orphanMinAge(c))
+	if err != nil {
+		log.Printf("Failed to find orphaned media: %v", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "failed to list storage",
+		})
+	}
+
+	deletions, err := services.QueueOrphanedMedia(database.DB, objects)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to queue deletions",
+		})
+	}
+	for _, deletion := range deletions {
+		services.DeleteMediaInBackground(database.DB, deletion.ID)
+	}
+
+	middleware.SetAuditTarget(c, "storage", nil, map[string]interface{}{
+		"queued": len(deletions),
+	})
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "deletions queued",
+		"queued":  len(deletions),
+	})
+}
+
+// AdminListMediaDeletions lists the storage delete queue, filtered by
+// ?status and ?reason
+func AdminListMediaDeletions(c *fiber.Ctx) error {
+	page, limit := adminPage(c)
+
+	query := database.DB.Model(&models.MediaDeletion{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var deletions []models.MediaDeletion
+	if err := query.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&deletions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch deletions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"deletions":  deletions,
+		"retention":  appconfig.AppConfig.MediaRetentionDays,
+		"pagination": adminPagination(page, limit, total),
+	})
+}
+
+// AdminRetryMediaDeletion puts a delete that gave up back in the queue with
+// a fresh set of attempts
+func AdminRetryMediaDeletion(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid deletion ID",
+		})
+	}
+
+	now := time.Now()
+	result := database.DB.Model(&models.MediaDeletion{}).
+		Where("id = ? AND status = ?", id, models.MediaDeletionFailed).
+		Updates(map[string]interface{}{
+			"status":          models.MediaDeletionPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to retry deletion",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "no failed deletion with that ID",
+		})
+	}
+
+	services.DeleteMediaInBackground(database.DB, id)
+	middleware.SetAuditTarget(c, "media_deletion", &id, nil)
+
+	return c.JSON(fiber.Map{
+		"message": "deletion queued",
+	})
+}
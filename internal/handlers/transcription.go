@@ -2,15 +2,21 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	appconfig "github.com/matills/litwick/internal/config"
 	"github.com/matills/litwick/internal/database"
 	"github.com/matills/litwick/internal/middleware"
 	"github.com/matills/litwick/internal/models"
 	"github.com/matills/litwick/internal/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func ProcessTranscription(c *fiber.Ctx) error {
@@ -30,7 +36,7 @@ func ProcessTranscription(c *fiber.Ctx) error {
 	}
 
 	var transcription models.Transcription
-	if err := database.DB.Where("id = ? AND user_id = ?", tid, user.ID).First(&transcription).Error; err != nil {
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", tid).First(&transcription).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "transcription not found",
 		})
@@ -42,10 +48,9 @@ func ProcessTranscription(c *fiber.Ctx) error {
 		})
 	}
 
-	transcription.Status = models.StatusProcessing
-	database.DB.Save(&transcription)
-
-	go processTranscriptionAsync(transcription.ID, user.ID)
+	if err := startTranscription(&transcription, []models.TranscriptionStatus{models.StatusPending}, nil); err != nil {
+		return startTranscriptionError(c, err)
+	}
 
 	return c.JSON(fiber.Map{
 		"message":       "transcription started",
@@ -53,37 +58,305 @@ func ProcessTranscription(c *fiber.Ctx) error {
 	})
 }
 
-func processTranscriptionAsync(transcriptionID, userID uuid.UUID) {
-	ctx := context.Background()
+// RetryTranscription restarts a failed or cancelled job with its stored media
+// and options. Such jobs were never charged, so the retry is billed normally.
+func RetryTranscription(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	transcriptionID := c.Params("id")
+	tid, err := uuid.Parse(transcriptionID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid transcription ID",
+		})
+	}
 
 	var transcription models.Transcription
-	if err := database.DB.First(&transcription, transcriptionID).Error; err != nil {
-		return
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", tid).First(&transcription).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcription not found",
+		})
+	}
+
+	if transcription.Status != models.StatusFailed && transcription.Status != models.StatusCancelled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "only failed or cancelled transcriptions can be retried",
+		})
+	}
+
+	if !transcription.HasMedia() {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error": errMediaPurged,
+		})
+	}
+
+	// A job that failed while charging knows its length; don't retry into
+	// the same insufficient balance
+	if transcription.Duration > 0 {
+		minutes := transcription.Duration / 60
+		if minutes == 0 {
+			minutes = 1
+		}
+		if !accountHasCredits(c, minutes) {
+			return c.Status(fiber.StatusPaymentRequired).JSON(fiber.Map{
+				"error": "insufficient credits",
+			})
+		}
+	}
+
+	if err := startTranscription(&transcription, []models.TranscriptionStatus{models.StatusFailed, models.StatusCancelled}, nil); err != nil {
+		return startTranscriptionError(c, err)
 	}
 
-	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
+	return c.JSON(fiber.Map{
+		"message":       "transcription restarted",
+		"transcription": transcription,
+	})
+}
+
+// ReprocessTranscription runs a completed job again, optionally with a new
+// language or options. The result becomes a new version, and the run is
+// charged like a new transcription.
+func ReprocessTranscription(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	transcriptionID := c.Params("id")
+	tid, err := uuid.Parse(transcriptionID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid transcription ID",
+		})
+	}
+
+	type ReprocessRequest struct {
+		Language      string `json:"language"`
+		SpeakerLabels *bool  `json:"speaker_labels"`
+	}
+
+	var req ReprocessRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+	}
+
+	var transcription models.Transcription
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", tid).First(&transcription).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcription not found",
+		})
+	}
+
+	if transcription.Status != models.StatusCompleted {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "only completed transcriptions can be reprocessed",
+		})
+	}
+
+	if !transcription.HasMedia() {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error": errMediaPurged,
+		})
+	}
+
+	minutes := transcription.Duration / 60
+	if minutes == 0 {
+		minutes = 1
+	}
+	if !accountHasCredits(c, minutes) {
+		return c.Status(fiber.StatusPaymentRequired).JSON(fiber.Map{
+			"error": "insufficient credits",
+		})
+	}
+
+	// The current transcript stays in place until the new result is
+	// recorded as the next version; if the run fails or is cancelled the
+	// job goes back to it
+	if req.Language != "" {
+		transcription.Language = req.Language
+	}
+	if req.SpeakerLabels != nil {
+		transcription.SpeakerLabels = *req.SpeakerLabels
+	}
+
+	if err := startTranscription(&transcription, []models.TranscriptionStatus{models.StatusCompleted}, map[string]interface{}{
+		"language":       transcription.Language,
+		"speaker_labels": transcription.SpeakerLabels,
+	}); err != nil {
+		return startTranscriptionError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message":       "transcription reprocessing",
+		"transcription": transcription,
+	})
+}
+
+// errMediaPurged is returned for jobs whose source media was deleted by the
+// retention policy
+const errMediaPurged = "the original media was deleted after the retention period"
+
+// errTranscriptionBusy is returned when a job's status changed between
+// reading it and starting it, such as two retries of the same job at once
+var errTranscriptionBusy = errors.New("transcription status changed, try again")
+
+// startTranscription moves a job from one of the given statuses to
+// processing and runs it in the background. changes are applied in the same
+// update, such as new options for a reprocess.
+func startTranscription(transcription *models.Transcription, from []models.TranscriptionStatus, changes map[string]interface{}) error {
+	if err := markProcessing(transcription, from, changes); err != nil {
+		return err
+	}
+	go processTranscriptionAsync(transcription.ID)
+	return nil
+}
+
+// ResumeRequeuedTranscriptions runs the jobs litwickctl handed back after
+// an instance left them stuck in processing. It runs on a schedule in every
+// server, and each job is claimed by one of them.
+func ResumeRequeuedTranscriptions(ctx context.Context) error {
+	ids, err := services.ClaimRequeuedTranscriptions(database.DB, 10)
+	for _, id := range ids {
+		runningTranscriptions.Lock()
+		_, running := runningTranscriptions.cancels[id]
+		runningTranscriptions.Unlock()
+		if running {
+			continue // slow rather than stuck, it is still ours
+		}
+		go processTranscriptionAsync(id)
+	}
+	return err
+}
+
+// markProcessing moves a job to processing with a conditional update, so of
+// two requests starting the same job only one gets through. A pending
+// requeue is dropped: the job is starting anyway.
+func markProcessing(transcription *models.Transcription, from []models.TranscriptionStatus, changes map[string]interface{}) error {
+	updates := map[string]interface{}{
+		"status":        models.StatusProcessing,
+		"error_message": "",
+		"requeued_at":   nil,
+	}
+	for column, value := range changes {
+		updates[column] = value
+	}
+
+	result := database.DB.Model(&models.Transcription{}).
+		Where("id = ? AND status IN ?", transcription.ID, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return errTranscriptionBusy
+	}
+
+	transcription.Status = models.StatusProcessing
+	transcription.ErrorMessage = ""
+	transcription.RequeuedAt = nil
+	services.Events.PublishTranscription(services.LiveTranscriptionProcessing, transcription)
+	return nil
+}
+
+// startTranscriptionError reports why a job couldn't be started
+func startTranscriptionError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errTranscriptionBusy) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "failed to start transcription",
+	})
+}
+
+// runningTranscriptions holds the cancel functions of the jobs this process
+// is running, so CancelTranscription can stop them
+var runningTranscriptions = struct {
+	sync.Mutex
+	cancels map[uuid.UUID]context.CancelFunc
+}{cancels: make(map[uuid.UUID]context.CancelFunc)}
+
+var (
+	errTranscriptionCancelled      = errors.New("transcription cancelled")
+	errTranscriptionNotCancellable = errors.New("transcription can't be cancelled")
+	errTranscriptNotEditable       = errors.New("transcription not completed")
+)
+
+func processTranscriptionAsync(transcriptionID uuid.UUID) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runningTranscriptions.Lock()
+	runningTranscriptions.cancels[transcriptionID] = cancel
+	runningTranscriptions.Unlock()
+
+	defer func() {
+		runningTranscriptions.Lock()
+		delete(runningTranscriptions.cancels, transcriptionID)
+		runningTranscriptions.Unlock()
+		cancel()
+	}()
+
+	var transcription models.Transcription
+	if err := database.DB.First(&transcription, transcriptionID).Error; err != nil {
 		return
 	}
+	if transcription.Status != models.StatusProcessing {
+		return // cancelled before the worker started
+	}
+
+	// Jobs are charged to the account that owns them, so a member's job in
+	// an organization draws from the shared pool
+	account := services.AccountFor(transcription.UserID, transcription.OrganizationID)
 
 	aaiService := services.NewAssemblyAIService()
 
-	result, err := aaiService.CreateTranscription(ctx, transcription.FileURL, transcription.Language)
+	result, err := aaiService.CreateTranscription(ctx, transcription.FileURL, services.TranscriptionOptions{
+		Language:      transcription.Language,
+		SpeakerLabels: transcription.SpeakerLabels,
+	})
 	if err != nil {
-		transcription.Status = models.StatusFailed
-		transcription.ErrorMessage = err.Error()
-		database.DB.Save(&transcription)
+		failTranscription(&transcription, err.Error())
 		return
 	}
 
+	// Update only the provider ID, and only while the job is still running,
+	// so a concurrent cancel isn't overwritten
 	transcription.AssemblyAIID = result.ID
-	database.DB.Save(&transcription)
+	database.DB.Model(&models.Transcription{}).
+		Where("id = ? AND status = ?", transcription.ID, models.StatusProcessing).
+		Update("assembly_ai_id", result.ID)
+	if ctx.Err() != nil {
+		// Cancelled while submitting; the provider ID wasn't known to the
+		// cancel request, so clean up here
+		go deleteProviderTranscript(result.ID)
+		return
+	}
 
-	result, err = aaiService.WaitForCompletion(ctx, result.ID, 30*time.Minute)
+	result, err = aaiService.WaitForCompletion(ctx, result.ID, 30*time.Minute, func(status string) {
+		switch status {
+		case "queued":
+			services.Events.PublishTranscription(services.LiveTranscriptionQueued, &transcription)
+		case "processing":
+			services.Events.PublishTranscription(services.LiveTranscriptionProcessing, &transcription)
+		}
+	})
 	if err != nil {
-		transcription.Status = models.StatusFailed
-		transcription.ErrorMessage = err.Error()
-		database.DB.Save(&transcription)
+		if ctx.Err() != nil {
+			return // cancelled, the row is already marked
+		}
+		failTranscription(&transcription, err.Error())
 		return
 	}
 
@@ -92,13 +365,6 @@ func processTranscriptionAsync(transcriptionID, userID uuid.UUID) {
 		durationMinutes = 1
 	}
 
-	if !user.HasCredits(durationMinutes) {
-		transcription.Status = models.StatusFailed
-		transcription.ErrorMessage = "insufficient credits"
-		database.DB.Save(&transcription)
-		return
-	}
-
 	srtContent, err := aaiService.GetSRT(ctx, result.ID)
 	if err != nil {
 		srtContent = ""
@@ -110,28 +376,261 @@ func processTranscriptionAsync(transcriptionID, userID uuid.UUID) {
 	}
 
 	now := time.Now()
+	previousCredits := transcription.CreditsUsed
+	before := transcription
 	transcription.Status = models.StatusCompleted
 	transcription.TranscriptText = &result.Text
 	transcription.SRTContent = &srtContent
 	transcription.VTTContent = &vttContent
 	transcription.Duration = result.Duration / 1000 // Convert to seconds
-	transcription.CreditsUsed = durationMinutes
+	transcription.CreditsUsed = previousCredits + durationMinutes
 	transcription.CompletedAt = &now
-	database.DB.Save(&transcription)
 
-	user.DeductCredits(durationMinutes)
-	database.DB.Save(&user)
+	var debits []models.CreditTransaction
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the row so a cancel either lands before the charge or fails
+		var current models.Transcription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("status").Where("id = ?", transcription.ID).First(&current).Error; err != nil {
+			return err
+		}
+		if current.Status != models.StatusProcessing {
+			return errTranscriptionCancelled
+		}
+
+		reason := models.VersionReasonTranscription
+		if previousCredits > 0 {
+			reason = models.VersionReasonReprocess
+		}
+		if _, err := services.RecordTranscriptVersion(tx, &transcription, reason, nil, nil); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Transcription: %s", transcription.FileName)
+		if transcription.Version > 1 {
+			description = fmt.Sprintf("%s (v%d)", description, transcription.Version)
+		}
+		debits, err = services.ConsumeCredits(tx, account, durationMinutes, &transcription.ID, description)
+		if err != nil {
+			return err
+		}
+		// Only the result columns: the row was loaded when the job started,
+		// and it may have been moved, renamed or trashed since
+		return tx.Model(&transcription).Updates(map[string]interface{}{
+			"status":          transcription.Status,
+			"transcript_text": transcription.TranscriptText,
+			"srt_content":     transcription.SRTContent,
+			"vtt_content":     transcription.VTTContent,
+			"duration":        transcription.Duration,
+			"credits_used":    transcription.CreditsUsed,
+			"version":         transcription.Version,
+			"completed_at":    transcription.CompletedAt,
+			"assembly_ai_id":  transcription.AssemblyAIID,
+		}).Error
+	})
+	if errors.Is(err, errTranscriptionCancelled) {
+		return
+	}
+	if err != nil {
+		// Keep the length the provider measured, so a retry can check the
+		// balance before running again
+		if before.TranscriptText == nil {
+			before.Duration = transcription.Duration
+		}
+		failTranscription(&before, err.Error())
+		return
+	}
+
+	notifyTranscription(&transcription, models.EventTranscriptionCompleted)
+	if len(debits) > 0 {
+		notifyCreditsDebited(account, debits[0].BalanceBefore, debits[len(debits)-1].BalanceAfter)
+	}
+}
+
+// failTranscription marks a processing job failed and notifies the owner.
+// A failed reprocess goes back to completed with its current version, so
+// the transcript already paid for stays usable; a failed first run keeps
+// its duration when it is known. Jobs cancelled in the meantime are left
+// alone.
+func failTranscription(transcription *models.Transcription, message string) {
+	updates := map[string]interface{}{
+		"status":        models.StatusFailed,
+		"error_message": message,
+	}
+	if transcription.TranscriptText != nil {
+		for column, value := range reprocessRollback(database.DB, transcription) {
+			updates[column] = value
+		}
+	} else if transcription.Duration > 0 {
+		updates["duration"] = transcription.Duration
+	}
+
+	result := database.DB.Model(&models.Transcription{}).
+		Where("id = ? AND status = ?", transcription.ID, models.StatusProcessing).
+		Updates(updates)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	transcription.Status = updates["status"].(models.TranscriptionStatus)
+	transcription.ErrorMessage = message
+	notifyTranscription(transcription, models.EventTranscriptionFailed)
+}
+
+// reprocessRollback returns the update that puts a job whose reprocess
+// didn't finish back to its current version: completed, with that version's
+// options. The transcript itself was never replaced.
+func reprocessRollback(db *gorm.DB, transcription *models.Transcription) map[string]interface{} {
+	updates := map[string]interface{}{
+		"status": models.StatusCompleted,
+	}
+
+	var current models.TranscriptVersion
+	if err := db.Where("transcription_id = ? AND version = ?", transcription.ID, transcription.Version).First(&current).Error; err == nil {
+		updates["language"] = current.Language
+		updates["speaker_labels"] = current.SpeakerLabels
+		updates["assembly_ai_id"] = current.AssemblyAIID
+		transcription.Language = current.Language
+		transcription.SpeakerLabels = current.SpeakerLabels
+		transcription.AssemblyAIID = current.AssemblyAIID
+	}
+	return updates
+}
+
+// CancelTranscription stops a pending or processing job. The worker's
+// context is cancelled and the provider transcript deleted. Credits are only
+// charged when a job completes, so a cancelled job costs nothing; if the job
+// finished before the cancel got through, the request fails instead.
+func CancelTranscription(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	transcriptionID := c.Params("id")
+	tid, err := uuid.Parse(transcriptionID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid transcription ID",
+		})
+	}
+
+	var transcription models.Transcription
+	var cancelledProviderID string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(middleware.GetAccount(c).Scope).Where("id = ?", tid).First(&transcription).Error; err != nil {
+			return err
+		}
+		if transcription.Status != models.StatusProcessing && transcription.Status != models.StatusPending {
+			return errTranscriptionNotCancellable
+		}
+
+		// A cancelled reprocess keeps the current version
+		if transcription.TranscriptText != nil {
+			providerID := transcription.AssemblyAIID
+			updates := reprocessRollback(tx, &transcription)
+			updates["error_message"] = ""
+			transcription.Status = models.StatusCompleted
+			transcription.ErrorMessage = ""
+			if err := tx.Model(&transcription).Updates(updates).Error; err != nil {
+				return err
+			}
+			// Only the new run's provider transcript is deleted, once it
+			// has one
+			if providerID != transcription.AssemblyAIID {
+				cancelledProviderID = providerID
+			}
+			return nil
+		}
 
-	transaction := models.CreditTransaction{
-		UserID:          user.ID,
-		TranscriptionID: &transcription.ID,
-		Type:            models.TransactionDebit,
-		Amount:          durationMinutes,
-		BalanceBefore:   user.CreditsRemaining + durationMinutes,
-		BalanceAfter:    user.CreditsRemaining,
-		Description:     fmt.Sprintf("Transcription: %s", transcription.FileName),
+		now := time.Now()
+		transcription.Status = models.StatusCancelled
+		transcription.CancelledAt = &now
+		transcription.ErrorMessage = ""
+		cancelledProviderID = transcription.AssemblyAIID
+		return tx.Save(&transcription).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcription not found",
+		})
+	}
+	if errors.Is(err, errTranscriptionNotCancellable) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("transcription already %s", transcription.Status),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to cancel transcription",
+		})
+	}
+
+	runningTranscriptions.Lock()
+	if cancel, ok := runningTranscriptions.cancels[transcription.ID]; ok {
+		cancel()
+	}
+	runningTranscriptions.Unlock()
+
+	if cancelledProviderID != "" {
+		go deleteProviderTranscript(cancelledProviderID)
+	}
+
+	notifyTranscription(&transcription, models.EventTranscriptionCancelled)
+
+	return c.JSON(fiber.Map{
+		"message":       "transcription cancelled",
+		"transcription": transcription,
+	})
+}
+
+// deleteProviderTranscript asks AssemblyAI to drop a transcript we no longer want
+func deleteProviderTranscript(assemblyAIID string) {
+	if err := services.NewAssemblyAIService().DeleteTranscription(context.Background(), assemblyAIID); err != nil {
+		log.Printf("Failed to delete AssemblyAI transcript %s: %v", assemblyAIID, err)
 	}
-	database.DB.Create(&transaction)
+}
+
+// notifyTranscription sends a transcription.* event to the owner's live
+// stream and to the webhooks of the user who created the job, who is also
+// emailed when the job completes or fails
+func notifyTranscription(transcription *models.Transcription, event string) {
+	services.Events.PublishTranscription(event, transcription)
+
+	if err := services.EnqueueWebhookEvent(database.DB, transcription.UserID, event, services.TranscriptionEventData(transcription)); err != nil {
+		log.Printf("Failed to enqueue %s webhook for %s: %v", event, transcription.ID, err)
+	}
+
+	if event == models.EventTranscriptionCompleted || event == models.EventTranscriptionFailed {
+		services.NotifyTranscriptionFinished(database.DB, transcription, event == models.EventTranscriptionFailed)
+	}
+}
+
+// notifyCreditsDebited publishes the new balance and sends credits.low when a
+// debit takes it below the configured threshold. The webhook and email fire
+// once per crossing, not on every debit. The webhook goes to the user whose
+// job made it.
+func notifyCreditsDebited(account services.Account, before, after int) {
+	services.Events.PublishCredits(account, after)
+
+	threshold := appconfig.AppConfig.LowCreditsThreshold
+	if before < threshold || after >= threshold {
+		return
+	}
+
+	data := map[string]interface{}{
+		"credits_remaining": after,
+		"threshold":         threshold,
+	}
+	if account.OrganizationID != nil {
+		data["organization_id"] = *account.OrganizationID
+	}
+	if err := services.EnqueueWebhookEvent(database.DB, account.UserID, models.EventCreditsLow, data); err != nil {
+		log.Printf("Failed to enqueue credits.low webhook for %s: %v", account.UserID, err)
+	}
+
+	services.NotifyCreditsLow(database.DB, account, after, threshold)
 }
 
 func GetTranscription(c *fiber.Ctx) error {
@@ -151,11 +650,12 @@ func GetTranscription(c *fiber.Ctx) error {
 	}
 
 	var transcription models.Transcription
-	if err := database.DB.Where("id = ? AND user_id = ?", tid, user.ID).First(&transcription).Error; err != nil {
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("tag") }).Preload("Media").Where("id = ?", tid).First(&transcription).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "transcription not found",
 		})
 	}
+	setMediaURLs(&transcription)
 
 	return c.JSON(transcription)
 }
@@ -179,7 +679,7 @@ func DownloadTranscription(c *fiber.Ctx) error {
 	format := c.Query("format", "txt")
 
 	var transcription models.Transcription
-	if err := database.DB.Where("id = ? AND user_id = ?", tid, user.ID).First(&transcription).Error; err != nil {
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", tid).First(&transcription).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "transcription not found",
 		})
@@ -191,32 +691,7 @@ func DownloadTranscription(c *fiber.Ctx) error {
 		})
 	}
 
-	var content string
-	var contentType string
-	var filename string
-
-	switch format {
-	case "srt":
-		if transcription.SRTContent != nil {
-			content = *transcription.SRTContent
-		}
-		contentType = "application/x-subrip"
-		filename = fmt.Sprintf("%s.srt", transcription.FileName)
-	case "vtt":
-		if transcription.VTTContent != nil {
-			content = *transcription.VTTContent
-		}
-		contentType = "text/vtt"
-		filename = fmt.Sprintf("%s.vtt", transcription.FileName)
-	case "txt":
-		fallthrough
-	default:
-		if transcription.TranscriptText != nil {
-			content = *transcription.TranscriptText
-		}
-		contentType = "text/plain"
-		filename = fmt.Sprintf("%s.txt", transcription.FileName)
-	}
+	content, contentType, filename := exportTranscript(&transcription, format)
 
 	c.Set("Content-Type", contentType)
 	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
@@ -224,6 +699,7 @@ func DownloadTranscription(c *fiber.Ctx) error {
 	return c.SendString(content)
 }
 
+// UpdateTranscription saves an edited transcript as a new version
 func UpdateTranscription(c *fiber.Ctx) error {
 	user := middleware.GetUser(c)
 	if user == nil {
@@ -241,7 +717,7 @@ func UpdateTranscription(c *fiber.Ctx) error {
 	}
 
 	var transcription models.Transcription
-	if err := database.DB.Where("id = ? AND user_id = ?", tid, user.ID).First(&transcription).Error; err != nil {
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", tid).First(&transcription).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "transcription not found",
 		})
@@ -258,8 +734,26 @@ func UpdateTranscription(c *fiber.Ctx) error {
 		})
 	}
 
-	transcription.TranscriptText = &req.TranscriptText
-	if err := database.DB.Save(&transcription).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transcription, "id = ?", transcription.ID).Error; err != nil {
+			return err
+		}
+		if transcription.Status != models.StatusCompleted {
+			return errTranscriptNotEditable
+		}
+
+		transcription.TranscriptText = &req.TranscriptText
+		if _, err := services.RecordTranscriptVersion(tx, &transcription, models.VersionReasonEdit, user, nil); err != nil {
+			return err
+		}
+		return tx.Save(&transcription).Error
+	})
+	if errors.Is(err, errTranscriptNotEditable) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "transcription not completed",
+		})
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to update transcription",
 		})
@@ -268,6 +762,8 @@ func UpdateTranscription(c *fiber.Ctx) error {
 	return c.JSON(transcription)
 }
 
+// DeleteTranscription moves a transcription to the trash, where it can be
+// restored until it is purged
 func DeleteTranscription(c *fiber.Ctx) error {
 	user := middleware.GetUser(c)
 	if user == nil {
@@ -285,34 +781,61 @@ func DeleteTranscription(c *fiber.Ctx) error {
 	}
 
 	var transcription models.Transcription
-	if err := database.DB.Where("id = ? AND user_id = ?", tid, user.ID).First(&transcription).Error; err != nil {
+	if err := database.DB.Scopes(middleware.GetAccount(c).Scope).Where("id = ?", tid).First(&transcription).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "transcription not found",
 		})
 	}
 
-	if err := database.DB.Delete(&transcription).Error; err != nil {
+	// A running job would finish into a trashed row
+	if transcription.Status == models.StatusProcessing {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "cancel the transcription before deleting it",
+		})
+	}
+
+	if err := trashTranscription(&transcription); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to delete transcription",
 		})
 	}
 
-	go func() {
-		ctx := context.Background()
-		storageService, err := services.NewStorageService(ctx)
-		if err != nil {
-			fmt.Printf("Failed to initialize storage service: %v\n", err)
-			return
-		}
+	return c.JSON(fiber.Map{
+		"message":  "transcription moved to trash",
+		"purge_at": services.TrashPurgeAt(&transcription),
+	})
+}
 
-		filePath := storageService.ExtractFilePathFromURL(transcription.FileURL)
+// trashTranscription moves a transcription to the trash. It and its media
+// are deleted for good when the trash is emptied or after TrashRetention.
+func trashTranscription(transcription *models.Transcription) error {
+	if err := database.DB.Delete(transcription).Error; err != nil {
+		return err
+	}
+	transcription.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	return nil
+}
 
-		if err := storageService.DeleteFile(ctx, filePath); err != nil {
-			fmt.Printf("Failed to delete file from storage: %v\n", err)
-		}
-	}()
+// exportTranscript renders a transcript in txt, srt or vtt, returning the
+// content, its MIME type and a file name
+func exportTranscript(transcription *models.Transcription, format string) (string, string, string) {
+	var content string
 
-	return c.JSON(fiber.Map{
-		"message": "transcription deleted successfully",
-	})
+	switch format {
+	case "srt":
+		if transcription.SRTContent != nil {
+			content = *transcription.SRTContent
+		}
+		return content, "application/x-subrip", fmt.Sprintf("%s.srt", transcription.FileName)
+	case "vtt":
+		if transcription.VTTContent != nil {
+			content = *transcription.VTTContent
+		}
+		return content, "text/vtt", fmt.Sprintf("%s.vtt", transcription.FileName)
+	default:
+		if transcription.TranscriptText != nil {
+			content = *transcription.TranscriptText
+		}
+		return content, "text/plain", fmt.Sprintf("%s.txt", transcription.FileName)
+	}
 }
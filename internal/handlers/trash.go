@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+)
+
+// trashedTranscription is a trash entry with the date it will be purged
+type trashedTranscription struct {
+	models.Transcription
+	PurgeAt *time.Time `json:"purge_at"`
+}
+
+// GetTrash lists the account's trashed transcriptions, most recently deleted
+// first
+func GetTrash(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 10)
+	offset := (page - 1) * limit
+
+	query := database.DB.Unscoped().Model(&models.Transcription{}).
+		Scopes(middleware.GetAccount(c).Scope).
+		Where("deleted_at IS NOT NULL")
+
+	var total int64
+	query.Count(&total)
+
+	var transcriptions []models.Transcription
+	if err := query.
+		Omit("transcript_json").
+		Order("deleted_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&transcriptions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch trash",
+		})
+	}
+
+	entries := make([]trashedTranscription, len(transcriptions))
+	for i := range transcriptions {
+		entries[i] = trashedTranscription{transcriptions[i], services.TrashPurgeAt(&transcriptions[i])}
+	}
+
+	return c.JSON(fiber.Map{
+		"transcriptions": entries,
+		"pagination": fiber.Map{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// RestoreTranscription takes a transcription out of the trash
+func RestoreTranscription(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	tid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid transcription ID",
+		})
+	}
+
+	var transcription models.Transcription
+	if err := database.DB.Unscoped().Scopes(middleware.GetAccount(c).Scope).
+		Where("id = ? AND deleted_at IS NOT NULL", tid).
+		First(&transcription).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcription not found in trash",
+		})
+	}
+
+	if err := database.DB.Unscoped().Model(&transcription).Update("deleted_at", nil).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to restore transcription",
+		})
+	}
+	transcription.DeletedAt.Valid = false
+
+	return c.JSON(fiber.Map{
+		"message":       "transcription restored",
+		"transcription": transcription,
+	})
+}
+
+// EmptyTrash permanently deletes every trashed transcription of the account
+// with its versions, share links and media. It can't be undone.
+func EmptyTrash(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var transcriptions []models.Transcription
+	if err := database.DB.Unscoped().Scopes(middleware.GetAccount(c).Scope).
+		Where("deleted_at IS NOT NULL").
+		Omit("transcript_text", "transcript_json", "srt_content", "vtt_content").
+		Find(&transcriptions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch trash",
+		})
+	}
+
+	storage, err := services.NewStorageService(context.Background())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to initialize storage",
+		})
+	}
+
+	deleted := []uuid.UUID{}
+	for i := range transcriptions {
+		deletion, err := services.PurgeTranscription(database.DB, storage, &transcriptions[i])
+		if err != nil {
+			log.Printf("Failed to purge transcription %s: %v", transcriptions[i].ID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "failed to empty trash",
+				"deleted": deleted,
+			})
+		}
+		if deletion != nil {
+			services.DeleteMediaInBackground(database.DB, deletion.ID)
+		}
+		deleted = append(deleted, transcriptions[i].ID)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "trash emptied",
+		"deleted": deleted,
+	})
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,6 +16,8 @@ import (
 	"github.com/matills/litwick/internal/services"
 )
 
+const maxMediaSize = int64(500 * 1024 * 1024) // 500MB
+
 var allowedExtensions = map[string]bool{
 	".mp3":  true,
 	".mp4":  true,
@@ -52,8 +57,7 @@ func UploadFile(c *fiber.Ctx) error {
 	}
 
 	// Validate file size (max 500MB)
-	maxSize := int64(500 * 1024 * 1024) // 500MB
-	if file.Size > maxSize {
+	if file.Size > maxMediaSize {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "file too large (max 500MB)",
 		})
@@ -91,13 +95,14 @@ func UploadFile(c *fiber.Ctx) error {
 
 	// Create transcription record
 	transcription := models.Transcription{
-		ID:       uuid.New(),
-		UserID:   user.ID,
-		FileName: file.Filename,
-		FileURL:  fileURL,
-		FileSize: file.Size,
-		Status:   models.StatusProcessing,
-		Language: language,
+		ID:             uuid.New(),
+		UserID:         user.ID,
+		OrganizationID: middleware.GetAccount(c).OrganizationID,
+		FileName:       file.Filename,
+		FileURL:        fileURL,
+		FileSize:       file.Size,
+		Status:         models.StatusProcessing,
+		Language:       language,
 	}
 
 	if err := database.DB.Create(&transcription).Error; err != nil {
@@ -106,11 +111,296 @@ func UploadFile(c *fiber.Ctx) error {
 		})
 	}
 
+	services.Events.PublishTranscription(services.LiveTranscriptionUploaded, &transcription)
+
 	// Start transcription process in background
-	go processTranscriptionAsync(transcription.ID, user.ID)
+	go processTranscriptionAsync(transcription.ID)
+	services.AnalyzeMediaInBackground(database.DB, transcription.ID)
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message":       "file uploaded successfully, transcription started",
 		"transcription": transcription,
 	})
 }
+
+// CreateTranscriptionFromURL queues a job for media hosted elsewhere. The URL
+// is checked against SSRF rules and probed for type and size; with
+// copy_to_storage the file is downloaded into our storage first, otherwise
+// AssemblyAI fetches it directly.
+func CreateTranscriptionFromURL(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	type CreateFromURLRequest struct {
+		SourceURL     string `json:"source_url"`
+		FileName      string `json:"file_name"`
+		Language      string `json:"language"`
+		CopyToStorage bool   `json:"copy_to_storage"`
+	}
+
+	var req CreateFromURLRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	if strings.TrimSpace(req.SourceURL) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "source_url is required",
+		})
+	}
+
+	sourceURL, err := services.ValidateRemoteURL(c.Context(), req.SourceURL)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	media, err := services.ProbeRemoteMedia(c.Context(), sourceURL, maxMediaSize)
+	if err != nil {
+		if errors.Is(err, services.ErrRemoteMediaTooBig) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "file too large (max 500MB)",
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	fileName := strings.TrimSpace(req.FileName)
+	if fileName == "" {
+		fileName = media.FileName
+	}
+
+	// Generic binary responses are only accepted with a known media extension
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if strings.HasPrefix(media.ContentType, "application/octet-stream") && !allowedExtensions[ext] {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("unsupported file type: %s", ext),
+		})
+	}
+
+	language := req.Language
+	if language == "" {
+		language = "es"
+	}
+
+	submitted := sourceURL.String()
+	transcription := models.Transcription{
+		ID:             uuid.New(),
+		UserID:         user.ID,
+		OrganizationID: middleware.GetAccount(c).OrganizationID,
+		FileName:       fileName,
+		FileURL:        media.URL,
+		SourceURL:      &submitted,
+		Status:         models.StatusProcessing,
+		Language:       language,
+	}
+	if media.Size > 0 {
+		transcription.FileSize = media.Size
+	}
+
+	if err := database.DB.Create(&transcription).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create transcription record",
+		})
+	}
+
+	services.Events.PublishTranscription(services.LiveTranscriptionUploaded, &transcription)
+
+	if req.CopyToStorage {
+		go copyRemoteMediaAsync(transcription, media)
+	} else {
+		go processTranscriptionAsync(transcription.ID)
+	}
+
+	response := fiber.Map{
+		"message":       "transcription queued",
+		"transcription": transcription,
+		"media_stored":  req.CopyToStorage,
+	}
+	if !req.CopyToStorage {
+		response["notice"] = uncopiedMediaNotice
+	}
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// uncopiedMediaNotice tells API clients what they give up by transcribing
+// remote media in place
+const uncopiedMediaNotice = "the media stays at source_url: it is not analyzed, streamed on share links or removed by retention; send copy_to_storage to keep a copy"
+
+// copyRemoteMediaAsync downloads remote media into storage, then starts the job
+func copyRemoteMediaAsync(transcription models.Transcription, media *services.RemoteMedia) {
+	ctx := context.Background()
+
+	storageService, err := services.NewStorageService(ctx)
+	if err != nil {
+		failTranscription(&transcription, "failed to initialize storage")
+		return
+	}
+
+	fileURL, err := services.CopyRemoteMedia(ctx, storageService, media, maxMediaSize)
+	if err != nil {
+		failTranscription(&transcription, err.Error())
+		return
+	}
+
+	transcription.FileURL = fileURL
+	if err := database.DB.Model(&transcription).Update("file_url", fileURL).Error; err != nil {
+		failTranscription(&transcription, "failed to save copied file")
+		return
+	}
+
+	services.AnalyzeMediaInBackground(database.DB, transcription.ID)
+	processTranscriptionAsync(transcription.ID)
+}
+
+const maxBatchFiles = 50
+
+// UploadBatch uploads several files as one batch. Every job shares the
+// batch's options (language and speaker labels) and starts right away; files
+// that fail to upload are reported without aborting the rest.
+func UploadBatch(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["files"]) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "no files uploaded",
+		})
+	}
+
+	files := form.File["files"]
+	if len(files) > maxBatchFiles {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("too many files (max %d per batch)", maxBatchFiles),
+		})
+	}
+
+	// Validate everything before uploading anything
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file.Filename))
+		if !allowedExtensions[ext] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("unsupported file type: %s (%s)", ext, file.Filename),
+			})
+		}
+		if file.Size > maxMediaSize {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("file too large (max 500MB): %s", file.Filename),
+			})
+		}
+	}
+
+	// Options are parsed once here, stored on the batch and copied onto
+	// every job in it
+	language := c.FormValue("language")
+	if language == "" {
+		language = "es"
+	}
+	speakerLabels := false
+	if value := c.FormValue("speaker_labels"); value != "" {
+		if speakerLabels, err = strconv.ParseBool(value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid speaker_labels",
+			})
+		}
+	}
+
+	storageService, err := services.NewStorageService(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to initialize storage",
+		})
+	}
+
+	batch := models.TranscriptionBatch{
+		UserID:         user.ID,
+		OrganizationID: middleware.GetAccount(c).OrganizationID,
+		Name:           strings.TrimSpace(c.FormValue("name")),
+		Language:       language,
+		SpeakerLabels:  speakerLabels,
+	}
+	if err := database.DB.Create(&batch).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create batch",
+		})
+	}
+
+	type fileError struct {
+		FileName string `json:"file_name"`
+		Error    string `json:"error"`
+	}
+
+	transcriptions := []models.Transcription{}
+	failed := []fileError{}
+
+	for _, file := range files {
+		fileReader, err := file.Open()
+		if err != nil {
+			failed = append(failed, fileError{file.Filename, "failed to read file"})
+			continue
+		}
+
+		fileURL, err := storageService.UploadFile(c.Context(), fileReader, file.Filename, file.Header.Get("Content-Type"))
+		fileReader.Close()
+		if err != nil {
+			failed = append(failed, fileError{file.Filename, "failed to upload file"})
+			continue
+		}
+
+		transcription := models.Transcription{
+			ID:             uuid.New(),
+			UserID:         user.ID,
+			OrganizationID: batch.OrganizationID,
+			BatchID:        &batch.ID,
+			FileName:       file.Filename,
+			FileURL:        fileURL,
+			FileSize:       file.Size,
+			Status:         models.StatusProcessing,
+			Language:       batch.Language,
+			SpeakerLabels:  batch.SpeakerLabels,
+		}
+		if err := database.DB.Create(&transcription).Error; err != nil {
+			failed = append(failed, fileError{file.Filename, "failed to create transcription record"})
+			continue
+		}
+
+		transcriptions = append(transcriptions, transcription)
+	}
+
+	if len(transcriptions) == 0 {
+		database.DB.Delete(&batch)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":  "failed to upload files",
+			"failed": failed,
+		})
+	}
+
+	batch.TotalFiles = len(transcriptions)
+	database.DB.Model(&batch).Update("total_files", batch.TotalFiles)
+
+	for i := range transcriptions {
+		services.Events.PublishTranscription(services.LiveTranscriptionUploaded, &transcriptions[i])
+		go processTranscriptionAsync(transcriptions[i].ID)
+		services.AnalyzeMediaInBackground(database.DB, transcriptions[i].ID)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"batch":          batch,
+		"transcriptions": transcriptions,
+		"failed":         failed,
+	})
+}
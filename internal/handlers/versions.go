@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// versionSummaryFields are returned when listing, leaving the content out
+var versionSummaryFields = []string{
+	"id", "transcription_id", "version", "reason", "author_id", "author_email",
+	"restored_from", "language", "speaker_labels", "duration", "completed_at", "created_at",
+}
+
+// findAccountTranscription loads the transcription in the :id param if it
+// belongs to the account
+func findAccountTranscription(c *fiber.Ctx, account services.Account) (*models.Transcription, error) {
+	tid, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid transcription ID",
+		})
+	}
+
+	var transcription models.Transcription
+	if err := database.DB.Scopes(account.Scope).Where("id = ?", tid).First(&transcription).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "transcription not found",
+		})
+	}
+	return &transcription, nil
+}
+
+func findTranscriptVersion(c *fiber.Ctx, transcriptionID uuid.UUID, param string) (*models.TranscriptVersion, error) {
+	number, err := strconv.Atoi(c.Params(param, c.Query(param)))
+	if err != nil || number < 1 {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid version number",
+		})
+	}
+
+	var version models.TranscriptVersion
+	if err := database.DB.Where("transcription_id = ? AND version = ?", transcriptionID, number).First(&version).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "version not found",
+		})
+	}
+	return &version, nil
+}
+
+// GetTranscriptVersions lists a transcript's versions, newest first, without
+// their content
+func GetTranscriptVersions(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	transcription, err := findAccountTranscription(c, middleware.GetAccount(c))
+	if transcription == nil {
+		return err
+	}
+
+	var versions []models.TranscriptVersion
+	if err := database.DB.Select(versionSummaryFields).
+		Where("transcription_id = ?", transcription.ID).
+		Order("version DESC").
+		Find(&versions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch versions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"current_version": transcription.Version,
+		"versions":        versions,
+	})
+}
+
+// GetTranscriptVersion returns one version with its content
+func GetTranscriptVersion(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	transcription, err := findAccountTranscription(c, middleware.GetAccount(c))
+	if transcription == nil {
+		return err
+	}
+
+	version, err := findTranscriptVersion(c, transcription.ID, "version")
+	if version == nil {
+		return err
+	}
+
+	return c.JSON(version)
+}
+
+// DiffTranscriptVersions returns a word-level diff between two versions
+// (?from=1&to=3)
+func DiffTranscriptVersions(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	transcription, err := findAccountTranscription(c, middleware.GetAccount(c))
+	if transcription == nil {
+		return err
+	}
+
+	from, err := findTranscriptVersion(c, transcription.ID, "from")
+	if from == nil {
+		return err
+	}
+	to, err := findTranscriptVersion(c, transcription.ID, "to")
+	if to == nil {
+		return err
+	}
+
+	var fromText, toText string
+	if from.TranscriptText != nil {
+		fromText = *from.TranscriptText
+	}
+	if to.TranscriptText != nil {
+		toText = *to.TranscriptText
+	}
+
+	changes := services.DiffWords(fromText, toText)
+
+	inserted, deleted := 0, 0
+	for _, change := range changes {
+		switch change.Op {
+		case services.DiffInsert:
+			inserted++
+		case services.DiffDelete:
+			deleted++
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"from":     from.Version,
+		"to":       to.Version,
+		"changes":  changes,
+		"inserted": inserted,
+		"deleted":  deleted,
+	})
+}
+
+// RestoreTranscriptVersion makes an older version current again. The restore
+// is recorded as a new version, so nothing in the history is lost.
+func RestoreTranscriptVersion(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	transcription, err := findAccountTranscription(c, middleware.GetAccount(c))
+	if transcription == nil {
+		return err
+	}
+
+	source, err := findTranscriptVersion(c, transcription.ID, "version")
+	if source == nil {
+		return err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(transcription, "id = ?", transcription.ID).Error; err != nil {
+			return err
+		}
+		if transcription.Status != models.StatusCompleted {
+			return errTranscriptNotEditable
+		}
+
+		transcription.TranscriptText = source.TranscriptText
+		transcription.SRTContent = source.SRTContent
+		transcription.VTTContent = source.VTTContent
+		transcription.Language = source.Language
+
+		if _, err := services.RecordTranscriptVersion(tx, transcription, models.VersionReasonRestore, user, &source.Version); err != nil {
+			return err
+		}
+		return tx.Save(transcription).Error
+	})
+	if errors.Is(err, errTranscriptNotEditable) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "transcription not completed",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to restore version",
+		})
+	}
+
+	return c.JSON(transcription)
+}
@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	appconfig "github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/middleware"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+	"gorm.io/gorm"
+)
+
+const maxWebhookEndpointsPerUser = 10
+
+// webhookEndpointResponse exposes events as a list; the secret is only set on
+// create and roll
+type webhookEndpointResponse struct {
+	models.WebhookEndpoint
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+func newWebhookEndpointResponse(endpoint models.WebhookEndpoint, secret string) webhookEndpointResponse {
+	return webhookEndpointResponse{WebhookEndpoint: endpoint, Events: endpoint.EventList(), Secret: secret}
+}
+
+// normalizeWebhookEvents validates and deduplicates subscribed events
+func normalizeWebhookEvents(events []string) (string, bool) {
+	seen := map[string]bool{}
+	var result []string
+	for _, event := range events {
+		event = strings.ToLower(strings.TrimSpace(event))
+		if !models.IsValidWebhookEvent(event) {
+			return "", false
+		}
+		if !seen[event] {
+			seen[event] = true
+			result = append(result, event)
+		}
+	}
+	return strings.Join(result, ","), len(result) > 0
+}
+
+// validateWebhookURL requires an absolute https URL (plain http is allowed
+// in development) whose host resolves only to public addresses, so endpoints
+// can't be pointed at internal services
+func validateWebhookURL(ctx context.Context, raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", errors.New("url must be an absolute https URL")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && appconfig.AppConfig.Environment == "development") {
+		return "", errors.New("url must be an absolute https URL")
+	}
+	if _, err := services.ValidateRemoteURL(ctx, raw); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func findUserWebhookEndpoint(c *fiber.Ctx, userID uuid.UUID) (*models.WebhookEndpoint, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid webhook ID",
+		})
+	}
+
+	var endpoint models.WebhookEndpoint
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&endpoint).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "webhook not found",
+		})
+	}
+	return &endpoint, nil
+}
+
+func findUserWebhookDelivery(c *fiber.Ctx, userID uuid.UUID) (*models.WebhookDelivery, error) {
+	id, err := uuid.Parse(c.Params("deliveryId"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid delivery ID",
+		})
+	}
+
+	var delivery models.WebhookDelivery
+	if err := database.DB.Preload("AttemptLog", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("id = ? AND user_id = ?", id, userID).First(&delivery).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "delivery not found",
+		})
+	}
+	return &delivery, nil
+}
+
+func ListWebhookEndpoints(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var endpoints []models.WebhookEndpoint
+	if err := database.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&endpoints).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch webhooks",
+		})
+	}
+
+	result := make([]webhookEndpointResponse, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		result = append(result, newWebhookEndpointResponse(endpoint, ""))
+	}
+
+	return c.JSON(fiber.Map{
+		"webhooks": result,
+		"events":   models.WebhookEvents,
+	})
+}
+
+// CreateWebhookEndpoint registers an endpoint. The signing secret is returned
+// only in this response.
+func CreateWebhookEndpoint(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	type CreateWebhookRequest struct {
+		URL         string   `json:"url"`
+		Description string   `json:"description"`
+		Events      []string `json:"events"`
+	}
+
+	var req CreateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	endpointURL, err := validateWebhookURL(c.Context(), req.URL)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	events, ok := normalizeWebhookEvents(req.Events)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "events must be one or more of: " + strings.Join(models.WebhookEvents, ", "),
+		})
+	}
+
+	var count int64
+	database.DB.Model(&models.WebhookEndpoint{}).Where("user_id = ?", user.ID).Count(&count)
+	if count >= maxWebhookEndpointsPerUser {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "too many webhooks, delete one first",
+		})
+	}
+
+	secret, err := services.GenerateWebhookSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to generate webhook secret",
+		})
+	}
+
+	endpoint := models.WebhookEndpoint{
+		UserID:      user.ID,
+		URL:         endpointURL,
+		Description: strings.TrimSpace(req.Description),
+		Events:      events,
+		Secret:      secret,
+		Active:      true,
+	}
+
+	if err := database.DB.Create(&endpoint).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to create webhook",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(newWebhookEndpointResponse(endpoint, secret))
+}
+
+// UpdateWebhookEndpoint changes the URL, description, events or active flag
+func UpdateWebhookEndpoint(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	endpoint, err := findUserWebhookEndpoint(c, user.ID)
+	if endpoint == nil {
+		return err
+	}
+
+	type UpdateWebhookRequest struct {
+		URL         *string  `json:"url"`
+		Description *string  `json:"description"`
+		Events      []string `json:"events"`
+		Active      *bool    `json:"active"`
+	}
+
+	var req UpdateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.URL != nil {
+		endpointURL, err := validateWebhookURL(c.Context(), *req.URL)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		endpoint.URL = endpointURL
+	}
+	if req.Description != nil {
+		endpoint.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Events != nil {
+		events, ok := normalizeWebhookEvents(req.Events)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "events must be one or more of: " + strings.Join(models.WebhookEvents, ", "),
+			})
+		}
+		endpoint.Events = events
+	}
+	if req.Active != nil {
+		endpoint.Active = *req.Active
+	}
+
+	if err := database.DB.Save(endpoint).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update webhook",
+		})
+	}
+
+	return c.JSON(newWebhookEndpointResponse(*endpoint, ""))
+}
+
+// RollWebhookSecret replaces an endpoint's signing secret. Deliveries sent
+// from now on, including pending retries, are signed with the new one.
+func RollWebhookSecret(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	endpoint, err := findUserWebhookEndpoint(c, user.ID)
+	if endpoint == nil {
+		return err
+	}
+
+	secret, err := services.GenerateWebhookSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to generate webhook secret",
+		})
+	}
+
+	endpoint.Secret = secret
+	if err := database.DB.Save(endpoint).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to roll webhook secret",
+		})
+	}
+
+	return c.JSON(newWebhookEndpointResponse(*endpoint, secret))
+}
+
+// DeleteWebhookEndpoint removes an endpoint together with its delivery log
+func DeleteWebhookEndpoint(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	endpoint, err := findUserWebhookEndpoint(c, user.ID)
+	if endpoint == nil {
+		return err
+	}
+
+	if err := database.DB.Delete(endpoint).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete webhook",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "webhook deleted",
+	})
+}
+
+// ListWebhookDeliveries returns an endpoint's delivery log, newest first
+func ListWebhookDeliveries(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	endpoint, err := findUserWebhookEndpoint(c, user.ID)
+	if endpoint == nil {
+		return err
+	}
+
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.WebhookDelivery{}).Where("endpoint_id = ?", endpoint.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&deliveries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch deliveries",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"deliveries": deliveries,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// GetWebhookDelivery returns one delivery with every attempt made for it
+func GetWebhookDelivery(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	endpoint, err := findUserWebhookEndpoint(c, user.ID)
+	if endpoint == nil {
+		return err
+	}
+
+	delivery, err := findUserWebhookDelivery(c, user.ID)
+	if delivery == nil {
+		return err
+	}
+	if delivery.EndpointID != endpoint.ID {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "delivery not found",
+		})
+	}
+
+	return c.JSON(delivery)
+}
+
+// RedeliverWebhookDelivery sends a delivery's payload again as a new delivery
+func RedeliverWebhookDelivery(c *fiber.Ctx) error {
+	user := middleware.GetUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	endpoint, err := findUserWebhookEndpoint(c, user.ID)
+	if endpoint == nil {
+		return err
+	}
+
+	delivery, err := findUserWebhookDelivery(c, user.ID)
+	if delivery == nil {
+		return err
+	}
+	if delivery.EndpointID != endpoint.ID {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "delivery not found",
+		})
+	}
+
+	if !endpoint.Active {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "webhook is disabled",
+		})
+	}
+
+	redelivery, err := services.RedeliverWebhook(database.DB, delivery)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to redeliver webhook",
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(redelivery)
+}
@@ -0,0 +1,19 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/services"
+)
+
+// ExpireCredits zeroes every credit lot past its expiry date
+func ExpireCredits(ctx context.Context) error {
+	expired, err := services.ExpireCreditLots(database.DB.WithContext(ctx), time.Now())
+	if expired > 0 {
+		log.Printf("Expired %d credit lots", expired)
+	}
+	return err
+}
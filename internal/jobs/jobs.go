@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/services"
+)
+
+// Start launches every scheduled background job. Jobs stop when ctx is cancelled.
+func Start(ctx context.Context) {
+	go Daily(ctx, "expire-credits", 3, ExpireCredits)
+	go Every(ctx, "deliver-webhooks", 30*time.Second, DeliverWebhooks)
+	go Every(ctx, "delete-media", time.Minute, DeleteMedia)
+	go Daily(ctx, "purge-trash", 5, PurgeTrash)
+
+	if services.MediaToolsAvailable() {
+		go Every(ctx, "analyze-media", 5*time.Minute, AnalyzeMedia)
+	} else {
+		log.Printf("ffmpeg or ffprobe not found, media analysis is disabled")
+	}
+
+	if len(config.AppConfig.MediaRetentionDays) > 0 {
+		go Daily(ctx, "apply-media-retention", 4, ApplyMediaRetention)
+	}
+
+	if interval := config.AppConfig.ReconcileIntervalMinutes; interval > 0 {
+		go Every(ctx, "reconcile-payments", time.Duration(interval)*time.Minute, ReconcilePayments)
+	}
+}
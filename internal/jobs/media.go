@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/services"
+)
+
+// DeleteMedia retries queued storage deletes that are due
+func DeleteMedia(ctx context.Context) error {
+	_, err := services.DeleteDueMedia(ctx, database.DB.WithContext(ctx))
+	return err
+}
+
+// ApplyMediaRetention deletes source media past its plan's retention period
+func ApplyMediaRetention(ctx context.Context) error {
+	purged, err := services.ApplyMediaRetention(ctx, database.DB.WithContext(ctx), config.AppConfig.MediaRetentionDays, time.Now())
+	if purged > 0 {
+		log.Printf("Purged the media of %d transcriptions past their retention period", purged)
+	}
+	return err
+}
+
+// PurgeTrash permanently deletes transcriptions whose trash period is over
+func PurgeTrash(ctx context.Context) error {
+	purged, err := services.PurgeExpiredTrash(ctx, database.DB.WithContext(ctx), time.Now())
+	if purged > 0 {
+		log.Printf("Purged %d transcriptions from the trash", purged)
+	}
+	return err
+}
+
+// AnalyzeMedia analyzes uploads that haven't been analyzed yet and retries
+// failed analyses
+func AnalyzeMedia(ctx context.Context) error {
+	analyzed, err := services.AnalyzePendingMedia(ctx, database.DB.WithContext(ctx))
+	if analyzed > 0 {
+		log.Printf("Analyzed the media of %d transcriptions", analyzed)
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/services"
+)
+
+// DefaultReconcileOptions are the windows used by the scheduled reconciler
+func DefaultReconcileOptions(trigger string) services.ReconcileOptions {
+	return services.ReconcileOptions{
+		PendingOlderThan: 15 * time.Minute,
+		RecentWindow:     24 * time.Hour,
+		AbandonAfter:     time.Duration(config.AppConfig.PaymentAbandonHours) * time.Hour,
+		Trigger:          trigger,
+	}
+}
+
+// ReconcilePayments settles payments whose webhook was missed
+func ReconcilePayments(ctx context.Context) error {
+	run, _, err := services.ReconcilePayments(ctx, database.DB.WithContext(ctx), services.NewMercadoPagoService(), DefaultReconcileOptions("scheduled"))
+	if err != nil {
+		return err
+	}
+
+	if run.Discrepancies > 0 || run.Errors > 0 {
+		log.Printf("Payment reconciliation %s: checked=%d settled=%d discrepancies=%d errors=%d",
+			run.ID, run.Checked, run.Settled, run.Discrepancies, run.Errors)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a unit of background work run by the scheduler
+type Job func(ctx context.Context) error
+
+// Every runs job on a fixed interval until ctx is cancelled
+func Every(ctx context.Context, name string, interval time.Duration, job Job) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run(ctx, name, job)
+		}
+	}
+}
+
+// Daily runs job once a day at the given hour (server local time) until ctx
+// is cancelled
+func Daily(ctx context.Context, name string, hour int, job Job) {
+	for {
+		timer := time.NewTimer(time.Until(nextDailyRun(time.Now(), hour)))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			run(ctx, name, job)
+		}
+	}
+}
+
+func nextDailyRun(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// run executes a single job, logging its outcome. A panicking job is
+// recovered so it can't take the server down.
+func run(ctx context.Context, name string, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v", name, r)
+		}
+	}()
+
+	start := time.Now()
+	if err := job(ctx); err != nil {
+		log.Printf("Job %s failed after %v: %v", name, time.Since(start), err)
+		return
+	}
+	log.Printf("Job %s finished in %v", name, time.Since(start))
+}
@@ -0,0 +1,14 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/services"
+)
+
+// DeliverWebhooks retries outbound webhook deliveries that are due
+func DeliverWebhooks(ctx context.Context) error {
+	_, err := services.DeliverDueWebhooks(ctx, database.DB.WithContext(ctx))
+	return err
+}
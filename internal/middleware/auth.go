@@ -2,16 +2,24 @@ package middleware
 
 import (
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/matills/litwick/internal/config"
 	"github.com/matills/litwick/internal/database"
 	"github.com/matills/litwick/internal/models"
 	"github.com/matills/litwick/internal/services"
+	"gorm.io/gorm"
 )
 
-// AuthMiddleware verifies Supabase JWT token and loads user
+// AuthMiddleware verifies Supabase JWT token and loads user. Personal API
+// keys are accepted too, as a Bearer token or in the X-API-Key header.
 func AuthMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if apiKey := c.Get("X-API-Key"); apiKey != "" {
+			return authenticateAPIKey(c, apiKey)
+		}
+
 		// Get Authorization header
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -28,6 +36,13 @@ func AuthMiddleware() fiber.Handler {
 			})
 		}
 
+		if services.IsAPIKey(token) {
+			return authenticateAPIKey(c, token)
+		}
+		if services.IsImpersonationToken(token) {
+			return authenticateImpersonation(c, token)
+		}
+
 		// Verify token with Supabase
 		supabaseUserID, email, err := services.VerifySupabaseToken(token)
 		if err != nil {
@@ -41,20 +56,46 @@ func AuthMiddleware() fiber.Handler {
 		result := database.DB.Where("supabase_user_id = ?", supabaseUserID).First(&user)
 
 		if result.Error != nil {
-			// User doesn't exist, create new user
+			// User doesn't exist, create new user with the free signup credits
 			user = models.User{
-				SupabaseUserID:  supabaseUserID,
-				Email:           email,
-				CreditsRemaining: 300, // 5 hours free
-				Plan:            "free",
+				SupabaseUserID: supabaseUserID,
+				Email:          email,
+				Plan:           "free",
 			}
-			if err := database.DB.Create(&user).Error; err != nil {
+			err := database.DB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+
+				grant := services.CreditGrant{
+					Amount:      models.FreeSignupCredits,
+					Source:      models.CreditSourceFreeGrant,
+					Description: "Créditos gratis de bienvenida",
+				}
+				if days := config.AppConfig.FreeCreditsExpiryDays; days > 0 {
+					expiresAt := time.Now().AddDate(0, 0, days)
+					grant.ExpiresAt = &expiresAt
+				}
+				if _, err := services.GrantCredits(tx, services.PersonalAccount(user.ID), grant); err != nil {
+					return err
+				}
+
+				return tx.First(&user, "id = ?", user.ID).Error
+			})
+			if err != nil {
 				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 					"error": "failed to create user",
 				})
 			}
 		}
 
+		// The token outlived an account deletion
+		if user.IsErased() {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "account deleted",
+			})
+		}
+
 		// Store user in context
 		c.Locals("user", user)
 		c.Locals("userID", user.ID.String())
@@ -63,6 +104,125 @@ func AuthMiddleware() fiber.Handler {
 	}
 }
 
+// authenticateAPIKey loads the user owning an active API key and records its use
+func authenticateAPIKey(c *fiber.Ctx, key string) error {
+	var apiKey models.APIKey
+	if err := database.DB.Preload("User").Where("key_hash = ?", services.HashAPIKey(key)).First(&apiKey).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid API key",
+		})
+	}
+
+	now := time.Now()
+	if !apiKey.IsActive(now) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "API key revoked or expired",
+		})
+	}
+
+	// The key outlived an account deletion
+	if apiKey.User.IsErased() {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "account deleted",
+		})
+	}
+
+	// Throttle last-used writes, scripts can make many calls per second
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > time.Minute {
+		database.DB.Model(&apiKey).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": c.IP(),
+		})
+	}
+
+	c.Locals("user", apiKey.User)
+	c.Locals("userID", apiKey.User.ID.String())
+	c.Locals("apiKey", &apiKey)
+
+	return c.Next()
+}
+
+// authenticateImpersonation loads the user an admin is impersonating. These
+// sessions are read-only: support can see what the user sees but not act
+// for them.
+func authenticateImpersonation(c *fiber.Ctx, token string) error {
+	var session models.ImpersonationSession
+	if err := database.DB.Preload("User").Preload("Admin").Where("token_hash = ?", services.HashAPIKey(token)).First(&session).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid impersonation token",
+		})
+	}
+
+	if !session.IsActive(time.Now()) || !session.Admin.IsAdmin() || session.User.IsErased() {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "impersonation session ended or expired",
+		})
+	}
+
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "impersonation sessions are read-only",
+		})
+	}
+
+	c.Locals("user", session.User)
+	c.Locals("userID", session.User.ID.String())
+	c.Locals("impersonation", &session)
+
+	return c.Next()
+}
+
+// GetImpersonation returns the impersonation session behind the request, or
+// nil when the user is acting for themselves
+func GetImpersonation(c *fiber.Ctx) *models.ImpersonationSession {
+	session, ok := c.Locals("impersonation").(*models.ImpersonationSession)
+	if !ok {
+		return nil
+	}
+	return session
+}
+
+// GetAPIKey returns the API key used to authenticate the request, or nil for
+// session (JWT) requests
+func GetAPIKey(c *fiber.Ctx) *models.APIKey {
+	key, ok := c.Locals("apiKey").(*models.APIKey)
+	if !ok {
+		return nil
+	}
+	return key
+}
+
+// RequireScope limits which API keys may call a route group. readScope is
+// required for GET and HEAD requests and writeScope for everything else; an
+// empty scope rejects API keys for those methods. Session requests always
+// pass. It must run after AuthMiddleware.
+func RequireScope(readScope, writeScope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		apiKey := GetAPIKey(c)
+		if apiKey == nil {
+			return c.Next()
+		}
+
+		scope := writeScope
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			scope = readScope
+		}
+
+		if scope == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "this endpoint requires a user session",
+			})
+		}
+		if !apiKey.HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "API key is missing the " + scope + " scope",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
 // GetUser retrieves the authenticated user from context
 func GetUser(c *fiber.Ctx) *models.User {
 	user, ok := c.Locals("user").(models.User)
@@ -71,3 +231,19 @@ func GetUser(c *fiber.Ctx) *models.User {
 	}
 	return &user
 }
+
+// AdminMiddleware rejects requests from users without the admin role, and
+// any request made with an API key or an impersonation session. It must run
+// after AuthMiddleware.
+func AdminMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := GetUser(c)
+		if user == nil || !user.IsAdmin() || GetAPIKey(c) != nil || GetImpersonation(c) != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "admin access required",
+			})
+		}
+
+		return c.Next()
+	}
+}
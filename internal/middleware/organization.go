@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/database"
+	"github.com/matills/litwick/internal/models"
+	"github.com/matills/litwick/internal/services"
+)
+
+// OrganizationMiddleware switches the request to an organization's account
+// when the X-Organization-ID header is set. The organization_id query
+// parameter is accepted too, for EventSource clients that can't set headers.
+// The user must be a member. It must run after AuthMiddleware.
+func OrganizationMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgID := c.Get("X-Organization-ID")
+		if orgID == "" {
+			orgID = c.Query("organization_id")
+		}
+		if orgID == "" {
+			return c.Next()
+		}
+
+		oid, err := uuid.Parse(orgID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid organization ID",
+			})
+		}
+
+		user := GetUser(c)
+		if user == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "unauthorized",
+			})
+		}
+
+		var member models.OrganizationMember
+		if err := database.DB.Preload("Organization").
+			Where("organization_id = ? AND user_id = ?", oid, user.ID).
+			First(&member).Error; err != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "not a member of this organization",
+			})
+		}
+
+		c.Locals("organization", member.Organization)
+		c.Locals("membership", member)
+
+		return c.Next()
+	}
+}
+
+// RequireOrgRole limits what organization members may do in a route group.
+// readRole is required for GET and HEAD requests and writeRole for everything
+// else. Personal requests always pass. It must run after
+// OrganizationMiddleware.
+func RequireOrgRole(readRole, writeRole string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		member := GetMembership(c)
+		if member == nil {
+			return c.Next()
+		}
+
+		role := writeRole
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			role = readRole
+		}
+
+		if !member.HasRole(role) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "this action requires the " + role + " role in the organization",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// GetOrganization returns the organization the request acts for, or nil for
+// personal requests
+func GetOrganization(c *fiber.Ctx) *models.Organization {
+	org, ok := c.Locals("organization").(models.Organization)
+	if !ok {
+		return nil
+	}
+	return &org
+}
+
+// GetMembership returns the user's membership in the request's organization,
+// or nil for personal requests
+func GetMembership(c *fiber.Ctx) *models.OrganizationMember {
+	member, ok := c.Locals("membership").(models.OrganizationMember)
+	if !ok {
+		return nil
+	}
+	return &member
+}
+
+// GetAccount returns the account the request reads from and charges to: the
+// organization's when one is selected, the user's otherwise
+func GetAccount(c *fiber.Ctx) services.Account {
+	user := GetUser(c)
+	if user == nil {
+		return services.Account{}
+	}
+	if org := GetOrganization(c); org != nil {
+		return services.AccountFor(user.ID, &org.ID)
+	}
+	return services.PersonalAccount(user.ID)
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AdminAuditLog records one action taken through the admin API
+type AdminAuditLog struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AdminID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"admin_id"`
+	Admin      User       `gorm:"foreignKey:AdminID" json:"-"`
+	Action     string     `gorm:"not null;index" json:"action"` // method and route, e.g. "POST /api/admin/users/:id/credits"
+	Path       string     `gorm:"not null" json:"path"`
+	TargetType string     `gorm:"index" json:"target_type,omitempty"` // user, transcription, payment...
+	TargetID   *uuid.UUID `gorm:"type:uuid;index" json:"target_id,omitempty"`
+	Details    *string    `gorm:"type:jsonb" json:"details,omitempty"`
+	StatusCode int        `json:"status_code"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (l *AdminAuditLog) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
+
+// ImpersonationSession lets an admin see the app as a user for support. The
+// token is stored hashed, like API keys, and only allows read requests.
+type ImpersonationSession struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AdminID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"admin_id"`
+	Admin     User       `gorm:"foreignKey:AdminID" json:"-"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID" json:"-"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	Reason    string     `gorm:"not null" json:"reason"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (s *ImpersonationSession) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsActive checks that the session has been neither ended nor outlived
+func (s *ImpersonationSession) IsActive(now time.Time) bool {
+	return s.EndedAt == nil && now.Before(s.ExpiresAt)
+}
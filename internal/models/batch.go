@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TranscriptionBatch groups jobs uploaded together with shared options
+type TranscriptionBatch struct {
+	ID             uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID       `gorm:"type:uuid;not null;index" json:"user_id"`
+	User           User            `gorm:"foreignKey:UserID" json:"-"`
+	OrganizationID *uuid.UUID      `gorm:"type:uuid;index" json:"organization_id,omitempty"`
+	Name           string          `json:"name"`
+	Language       string          `gorm:"not null" json:"language"`
+	SpeakerLabels  bool            `gorm:"not null;default:false" json:"speaker_labels"`
+	TotalFiles     int             `json:"total_files"`
+	Transcriptions []Transcription `gorm:"foreignKey:BatchID" json:"transcriptions,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+func (b *TranscriptionBatch) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return nil
+}
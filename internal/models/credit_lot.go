@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FreeSignupCredits is the minutes granted to every new account (5 hours)
+const FreeSignupCredits = 300
+
+type CreditSource string
+
+const (
+	CreditSourceFreeGrant    CreditSource = "free_grant"
+	CreditSourcePackage      CreditSource = "package"
+	CreditSourceSubscription CreditSource = "subscription"
+	CreditSourceRefund       CreditSource = "refund"
+	CreditSourceAdjustment   CreditSource = "adjustment" // granted by an admin
+)
+
+// CreditLot is a block of minutes added to a user's or organization's
+// balance by a single grant. Debits consume lots oldest-expiring first.
+type CreditLot struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID    `gorm:"type:uuid;not null;index" json:"user_id"`
+	User           User         `gorm:"foreignKey:UserID" json:"-"`
+	OrganizationID *uuid.UUID   `gorm:"type:uuid;index" json:"organization_id,omitempty"` // set for an organization's pool
+	Source         CreditSource `gorm:"not null" json:"source"`
+	PaymentID      *uuid.UUID   `gorm:"type:uuid;index" json:"payment_id,omitempty"`
+	Amount         int          `gorm:"not null" json:"amount"`    // minutes granted
+	Remaining      int          `gorm:"not null" json:"remaining"` // minutes left
+	Description    string       `json:"description"`
+	PurchasedAt    time.Time    `gorm:"not null" json:"purchased_at"`
+	ExpiresAt      *time.Time   `gorm:"index" json:"expires_at,omitempty"`
+	ExpiredAt      *time.Time   `json:"expired_at,omitempty"` // set when the expiry job zeroes the lot
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+func (l *CreditLot) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	if l.PurchasedAt.IsZero() {
+		l.PurchasedAt = time.Now()
+	}
+	return nil
+}
+
+// IsUsable checks if the lot still has minutes that can be spent at the given time
+func (l *CreditLot) IsUsable(now time.Time) bool {
+	if l.Remaining <= 0 {
+		return false
+	}
+	return l.ExpiresAt == nil || now.Before(*l.ExpiresAt)
+}
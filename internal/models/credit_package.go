@@ -0,0 +1,119 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type CreditPackage struct {
+	ID          string               `gorm:"primary_key" json:"id"` // slug, e.g. "basic"
+	Name        string               `gorm:"not null" json:"name"`
+	Description string               `json:"description"`
+	Credits     int                  `gorm:"not null" json:"credits"`      // minutes
+	ExpireDays  int                  `gorm:"default:0" json:"expire_days"` // 0 = credits never expire
+	Popular     bool                 `gorm:"default:false" json:"popular"`
+	Discount    int                  `gorm:"default:0" json:"discount"` // advertised % off, display only
+	SortOrder   int                  `gorm:"default:0" json:"sort_order"`
+	Active      bool                 `gorm:"not null" json:"active"`
+	ActiveFrom  *time.Time           `json:"active_from,omitempty"`
+	ActiveUntil *time.Time           `json:"active_until,omitempty"`
+	Prices      []CreditPackagePrice `gorm:"foreignKey:PackageID;constraint:OnDelete:CASCADE" json:"prices"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+type CreditPackagePrice struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PackageID string    `gorm:"not null;uniqueIndex:idx_package_currency" json:"package_id"`
+	Currency  string    `gorm:"not null;uniqueIndex:idx_package_currency" json:"currency"` // ISO 4217, e.g. "ARS", "USD"
+	Amount    float64   `gorm:"not null" json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (p *CreditPackagePrice) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	p.Currency = strings.ToUpper(p.Currency)
+	return nil
+}
+
+// IsAvailable checks if the package can be purchased at the given time
+func (p *CreditPackage) IsAvailable(now time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.ActiveFrom != nil && now.Before(*p.ActiveFrom) {
+		return false
+	}
+	if p.ActiveUntil != nil && !now.Before(*p.ActiveUntil) {
+		return false
+	}
+	return true
+}
+
+// PriceFor returns the package price in the given currency, or nil if the
+// package is not sold in that currency
+func (p *CreditPackage) PriceFor(currency string) *CreditPackagePrice {
+	currency = strings.ToUpper(currency)
+	for i := range p.Prices {
+		if p.Prices[i].Currency == currency {
+			return &p.Prices[i]
+		}
+	}
+	return nil
+}
+
+// DefaultCreditPackages is the catalog seeded into an empty database
+func DefaultCreditPackages() []CreditPackage {
+	return []CreditPackage{
+		{
+			ID:          "basic",
+			Name:        "Básico",
+			Description: "Perfecto para empezar",
+			Credits:     120,
+			Popular:     false,
+			Discount:    0,
+			SortOrder:   1,
+			Active:      true,
+			Prices:      []CreditPackagePrice{{Currency: "USD", Amount: 5}},
+		},
+		{
+			ID:          "standard",
+			Name:        "Estándar",
+			Description: "Ideal para uso regular",
+			Credits:     300,
+			Popular:     true,
+			Discount:    17,
+			SortOrder:   2,
+			Active:      true,
+			Prices:      []CreditPackagePrice{{Currency: "USD", Amount: 10}},
+		},
+		{
+			ID:          "premium",
+			Name:        "Premium",
+			Description: "Para usuarios frecuentes",
+			Credits:     600,
+			Popular:     false,
+			Discount:    25,
+			SortOrder:   3,
+			Active:      true,
+			Prices:      []CreditPackagePrice{{Currency: "USD", Amount: 18}},
+		},
+		{
+			ID:          "max",
+			Name:        "Max",
+			Description: "Máxima capacidad",
+			Credits:     1500,
+			Popular:     false,
+			Discount:    33,
+			SortOrder:   4,
+			Active:      true,
+			Prices:      []CreditPackagePrice{{Currency: "USD", Amount: 40}},
+		},
+	}
+}
@@ -12,13 +12,17 @@ type TransactionType string
 const (
 	TransactionDebit  TransactionType = "debit"  // Used credits
 	TransactionCredit TransactionType = "credit" // Added credits
+	TransactionExpiry TransactionType = "expiry" // Credits lost when a lot expired
 )
 
 type CreditTransaction struct {
 	ID              uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	UserID          uuid.UUID       `gorm:"type:uuid;not null;index" json:"user_id"`
 	User            User            `gorm:"foreignKey:UserID" json:"-"`
+	OrganizationID  *uuid.UUID      `gorm:"type:uuid;index" json:"organization_id,omitempty"`
 	TranscriptionID *uuid.UUID      `gorm:"type:uuid" json:"transcription_id,omitempty"`
+	CreditLotID     *uuid.UUID      `gorm:"type:uuid;index" json:"credit_lot_id,omitempty"`
+	CreditLot       *CreditLot      `gorm:"foreignKey:CreditLotID" json:"credit_lot,omitempty"`
 	Type            TransactionType `gorm:"not null" json:"type"`
 	Amount          int             `gorm:"not null" json:"amount"` // minutes
 	BalanceBefore   int             `json:"balance_before"`
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Where a consent change came from
+const (
+	ConsentSourceSettings        = "settings"         // account settings
+	ConsentSourceUnsubscribeLink = "unsubscribe_link" // footer link of an email
+	ConsentSourceOneClick        = "one_click"        // List-Unsubscribe-Post from a mail client
+)
+
+// EmailConsentEvent records one change of a user's consent to a mailing list.
+// Events are never updated or deleted, so they are the audit trail of when
+// and how consent was given or withdrawn.
+type EmailConsentEvent struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	List      string    `gorm:"not null;index" json:"list"` // notifications, promotional
+	Granted   bool      `json:"granted"`
+	Source    string    `gorm:"not null" json:"source"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (e *EmailConsentEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+const (
+	CampaignStatusSending   = "sending"
+	CampaignStatusCompleted = "completed"
+	CampaignStatusFailed    = "failed"
+)
+
+// EmailCampaign is a promotional email sent to the users opted in at send time
+type EmailCampaign struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Subject     string     `gorm:"not null" json:"subject"`
+	Body        string     `gorm:"type:text;not null" json:"body"`
+	Locale      string     `json:"locale,omitempty"` // only users with this locale, all when empty
+	CreatedByID uuid.UUID  `gorm:"type:uuid;not null" json:"created_by_id"`
+	Status      string     `gorm:"not null;default:'sending'" json:"status"`
+	Recipients  int        `json:"recipients"`
+	Sent        int        `json:"sent"`
+	Failed      int        `json:"failed"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (c *EmailCampaign) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Folder groups an account's transcriptions, such as the episodes of a
+// podcast or the interviews of a project
+type Folder struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"` // creator
+	OrganizationID *uuid.UUID `gorm:"type:uuid;index" json:"organization_id,omitempty"`
+	Name           string     `gorm:"not null" json:"name"`
+	Description    string     `json:"description"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (f *Folder) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return nil
+}
+
+// TranscriptionTag is a free-form label on a transcription. Tags are
+// lowercase and unique per transcription.
+type TranscriptionTag struct {
+	TranscriptionID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Tag             string    `gorm:"primaryKey;index"`
+	CreatedAt       time.Time
+}
+
+// MarshalJSON renders a tag as its name, so a transcription's tags read as
+// a list of strings
+func (t TranscriptionTag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Tag)
+}
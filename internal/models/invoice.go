@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvoiceImmutable = errors.New("invoices can't be modified once issued")
+
+// BillingProfile holds the details printed on a user's receipts
+type BillingProfile struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID       uuid.UUID `gorm:"type:uuid;uniqueIndex;not null" json:"user_id"`
+	User         User      `gorm:"foreignKey:UserID" json:"-"`
+	LegalName    string    `json:"legal_name"`
+	TaxID        string    `json:"tax_id"` // CUIT, RFC, VAT number...
+	AddressLine1 string    `json:"address_line1"`
+	AddressLine2 string    `json:"address_line2"`
+	City         string    `json:"city"`
+	State        string    `json:"state"`
+	PostalCode   string    `json:"postal_code"`
+	Country      string    `json:"country"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (b *BillingProfile) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	return nil
+}
+
+// Invoice is the receipt issued for an approved payment. Numbers are
+// sequential without gaps and rows are never updated or deleted; billing
+// details are copied so later profile edits don't change issued receipts.
+type Invoice struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Number        int64     `gorm:"uniqueIndex;not null" json:"number"`
+	DisplayNumber string    `gorm:"not null" json:"display_number"` // e.g. LW-00000042
+	PaymentID     uuid.UUID `gorm:"type:uuid;uniqueIndex;not null" json:"payment_id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Email         string    `json:"email"`
+	LegalName     string    `json:"legal_name"`
+	TaxID         string    `json:"tax_id"`
+	Address       string    `json:"address"`
+	Description   string    `json:"description"`
+	CreditsAmount int       `json:"credits_amount"`
+	Subtotal      float64   `json:"subtotal"`
+	Discount      float64   `json:"discount"`
+	Total         float64   `json:"total"`
+	Currency      string    `json:"currency"`
+	IssuedAt      time.Time `gorm:"not null" json:"issued_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (i *Invoice) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+func (i *Invoice) BeforeUpdate(tx *gorm.DB) error {
+	return ErrInvoiceImmutable
+}
+
+func (i *Invoice) BeforeDelete(tx *gorm.DB) error {
+	return ErrInvoiceImmutable
+}
+
+// FormatInvoiceNumber renders a sequential number with the configured prefix
+func FormatInvoiceNumber(prefix string, number int64) string {
+	return fmt.Sprintf("%s-%08d", prefix, number)
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MediaAnalysisStatus string
+
+const (
+	MediaAnalysisProcessing MediaAnalysisStatus = "processing"
+	MediaAnalysisCompleted  MediaAnalysisStatus = "completed"
+	MediaAnalysisFailed     MediaAnalysisStatus = "failed"
+)
+
+// MediaAnalysis is what ffprobe found in a transcription's source media, plus
+// the waveform peaks and, for video, the poster frame generated for the
+// editor. The generated files live in storage under media/<transcription id>/.
+type MediaAnalysis struct {
+	TranscriptionID uuid.UUID           `gorm:"type:uuid;primaryKey" json:"-"`
+	Status          MediaAnalysisStatus `gorm:"not null;index" json:"status"`
+	Attempts        int                 `json:"-"`
+	Error           string              `json:"error,omitempty"`
+	Container       string              `json:"container,omitempty"` // e.g. "mov,mp4,m4a,3gp,3g2,mj2"
+	DurationMs      int64               `json:"duration_ms,omitempty"`
+	Bitrate         int64               `json:"bitrate,omitempty"` // in bits per second, whole file
+	AudioCodec      string              `json:"audio_codec,omitempty"`
+	AudioChannels   int                 `json:"audio_channels,omitempty"`
+	AudioSampleRate int                 `json:"audio_sample_rate,omitempty"` // in Hz
+	AudioBitrate    int64               `json:"audio_bitrate,omitempty"`
+	VideoCodec      string              `json:"video_codec,omitempty"`
+	VideoWidth      int                 `json:"video_width,omitempty"`
+	VideoHeight     int                 `json:"video_height,omitempty"`
+	VideoFrameRate  float64             `json:"video_frame_rate,omitempty"`
+	WaveformKey     string              `json:"-"`
+	PeaksPerSecond  int                 `json:"peaks_per_second,omitempty"`
+	PosterKey       string              `json:"-"`
+	WaveformURL     string              `gorm:"-" json:"waveform_url,omitempty"` // API path, set when served
+	PosterURL       string              `gorm:"-" json:"poster_url,omitempty"`
+	AnalyzedAt      *time.Time          `json:"analyzed_at,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+// HasVideo reports whether the media has a video stream
+func (a *MediaAnalysis) HasVideo() bool {
+	return a.VideoCodec != ""
+}
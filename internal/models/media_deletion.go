@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MediaDeletionStatus string
+
+const (
+	MediaDeletionPending MediaDeletionStatus = "pending"
+	MediaDeletionDone    MediaDeletionStatus = "done"
+	MediaDeletionFailed  MediaDeletionStatus = "failed" // gave up after the last retry
+)
+
+// Why a stored object is being deleted
+const (
+	MediaDeletionReasonTranscriptionDeleted = "transcription_deleted"
+	MediaDeletionReasonRetention            = "retention"
+	MediaDeletionReasonOrphaned             = "orphaned"
+)
+
+// MediaDeletion is a queued delete of a storage object, retried until the
+// storage confirms it or it runs out of attempts
+type MediaDeletion struct {
+	ID              uuid.UUID           `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Key             string              `gorm:"not null;index" json:"key"`
+	Reason          string              `gorm:"not null" json:"reason"`
+	TranscriptionID *uuid.UUID          `gorm:"type:uuid" json:"transcription_id,omitempty"` // no foreign key: the transcription may be gone
+	UserID          *uuid.UUID          `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Size            int64               `json:"size"` // in bytes, when known
+	Status          MediaDeletionStatus `gorm:"default:'pending';index" json:"status"`
+	Attempts        int                 `json:"attempts"`
+	NextAttemptAt   *time.Time          `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastError       string              `json:"last_error,omitempty"`
+	CompletedAt     *time.Time          `json:"completed_at,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+func (d *MediaDeletion) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Organization member roles, from most to least privileged
+const (
+	OrgRoleOwner  = "owner"  // everything, including deleting the organization and managing owners
+	OrgRoleAdmin  = "admin"  // members, settings and purchases
+	OrgRoleEditor = "editor" // upload, edit and delete transcriptions
+	OrgRoleViewer = "viewer" // read transcriptions and the dashboard
+)
+
+var orgRoleRank = map[string]int{
+	OrgRoleViewer: 1,
+	OrgRoleEditor: 2,
+	OrgRoleAdmin:  3,
+	OrgRoleOwner:  4,
+}
+
+// Organization is a team sharing transcriptions and a credit pool. Its
+// balance is kept in CreditsRemaining like a user's.
+type Organization struct {
+	ID               uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name             string               `gorm:"not null" json:"name"`
+	CreditsRemaining int                  `gorm:"default:0" json:"credits_remaining"` // sum of usable CreditLot.Remaining
+	CreatedByID      uuid.UUID            `gorm:"type:uuid;not null" json:"created_by_id"`
+	Members          []OrganizationMember `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE" json:"members,omitempty"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+}
+
+func (o *Organization) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+// HasCredits checks if the pool has enough credits for a given duration in minutes
+func (o *Organization) HasCredits(minutes int) bool {
+	return o.CreditsRemaining >= minutes
+}
+
+// OrganizationMember gives a user a role in an organization
+type OrganizationMember struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_org_member" json:"organization_id"`
+	Organization   Organization `gorm:"foreignKey:OrganizationID" json:"-"`
+	UserID         uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex:idx_org_member;index" json:"user_id"`
+	User           User         `gorm:"foreignKey:UserID" json:"user"`
+	Role           string       `gorm:"not null" json:"role"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+func (m *OrganizationMember) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// HasRole checks if the member's role is at least the given one
+func (m *OrganizationMember) HasRole(role string) bool {
+	return orgRoleRank[m.Role] >= orgRoleRank[role]
+}
+
+// IsValidOrgRole checks a role name against the organization roles
+func IsValidOrgRole(role string) bool {
+	_, ok := orgRoleRank[role]
+	return ok
+}
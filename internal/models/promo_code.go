@@ -0,0 +1,80 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PromoCode struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Code            string     `gorm:"uniqueIndex;not null" json:"code"`
+	Description     string     `json:"description"`
+	DiscountPercent int        `gorm:"default:0" json:"discount_percent"` // 0-100
+	DiscountAmount  float64    `gorm:"default:0" json:"discount_amount"`  // fixed amount off, in Currency
+	Currency        string     `json:"currency,omitempty"`                // required when DiscountAmount is set
+	PackageID       *string    `json:"package_id,omitempty"`              // restrict to a single package
+	MaxUses         *int       `json:"max_uses,omitempty"`                // total redemptions allowed
+	MaxUsesPerUser  *int       `json:"max_uses_per_user,omitempty"`
+	Active          bool       `gorm:"not null" json:"active"`
+	ValidFrom       *time.Time `json:"valid_from,omitempty"`
+	ValidUntil      *time.Time `json:"valid_until,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (p *PromoCode) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	p.Code = NormalizePromoCode(p.Code)
+	p.Currency = strings.ToUpper(p.Currency)
+	return nil
+}
+
+// NormalizePromoCode returns the canonical form codes are stored and looked up in
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// IsValid checks the active flag and validity window at the given time
+func (p *PromoCode) IsValid(now time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.ValidFrom != nil && now.Before(*p.ValidFrom) {
+		return false
+	}
+	if p.ValidUntil != nil && !now.Before(*p.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// AppliesTo checks if the code can be used for the given package and currency
+func (p *PromoCode) AppliesTo(packageID, currency string) bool {
+	if p.PackageID != nil && *p.PackageID != packageID {
+		return false
+	}
+	if p.DiscountAmount > 0 && !strings.EqualFold(p.Currency, currency) {
+		return false
+	}
+	return true
+}
+
+// DiscountFor returns the discount to apply to the given price, rounded to
+// cents and never larger than the price itself
+func (p *PromoCode) DiscountFor(price float64) float64 {
+	discount := p.DiscountAmount
+	if p.DiscountPercent > 0 {
+		discount += price * float64(p.DiscountPercent) / 100
+	}
+	discount = math.Round(discount*100) / 100
+	if discount > price {
+		discount = price
+	}
+	return discount
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPromoCodeIsValid(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		promo PromoCode
+		want  bool
+	}{
+		{"active without window", PromoCode{Active: true}, true},
+		{"inactive", PromoCode{Active: false}, false},
+		{"not started", PromoCode{Active: true, ValidFrom: &after}, false},
+		{"started", PromoCode{Active: true, ValidFrom: &before}, true},
+		{"starts now", PromoCode{Active: true, ValidFrom: &now}, true},
+		{"ended", PromoCode{Active: true, ValidUntil: &before}, false},
+		{"ends now", PromoCode{Active: true, ValidUntil: &now}, false},
+		{"inside window", PromoCode{Active: true, ValidFrom: &before, ValidUntil: &after}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.IsValid(now); got != tt.want {
+				t.Errorf("IsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoCodeAppliesTo(t *testing.T) {
+	pro := "pro"
+
+	tests := []struct {
+		name      string
+		promo     PromoCode
+		packageID string
+		currency  string
+		want      bool
+	}{
+		{"any package, percent off", PromoCode{DiscountPercent: 10}, "basic", "USD", true},
+		{"restricted package matches", PromoCode{DiscountPercent: 10, PackageID: &pro}, "pro", "ARS", true},
+		{"restricted package differs", PromoCode{DiscountPercent: 10, PackageID: &pro}, "basic", "ARS", false},
+		{"fixed amount in the same currency", PromoCode{DiscountAmount: 500, Currency: "ARS"}, "basic", "ARS", true},
+		{"fixed amount, currency case", PromoCode{DiscountAmount: 500, Currency: "ARS"}, "basic", "ars", true},
+		{"fixed amount in another currency", PromoCode{DiscountAmount: 500, Currency: "ARS"}, "basic", "USD", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.AppliesTo(tt.packageID, tt.currency); got != tt.want {
+				t.Errorf("AppliesTo(%q, %q) = %v, want %v", tt.packageID, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoCodeDiscountFor(t *testing.T) {
+	tests := []struct {
+		name  string
+		promo PromoCode
+		price float64
+		want  float64
+	}{
+		{"no discount", PromoCode{}, 1000, 0},
+		{"percent", PromoCode{DiscountPercent: 15}, 1000, 150},
+		{"percent rounded to cents", PromoCode{DiscountPercent: 33}, 9.99, 3.30},
+		{"fixed amount", PromoCode{DiscountAmount: 250.5}, 1000, 250.5},
+		{"percent plus fixed amount", PromoCode{DiscountPercent: 10, DiscountAmount: 50}, 1000, 150},
+		{"capped at the price", PromoCode{DiscountAmount: 1500}, 1000, 1000},
+		{"full percent", PromoCode{DiscountPercent: 100}, 4.99, 4.99},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.DiscountFor(tt.price); got != tt.want {
+				t.Errorf("DiscountFor(%v) = %v, want %v", tt.price, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePromoCode(t *testing.T) {
+	for in, want := range map[string]string{
+		"verano24":   "VERANO24",
+		"  Promo10 ": "PROMO10",
+		"":           "",
+	} {
+		if got := NormalizePromoCode(in); got != want {
+			t.Errorf("NormalizePromoCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
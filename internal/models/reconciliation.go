@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReconciliationRun stores the report of one payment reconciliation pass
+type ReconciliationRun struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Trigger       string     `gorm:"not null" json:"trigger"` // scheduled, manual, cli
+	DryRun        bool       `json:"dry_run"`
+	Checked       int        `json:"checked"`
+	Settled       int        `json:"settled"`
+	Discrepancies int        `json:"discrepancies"`
+	Errors        int        `json:"errors"`
+	Report        *string    `gorm:"type:jsonb" json:"report,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (r *ReconciliationRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Formats a share link can offer for download
+var ShareFormats = []string{"txt", "srt", "vtt"}
+
+// ShareLink gives read-only access to one transcription to anyone holding
+// its token, without an account
+type ShareLink struct {
+	ID              uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TranscriptionID uuid.UUID     `gorm:"type:uuid;not null;index" json:"transcription_id"`
+	Transcription   Transcription `gorm:"foreignKey:TranscriptionID;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedByID     uuid.UUID     `gorm:"type:uuid;not null" json:"created_by_id"`
+	Token           string        `gorm:"uniqueIndex;not null" json:"token"`
+	PasswordHash    string        `json:"-"`                 // bcrypt, empty when the link is open
+	Formats         string        `gorm:"not null" json:"-"` // comma separated
+	ExpiresAt       *time.Time    `json:"expires_at,omitempty"`
+	RevokedAt       *time.Time    `json:"revoked_at,omitempty"`
+	ViewCount       int           `gorm:"not null;default:0" json:"view_count"`
+	LastViewedAt    *time.Time    `json:"last_viewed_at,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+func (l *ShareLink) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
+
+// FormatList returns the download formats the link allows
+func (l *ShareLink) FormatList() []string {
+	if l.Formats == "" {
+		return []string{}
+	}
+	return strings.Split(l.Formats, ",")
+}
+
+// AllowsFormat checks if the link offers downloads in the given format
+func (l *ShareLink) AllowsFormat(format string) bool {
+	for _, f := range l.FormatList() {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPassword reports whether viewers must enter a password
+func (l *ShareLink) HasPassword() bool {
+	return l.PasswordHash != ""
+}
+
+// IsActive checks that the link is neither revoked nor expired
+func (l *ShareLink) IsActive(now time.Time) bool {
+	if l.RevokedAt != nil {
+		return false
+	}
+	return l.ExpiresAt == nil || now.Before(*l.ExpiresAt)
+}
+
+// IsValidShareFormat checks a format name against ShareFormats
+func IsValidShareFormat(format string) bool {
+	for _, f := range ShareFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrVersionImmutable = errors.New("transcript versions can't be modified")
+
+// What produced a transcript version
+const (
+	VersionReasonTranscription = "transcription" // first result from the provider
+	VersionReasonReprocess     = "reprocess"     // result of reprocessing
+	VersionReasonEdit          = "edit"
+	VersionReasonRestore       = "restore"
+)
+
+// TranscriptVersion is an immutable snapshot of a transcript. Every result
+// from the provider, edit and restore adds one; Transcription.Version points
+// at the one currently shown.
+type TranscriptVersion struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TranscriptionID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_transcription_version" json:"transcription_id"`
+	Version         int        `gorm:"not null;uniqueIndex:idx_transcription_version" json:"version"`
+	Reason          string     `gorm:"not null" json:"reason"`
+	AuthorID        *uuid.UUID `gorm:"type:uuid" json:"author_id,omitempty"` // nil for provider results
+	AuthorEmail     string     `json:"author_email,omitempty"`
+	RestoredFrom    *int       `json:"restored_from,omitempty"`
+	Language        string     `json:"language"`
+	SpeakerLabels   bool       `gorm:"not null;default:false" json:"speaker_labels"`
+	AssemblyAIID    string     `json:"assemblyai_id,omitempty"`
+	TranscriptText  *string    `gorm:"type:text" json:"transcript_text,omitempty"`
+	SRTContent      *string    `gorm:"type:text" json:"srt_content,omitempty"`
+	VTTContent      *string    `gorm:"type:text" json:"vtt_content,omitempty"`
+	Duration        int        `json:"duration"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (v *TranscriptVersion) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == uuid.Nil {
+		v.ID = uuid.New()
+	}
+	return nil
+}
+
+func (v *TranscriptVersion) BeforeUpdate(tx *gorm.DB) error {
+	return ErrVersionImmutable
+}
+
+func (v *TranscriptVersion) BeforeDelete(tx *gorm.DB) error {
+	return ErrVersionImmutable
+}
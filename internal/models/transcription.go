@@ -14,28 +14,48 @@ const (
 	StatusProcessing TranscriptionStatus = "processing"
 	StatusCompleted  TranscriptionStatus = "completed"
 	StatusFailed     TranscriptionStatus = "failed"
+	StatusCancelled  TranscriptionStatus = "cancelled"
 )
 
 type Transcription struct {
-	ID               uuid.UUID           `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID           uuid.UUID           `gorm:"type:uuid;not null;index" json:"user_id"`
-	User             User                `gorm:"foreignKey:UserID" json:"-"`
-	FileName         string              `gorm:"not null" json:"file_name"`
-	FileURL          string              `gorm:"not null" json:"file_url"`
-	FileSize         int64               `json:"file_size"`         // in bytes
-	Duration         int                 `json:"duration"`          // in seconds
-	Status           TranscriptionStatus `gorm:"default:'pending'" json:"status"`
-	AssemblyAIID     string              `json:"assemblyai_id,omitempty"`
-	TranscriptText   *string             `gorm:"type:text" json:"transcript_text,omitempty"`
-	TranscriptJSON   *string             `gorm:"type:jsonb" json:"transcript_json,omitempty"` // Full AssemblyAI response
-	SRTContent       *string             `gorm:"type:text" json:"srt_content,omitempty"`
-	VTTContent       *string             `gorm:"type:text" json:"vtt_content,omitempty"`
-	ErrorMessage     string              `json:"error_message,omitempty"`
-	Language         string              `gorm:"default:'es'" json:"language"` // detected or specified language
-	CreditsUsed      int                 `json:"credits_used"`                 // minutes of audio processed
-	CreatedAt        time.Time           `json:"created_at"`
-	UpdatedAt        time.Time           `json:"updated_at"`
-	CompletedAt      *time.Time          `json:"completed_at,omitempty"`
+	ID             uuid.UUID           `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID         uuid.UUID           `gorm:"type:uuid;not null;index" json:"user_id"`
+	User           User                `gorm:"foreignKey:UserID" json:"-"`
+	OrganizationID *uuid.UUID          `gorm:"type:uuid;index" json:"organization_id,omitempty"`
+	BatchID        *uuid.UUID          `gorm:"type:uuid;index" json:"batch_id,omitempty"`
+	FolderID       *uuid.UUID          `gorm:"type:uuid;index" json:"folder_id,omitempty"`
+	FileName       string              `gorm:"not null" json:"file_name"`
+	FileURL        string              `gorm:"not null" json:"file_url"`
+	SourceURL      *string             `json:"source_url,omitempty"` // remote URL the media was submitted from
+	FileSize       int64               `json:"file_size"`            // in bytes
+	Duration       int                 `json:"duration"`             // in seconds
+	Status         TranscriptionStatus `gorm:"default:'pending'" json:"status"`
+	AssemblyAIID   string              `json:"assemblyai_id,omitempty"`
+	TranscriptText *string             `gorm:"type:text" json:"transcript_text,omitempty"`
+	TranscriptJSON *string             `gorm:"type:jsonb" json:"transcript_json,omitempty"` // Full AssemblyAI response
+	SRTContent     *string             `gorm:"type:text" json:"srt_content,omitempty"`
+	VTTContent     *string             `gorm:"type:text" json:"vtt_content,omitempty"`
+	ErrorMessage   string              `json:"error_message,omitempty"`
+	Language       string              `gorm:"default:'es'" json:"language"` // detected or specified language
+	SpeakerLabels  bool                `gorm:"not null;default:false" json:"speaker_labels"`
+	Version        int                 `gorm:"not null;default:1" json:"version"` // current TranscriptVersion
+	CreditsUsed    int                 `json:"credits_used"`                      // minutes of audio processed, across all runs
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+	CompletedAt    *time.Time          `json:"completed_at,omitempty"`
+	CancelledAt    *time.Time          `json:"cancelled_at,omitempty"`
+	RequeuedAt     *time.Time          `gorm:"index" json:"requeued_at,omitempty"` // stuck job handed back for the server to run again
+	MediaPurgedAt  *time.Time          `json:"media_purged_at,omitempty"`          // source media deleted by the retention policy
+	DeletedAt      gorm.DeletedAt      `gorm:"index" json:"deleted_at"`            // in the trash; restorable until purged
+	Versions       []TranscriptVersion `gorm:"foreignKey:TranscriptionID;constraint:OnDelete:CASCADE" json:"-"`
+	Tags           []TranscriptionTag  `gorm:"foreignKey:TranscriptionID;constraint:OnDelete:CASCADE" json:"tags,omitempty"`
+	Media          *MediaAnalysis      `gorm:"foreignKey:TranscriptionID;constraint:OnDelete:CASCADE" json:"media,omitempty"`
+}
+
+// HasMedia reports whether the source media is still kept for this job.
+// Without it the job can't be retried or reprocessed.
+func (t *Transcription) HasMedia() bool {
+	return t.MediaPurgedAt == nil
 }
 
 func (t *Transcription) BeforeCreate(tx *gorm.DB) error {
@@ -7,21 +7,30 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID               uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	SupabaseUserID   string    `gorm:"uniqueIndex;not null" json:"supabase_user_id"`
 	Email            string    `gorm:"uniqueIndex;not null" json:"email"`
-	CreditsRemaining int       `gorm:"default:300" json:"credits_remaining"` // 5 hours * 60 minutes = 300 minutes
-	Plan             string    `gorm:"default:'free'" json:"plan"`           // free, pro, enterprise
+	CreditsRemaining int       `gorm:"default:0" json:"credits_remaining"` // sum of usable CreditLot.Remaining
+	Plan             string    `gorm:"default:'free'" json:"plan"`         // free, pro, enterprise
+	Role             string    `gorm:"default:'user'" json:"role"`         // user, admin
 	StripeCustomerID string    `json:"stripe_customer_id,omitempty"`
 
 	// Settings
-	DefaultLanguage        string `gorm:"default:'es'" json:"default_language"`                // Default transcription language
-	DefaultExportFormat    string `gorm:"default:'srt'" json:"default_export_format"`          // txt, srt, vtt
-	IncludeTimestamps      bool   `gorm:"default:true" json:"include_timestamps"`              // Include timestamps in exports
-	DetectSpeakers         bool   `gorm:"default:true" json:"detect_speakers"`                 // Detect multiple speakers
-	EmailNotifications     bool   `gorm:"default:true" json:"email_notifications"`             // Send email when transcription completes
-	PromotionalEmails      bool   `gorm:"default:false" json:"promotional_emails"`             // Send promotional emails
+	DefaultLanguage     string `gorm:"default:'es'" json:"default_language"`       // Default transcription language
+	DefaultExportFormat string `gorm:"default:'srt'" json:"default_export_format"` // txt, srt, vtt
+	IncludeTimestamps   bool   `gorm:"default:true" json:"include_timestamps"`     // Include timestamps in exports
+	DetectSpeakers      bool   `gorm:"default:true" json:"detect_speakers"`        // Detect multiple speakers
+	EmailNotifications  bool   `gorm:"default:true" json:"email_notifications"`    // Send email when transcription completes
+	PromotionalEmails   bool   `gorm:"default:false" json:"promotional_emails"`    // Send promotional emails
+	Locale              string `gorm:"default:'es'" json:"locale"`                 // Language of emails: es, en
+
+	ErasedAt *time.Time `json:"erased_at,omitempty"` // account deleted; the row is kept anonymized for the financial records
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -34,15 +43,17 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// IsErased reports whether the account was deleted
+func (u *User) IsErased() bool {
+	return u.ErasedAt != nil
+}
+
+// IsAdmin checks if user has access to the admin API
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
 // HasCredits checks if user has enough credits for a given duration in minutes
 func (u *User) HasCredits(minutes int) bool {
 	return u.CreditsRemaining >= minutes
 }
-
-// DeductCredits removes credits from user account
-func (u *User) DeductCredits(minutes int) {
-	u.CreditsRemaining -= minutes
-	if u.CreditsRemaining < 0 {
-		u.CreditsRemaining = 0
-	}
-}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Outbound webhook events
+const (
+	EventTranscriptionCompleted = "transcription.completed"
+	EventTranscriptionFailed    = "transcription.failed"
+	EventTranscriptionCancelled = "transcription.cancelled"
+	EventCreditsLow             = "credits.low"
+)
+
+var WebhookEvents = []string{EventTranscriptionCompleted, EventTranscriptionFailed, EventTranscriptionCancelled, EventCreditsLow}
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	DeliveryFailed    WebhookDeliveryStatus = "failed" // gave up after the last retry
+)
+
+// WebhookEndpoint is a user-registered URL that receives signed event payloads
+type WebhookEndpoint struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	User        User      `gorm:"foreignKey:UserID" json:"-"`
+	URL         string    `gorm:"not null" json:"url"`
+	Description string    `json:"description"`
+	Events      string    `gorm:"not null" json:"-"` // comma separated
+	Secret      string    `gorm:"not null" json:"-"` // HMAC key, shown on create and roll
+	Active      bool      `gorm:"not null" json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (e *WebhookEndpoint) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// EventList returns the events the endpoint is subscribed to
+func (e *WebhookEndpoint) EventList() []string {
+	if e.Events == "" {
+		return []string{}
+	}
+	return strings.Split(e.Events, ",")
+}
+
+// IsValidWebhookEvent checks an event name against WebhookEvents
+func IsValidWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent to one endpoint, retried until it
+// succeeds or runs out of attempts
+type WebhookDelivery struct {
+	ID               uuid.UUID             `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EndpointID       uuid.UUID             `gorm:"type:uuid;not null;index" json:"endpoint_id"`
+	Endpoint         WebhookEndpoint       `gorm:"foreignKey:EndpointID;constraint:OnDelete:CASCADE" json:"-"`
+	UserID           uuid.UUID             `gorm:"type:uuid;not null;index" json:"user_id"`
+	Event            string                `gorm:"not null" json:"event"`
+	Payload          string                `gorm:"type:jsonb;not null" json:"payload"`
+	Status           WebhookDeliveryStatus `gorm:"default:'pending';index" json:"status"`
+	Attempts         int                   `json:"attempts"`
+	NextAttemptAt    *time.Time            `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastResponseCode int                   `json:"last_response_code,omitempty"`
+	LastError        string                `json:"last_error,omitempty"`
+	RedeliveryOf     *uuid.UUID            `gorm:"type:uuid" json:"redelivery_of,omitempty"`
+	DeliveredAt      *time.Time            `json:"delivered_at,omitempty"`
+	AttemptLog       []WebhookAttempt      `gorm:"foreignKey:DeliveryID;constraint:OnDelete:CASCADE" json:"attempt_log,omitempty"`
+	CreatedAt        time.Time             `json:"created_at"`
+	UpdatedAt        time.Time             `json:"updated_at"`
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// WebhookAttempt records a single HTTP request made for a delivery
+type WebhookAttempt struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	DeliveryID   uuid.UUID `gorm:"type:uuid;not null;index" json:"delivery_id"`
+	ResponseCode int       `json:"response_code,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"` // truncated
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (a *WebhookAttempt) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
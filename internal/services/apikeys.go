@@ -0,0 +1,73 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// APIKeyPrefix marks a bearer token as a personal API key rather than a Supabase JWT
+const APIKeyPrefix = "lwk_"
+
+const apiKeyAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// GenerateAPIKey creates a new random key. It returns the plaintext key,
+// shown to the user once, the display prefix and the hash to store.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	secret, err := randomString(40)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	key = APIKeyPrefix + secret
+	prefix = key[:len(APIKeyPrefix)+8]
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the value stored for a key. Keys are long random strings,
+// so an unsalted SHA-256 is enough and allows lookup by hash.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ImpersonationPrefix marks a bearer token as an admin impersonation session
+const ImpersonationPrefix = "lwi_"
+
+// GenerateImpersonationToken creates a session token. It returns the
+// plaintext token and the hash to store.
+func GenerateImpersonationToken() (token, hash string, err error) {
+	secret, err := randomString(40)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate impersonation token: %w", err)
+	}
+
+	token = ImpersonationPrefix + secret
+	return token, HashAPIKey(token), nil
+}
+
+// IsImpersonationToken checks if a bearer token is an impersonation session
+func IsImpersonationToken(token string) bool {
+	return strings.HasPrefix(token, ImpersonationPrefix)
+}
+
+// IsAPIKey checks if a bearer token looks like a personal API key
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+func randomString(length int) (string, error) {
+	max := big.NewInt(int64(len(apiKeyAlphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = apiKeyAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -30,12 +30,23 @@ func (s *AssemblyAIService) UploadFile(ctx context.Context, fileURL string) (str
 	return fileURL, nil
 }
 
-func (s *AssemblyAIService) CreateTranscription(ctx context.Context, audioURL string, language string) (*TranscriptionResult, error) {
+// TranscriptionOptions are the per-job settings sent to AssemblyAI
+type TranscriptionOptions struct {
+	Language      string
+	SpeakerLabels bool
+}
+
+// CreateTranscription submits a job and returns without waiting for it;
+// use WaitForCompletion to follow it
+func (s *AssemblyAIService) CreateTranscription(ctx context.Context, audioURL string, opts TranscriptionOptions) (*TranscriptionResult, error) {
 	params := &aai.TranscriptOptionalParams{
-		LanguageCode: aai.TranscriptLanguageCode(language),
+		LanguageCode: aai.TranscriptLanguageCode(opts.Language),
+	}
+	if opts.SpeakerLabels {
+		params.SpeakerLabels = aai.Bool(true)
 	}
 
-	transcript, err := s.client.Transcripts.TranscribeFromURL(ctx, audioURL, params)
+	transcript, err := s.client.Transcripts.SubmitFromURL(ctx, audioURL, params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transcription: %w", err)
 	}
@@ -86,6 +97,15 @@ func (s *AssemblyAIService) GetTranscription(ctx context.Context, transcriptID s
 	return result, nil
 }
 
+// DeleteTranscription removes a transcript at AssemblyAI. Deleting a queued
+// or processing transcript stops it there too.
+func (s *AssemblyAIService) DeleteTranscription(ctx context.Context, transcriptID string) error {
+	if _, err := s.client.Transcripts.Delete(ctx, transcriptID); err != nil {
+		return fmt.Errorf("failed to delete transcription: %w", err)
+	}
+	return nil
+}
+
 func (s *AssemblyAIService) GetSRT(ctx context.Context, transcriptID string) (string, error) {
 	srt, err := s.client.Transcripts.GetSubtitles(ctx, transcriptID, aai.SubtitleFormat("srt"), nil)
 	if err != nil {
@@ -102,11 +122,14 @@ func (s *AssemblyAIService) GetVTT(ctx context.Context, transcriptID string) (st
 	return string(vtt), nil
 }
 
-func (s *AssemblyAIService) WaitForCompletion(ctx context.Context, transcriptID string, maxWait time.Duration) (*TranscriptionResult, error) {
+// WaitForCompletion polls the transcript until it finishes. onStatus, if set,
+// is called whenever the AssemblyAI status (queued, processing...) changes.
+func (s *AssemblyAIService) WaitForCompletion(ctx context.Context, transcriptID string, maxWait time.Duration, onStatus func(status string)) (*TranscriptionResult, error) {
 	ticker := time.NewTicker(3 * time.Second)
 	defer ticker.Stop()
 
 	timeout := time.After(maxWait)
+	lastStatus := ""
 
 	for {
 		select {
@@ -120,6 +143,11 @@ func (s *AssemblyAIService) WaitForCompletion(ctx context.Context, transcriptID
 				return nil, err
 			}
 
+			if onStatus != nil && result.Status != lastStatus {
+				lastStatus = result.Status
+				onStatus(result.Status)
+			}
+
 			switch result.Status {
 			case "completed":
 				return result, nil
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+const campaignBatchSize = 100
+
+var promotionalFooters = map[string]string{
+	"es": "\n--\nRecibís este email porque aceptaste recibir novedades y promociones de Litwick.\nPara dejar de recibirlas: %s\n",
+	"en": "\n--\nYou get this email because you agreed to receive Litwick news and offers.\nTo stop receiving them: %s\n",
+}
+
+// campaignAudience narrows the promotional audience to a campaign's locale
+func campaignAudience(db *gorm.DB, campaign *models.EmailCampaign) *gorm.DB {
+	query := PromotionalAudience(db)
+	if campaign.Locale != "" {
+		query = query.Where("locale = ?", campaign.Locale)
+	}
+	return query
+}
+
+// CountCampaignAudience counts who a campaign would go to right now
+func CountCampaignAudience(db *gorm.DB, campaign *models.EmailCampaign) (int64, error) {
+	var count int64
+	err := campaignAudience(db, campaign).Count(&count).Error
+	return count, err
+}
+
+// SendCampaign delivers a campaign in batches, re-reading the audience for
+// each one so users who unsubscribe mid-send are skipped. Progress is saved
+// after every batch.
+func SendCampaign(db *gorm.DB, campaign *models.EmailCampaign) {
+	ctx := context.Background()
+	lastID := uuid.Nil
+	status := models.CampaignStatusCompleted
+
+	for {
+		var users []models.User
+		if err := campaignAudience(db, campaign).
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(campaignBatchSize).
+			Find(&users).Error; err != nil {
+			log.Printf("Campaign %s: failed to load audience: %v", campaign.ID, err)
+			status = models.CampaignStatusFailed
+			break
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		for _, user := range users {
+			campaign.Recipients++
+			sendCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			if err := Mail.Send(sendCtx, campaignMessage(campaign, user)); err != nil {
+				log.Printf("Campaign %s: failed to send to %s: %v", campaign.ID, user.ID, err)
+				campaign.Failed++
+			} else {
+				campaign.Sent++
+			}
+			cancel()
+		}
+		lastID = users[len(users)-1].ID
+
+		db.Model(campaign).Updates(map[string]interface{}{
+			"recipients": campaign.Recipients,
+			"sent":       campaign.Sent,
+			"failed":     campaign.Failed,
+		})
+	}
+
+	now := time.Now()
+	campaign.Status = status
+	campaign.FinishedAt = &now
+	if err := db.Model(campaign).Updates(map[string]interface{}{
+		"status":      status,
+		"finished_at": now,
+	}).Error; err != nil {
+		log.Printf("Campaign %s: failed to save status: %v", campaign.ID, err)
+	}
+}
+
+func campaignMessage(campaign *models.EmailCampaign, user models.User) MailMessage {
+	footer, ok := promotionalFooters[user.Locale]
+	if !ok {
+		footer = promotionalFooters[defaultLocale]
+	}
+	unsubscribe := UnsubscribeURL(user.ID, MailListPromotional)
+
+	return MailMessage{
+		To:      user.Email,
+		Subject: campaign.Subject,
+		Body:    campaign.Body + "\n" + fmt.Sprintf(footer, unsubscribe),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribe + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConsentContext says where a consent change came from, for the audit trail
+type ConsentContext struct {
+	Source    string
+	IPAddress string
+	UserAgent string
+}
+
+// mailListSetting returns the user column behind a mailing list and its
+// current value
+func mailListSetting(user *models.User, list string) (string, bool, bool) {
+	switch list {
+	case MailListNotifications:
+		return "email_notifications", user.EmailNotifications, true
+	case MailListPromotional:
+		return "promotional_emails", user.PromotionalEmails, true
+	}
+	return "", false, false
+}
+
+// IsMailList checks a name against the mailing lists users can opt in or out of
+func IsMailList(list string) bool {
+	_, _, ok := mailListSetting(&models.User{}, list)
+	return ok
+}
+
+// SetMailConsent opts a user in or out of a mailing list and records an
+// EmailConsentEvent when the setting actually changes. It reports whether it
+// did.
+func SetMailConsent(tx *gorm.DB, userID uuid.UUID, list string, granted bool, ctx ConsentContext) (bool, error) {
+	changed := false
+
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&user).Error; err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+
+		column, current, ok := mailListSetting(&user, list)
+		if !ok {
+			return fmt.Errorf("unknown mailing list %q", list)
+		}
+		if current == granted {
+			return nil
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update(column, granted).Error; err != nil {
+			return fmt.Errorf("failed to update %s: %w", column, err)
+		}
+
+		event := models.EmailConsentEvent{
+			UserID:    userID,
+			List:      list,
+			Granted:   granted,
+			Source:    ctx.Source,
+			IPAddress: ctx.IPAddress,
+			UserAgent: ctx.UserAgent,
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return fmt.Errorf("failed to record consent: %w", err)
+		}
+
+		changed = true
+		return nil
+	})
+
+	return changed, err
+}
+
+// PromotionalAudience selects the users currently opted in to promotional
+// email. Consent is read at query time, so anything built on it never
+// includes someone who has since unsubscribed.
+func PromotionalAudience(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.User{}).Where("promotional_emails = ? AND email <> ''", true)
+}
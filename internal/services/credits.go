@@ -0,0 +1,352 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInsufficientCredits = errors.New("insufficient credits")
+
+// CreditGrant describes a new lot of credits to add to a balance
+type CreditGrant struct {
+	Amount      int
+	Source      models.CreditSource
+	PaymentID   *uuid.UUID
+	ExpiresAt   *time.Time
+	Description string
+}
+
+// Account is whose balance a credit operation applies to, and who owns a
+// transcription or payment: a user's personal account, or an organization's
+// shared pool. UserID is the acting user and is recorded either way.
+type Account struct {
+	UserID         uuid.UUID
+	OrganizationID *uuid.UUID
+}
+
+// PersonalAccount is the user's own balance
+func PersonalAccount(userID uuid.UUID) Account {
+	return Account{UserID: userID}
+}
+
+// AccountFor builds the account of a row with a user and an optional
+// organization, such as a transcription or payment
+func AccountFor(userID uuid.UUID, organizationID *uuid.UUID) Account {
+	return Account{UserID: userID, OrganizationID: organizationID}
+}
+
+// OwnerID is the organization ID for organization accounts and the user ID
+// otherwise. Live events are published under it.
+func (a Account) OwnerID() uuid.UUID {
+	if a.OrganizationID != nil {
+		return *a.OrganizationID
+	}
+	return a.UserID
+}
+
+// Scope limits a query on an account-owned table (credit lots and
+// transactions, transcriptions, payments) to the account's rows. Personal
+// accounts don't include what the user did inside organizations.
+func (a Account) Scope(db *gorm.DB) *gorm.DB {
+	if a.OrganizationID != nil {
+		return db.Where("organization_id = ?", *a.OrganizationID)
+	}
+	return db.Where("user_id = ? AND organization_id IS NULL", a.UserID)
+}
+
+// lockAccount locks the row holding the account's cached balance, so balance
+// changes are serialized, and returns that balance
+func lockAccount(tx *gorm.DB, account Account) (int, error) {
+	if account.OrganizationID != nil {
+		var org models.Organization
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", *account.OrganizationID).First(&org).Error; err != nil {
+			return 0, fmt.Errorf("failed to lock organization: %w", err)
+		}
+		return org.CreditsRemaining, nil
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", account.UserID).First(&user).Error; err != nil {
+		return 0, fmt.Errorf("failed to lock user: %w", err)
+	}
+	return user.CreditsRemaining, nil
+}
+
+// AccountBalance returns the account's cached balance
+func AccountBalance(db *gorm.DB, account Account) (int, error) {
+	var balance int
+	var err error
+	if account.OrganizationID != nil {
+		err = db.Model(&models.Organization{}).Where("id = ?", *account.OrganizationID).Pluck("credits_remaining", &balance).Error
+	} else {
+		err = db.Model(&models.User{}).Where("id = ?", account.UserID).Pluck("credits_remaining", &balance).Error
+	}
+	return balance, err
+}
+
+// usableLots returns the account's lots that still have minutes,
+// oldest-expiring first. Lots without expiry are spent last, oldest purchase
+// first.
+func usableLots(tx *gorm.DB, account Account, now time.Time) ([]models.CreditLot, error) {
+	var lots []models.CreditLot
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Scopes(account.Scope).
+		Where("remaining > 0 AND (expires_at IS NULL OR expires_at > ?)", now).
+		Order("expires_at ASC NULLS LAST, purchased_at ASC").
+		Find(&lots).Error
+	return lots, err
+}
+
+// syncBalance recomputes the account's cached CreditsRemaining from its lots
+func syncBalance(tx *gorm.DB, account Account, now time.Time) (int, error) {
+	var balance int
+	if err := tx.Model(&models.CreditLot{}).
+		Select("COALESCE(SUM(remaining), 0)").
+		Scopes(account.Scope).
+		Where("remaining > 0 AND (expires_at IS NULL OR expires_at > ?)", now).
+		Scan(&balance).Error; err != nil {
+		return 0, err
+	}
+
+	if account.OrganizationID != nil {
+		return balance, tx.Model(&models.Organization{}).Where("id = ?", *account.OrganizationID).Update("credits_remaining", balance).Error
+	}
+	return balance, tx.Model(&models.User{}).Where("id = ?", account.UserID).Update("credits_remaining", balance).Error
+}
+
+// GrantCredits adds a new lot to the account's balance and records the
+// credit transaction. It must be called inside a database transaction.
+func GrantCredits(tx *gorm.DB, account Account, grant CreditGrant) (*models.CreditLot, error) {
+	if grant.Amount <= 0 {
+		return nil, errors.New("credit amount must be positive")
+	}
+
+	balanceBefore, err := lockAccount(tx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	lot := models.CreditLot{
+		UserID:         account.UserID,
+		OrganizationID: account.OrganizationID,
+		Source:         grant.Source,
+		PaymentID:      grant.PaymentID,
+		Amount:         grant.Amount,
+		Remaining:      grant.Amount,
+		Description:    grant.Description,
+		PurchasedAt:    now,
+		ExpiresAt:      grant.ExpiresAt,
+	}
+	if err := tx.Create(&lot).Error; err != nil {
+		return nil, fmt.Errorf("failed to create credit lot: %w", err)
+	}
+
+	balanceAfter, err := syncBalance(tx, account, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+
+	transaction := models.CreditTransaction{
+		UserID:         account.UserID,
+		OrganizationID: account.OrganizationID,
+		CreditLotID:    &lot.ID,
+		Type:           models.TransactionCredit,
+		Amount:         grant.Amount,
+		BalanceBefore:  balanceBefore,
+		BalanceAfter:   balanceAfter,
+		Description:    grant.Description,
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return nil, fmt.Errorf("failed to create credit transaction: %w", err)
+	}
+
+	return &lot, nil
+}
+
+// ConsumeCredits spends minutes from the account's lots, oldest-expiring
+// first, writing one debit transaction per lot touched. It returns
+// ErrInsufficientCredits without changing anything if the balance is too low.
+// It must be called inside a database transaction.
+func ConsumeCredits(tx *gorm.DB, account Account, amount int, transcriptionID *uuid.UUID, description string) ([]models.CreditTransaction, error) {
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	if _, err := lockAccount(tx, account); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lots, err := usableLots(tx, account, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credit lots: %w", err)
+	}
+
+	available := 0
+	for _, lot := range lots {
+		available += lot.Remaining
+	}
+	if available < amount {
+		return nil, ErrInsufficientCredits
+	}
+
+	balance := available
+	pending := amount
+	var transactions []models.CreditTransaction
+
+	for i := range lots {
+		if pending == 0 {
+			break
+		}
+
+		lot := &lots[i]
+		take := lot.Remaining
+		if take > pending {
+			take = pending
+		}
+
+		lot.Remaining -= take
+		if err := tx.Model(lot).Update("remaining", lot.Remaining).Error; err != nil {
+			return nil, fmt.Errorf("failed to update credit lot: %w", err)
+		}
+
+		transaction := models.CreditTransaction{
+			UserID:          account.UserID,
+			OrganizationID:  account.OrganizationID,
+			TranscriptionID: transcriptionID,
+			CreditLotID:     &lot.ID,
+			Type:            models.TransactionDebit,
+			Amount:          take,
+			BalanceBefore:   balance,
+			BalanceAfter:    balance - take,
+			Description:     description,
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return nil, fmt.Errorf("failed to create credit transaction: %w", err)
+		}
+
+		transactions = append(transactions, transaction)
+		balance -= take
+		pending -= take
+	}
+
+	if _, err := syncBalance(tx, account, now); err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// ExpireCreditLots zeroes every lot whose expiry has passed and records an
+// expiry transaction for the minutes that were left. It returns the number
+// of lots expired.
+func ExpireCreditLots(db *gorm.DB, now time.Time) (int, error) {
+	var lots []models.CreditLot
+	if err := db.Where("remaining > 0 AND expires_at IS NOT NULL AND expires_at <= ?", now).
+		Order("organization_id, user_id, expires_at").
+		Find(&lots).Error; err != nil {
+		return 0, fmt.Errorf("failed to find expired lots: %w", err)
+	}
+
+	expired := 0
+	for _, candidate := range lots {
+		account := AccountFor(candidate.UserID, candidate.OrganizationID)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			balanceBefore, err := lockAccount(tx, account)
+			if err != nil {
+				return err
+			}
+
+			// Reload under lock, a debit may have drained the lot since the scan
+			var lot models.CreditLot
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", candidate.ID).First(&lot).Error; err != nil {
+				return err
+			}
+			if lot.Remaining <= 0 {
+				return nil
+			}
+
+			lost := lot.Remaining
+
+			if err := tx.Model(&lot).Updates(map[string]interface{}{
+				"remaining":  0,
+				"expired_at": now,
+			}).Error; err != nil {
+				return err
+			}
+
+			balanceAfter, err := syncBalance(tx, account, now)
+			if err != nil {
+				return err
+			}
+
+			transaction := models.CreditTransaction{
+				UserID:         lot.UserID,
+				OrganizationID: lot.OrganizationID,
+				CreditLotID:    &lot.ID,
+				Type:           models.TransactionExpiry,
+				Amount:         lost,
+				BalanceBefore:  balanceBefore,
+				BalanceAfter:   balanceAfter,
+				Description:    fmt.Sprintf("Vencimiento de créditos: %s", lot.Description),
+			}
+			if err := tx.Create(&transaction).Error; err != nil {
+				return err
+			}
+
+			expired++
+			return nil
+		})
+		if err != nil {
+			return expired, fmt.Errorf("failed to expire lot %s: %w", candidate.ID, err)
+		}
+	}
+
+	return expired, nil
+}
+
+// AdjustCredits applies a manual correction to an account's balance: a
+// positive amount grants a new lot, a negative one spends minutes like a
+// job would. The reason becomes the description of the ledger entries.
+func AdjustCredits(db *gorm.DB, account Account, amount int, reason string, expiresAt *time.Time) ([]models.CreditTransaction, error) {
+	if amount == 0 {
+		return nil, errors.New("adjustment amount can't be zero")
+	}
+	description := "Ajuste manual: " + reason
+
+	var transactions []models.CreditTransaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if amount > 0 {
+			lot, err := GrantCredits(tx, account, CreditGrant{
+				Amount:      amount,
+				Source:      models.CreditSourceAdjustment,
+				Description: description,
+				ExpiresAt:   expiresAt,
+			})
+			if err != nil {
+				return err
+			}
+			return tx.Where("credit_lot_id = ? AND type = ?", lot.ID, models.TransactionCredit).Find(&transactions).Error
+		}
+
+		var err error
+		transactions, err = ConsumeCredits(tx, account, -amount, nil, description)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if balance, err := AccountBalance(db, account); err == nil {
+		Events.PublishCredits(account, balance)
+	}
+	return transactions, nil
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+var creditTables = []interface{}{&models.User{}, &models.Organization{}, &models.CreditLot{}, &models.CreditTransaction{}}
+
+// testLot is a lot to create: expiresIn zero means it never expires, a
+// negative value that it already expired
+type testLot struct {
+	amount    int
+	expiresIn time.Duration
+}
+
+// createTestLots inserts lots in the given purchase order and syncs the
+// account's balance
+func createTestLots(t *testing.T, db *gorm.DB, account Account, lots []testLot) []models.CreditLot {
+	t.Helper()
+	now := time.Now()
+	created := make([]models.CreditLot, len(lots))
+	for i, spec := range lots {
+		lot := models.CreditLot{
+			UserID:         account.UserID,
+			OrganizationID: account.OrganizationID,
+			Source:         models.CreditSourcePackage,
+			Amount:         spec.amount,
+			Remaining:      spec.amount,
+			PurchasedAt:    now.Add(time.Duration(i-len(lots)) * time.Hour),
+		}
+		if spec.expiresIn != 0 {
+			expiresAt := now.Add(spec.expiresIn)
+			lot.ExpiresAt = &expiresAt
+		}
+		if err := db.Create(&lot).Error; err != nil {
+			t.Fatal(err)
+		}
+		created[i] = lot
+	}
+	if _, err := syncBalance(db, account, now); err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+func lotsRemaining(t *testing.T, db *gorm.DB, lots []models.CreditLot) []int {
+	t.Helper()
+	remaining := make([]int, len(lots))
+	for i, lot := range lots {
+		if err := db.Model(&models.CreditLot{}).Where("id = ?", lot.ID).Pluck("remaining", &remaining[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return remaining
+}
+
+func TestConsumeCredits(t *testing.T) {
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name          string
+		lots          []testLot
+		amount        int
+		wantRemaining []int
+		wantDebits    int
+		wantErr       error
+	}{
+		{
+			name:          "oldest-expiring first, non-expiring last",
+			lots:          []testLot{{50, 0}, {20, 10 * day}, {10, 5 * day}},
+			amount:        25,
+			wantRemaining: []int{50, 5, 0},
+			wantDebits:    2,
+		},
+		{
+			name:          "non-expiring lots by purchase date",
+			lots:          []testLot{{10, 0}, {10, 0}},
+			amount:        15,
+			wantRemaining: []int{0, 5},
+			wantDebits:    2,
+		},
+		{
+			name:          "expired lots are not spent",
+			lots:          []testLot{{100, -day}, {30, 0}},
+			amount:        20,
+			wantRemaining: []int{100, 10},
+			wantDebits:    1,
+		},
+		{
+			name:          "insufficient balance changes nothing",
+			lots:          []testLot{{100, -day}, {30, 0}},
+			amount:        40,
+			wantRemaining: []int{100, 30},
+			wantErr:       ErrInsufficientCredits,
+		},
+		{
+			name:          "exact balance",
+			lots:          []testLot{{10, day}, {5, 0}},
+			amount:        15,
+			wantRemaining: []int{0, 0},
+			wantDebits:    2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t, creditTables...)
+			user := createTestUser(t, db)
+			account := PersonalAccount(user.ID)
+			lots := createTestLots(t, db, account, tt.lots)
+			before, _ := AccountBalance(db, account)
+
+			var debits []models.CreditTransaction
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				debits, err = ConsumeCredits(tx, account, tt.amount, nil, "test")
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			remaining := lotsRemaining(t, db, lots)
+			for i := range remaining {
+				if remaining[i] != tt.wantRemaining[i] {
+					t.Fatalf("remaining = %v, want %v", remaining, tt.wantRemaining)
+				}
+			}
+			if len(debits) != tt.wantDebits {
+				t.Errorf("%d debits, want %d", len(debits), tt.wantDebits)
+			}
+
+			after, _ := AccountBalance(db, account)
+			if tt.wantErr == nil && after != before-tt.amount {
+				t.Errorf("balance %d -> %d, want %d", before, after, before-tt.amount)
+			}
+			if len(debits) > 0 && (debits[0].BalanceBefore != before || debits[len(debits)-1].BalanceAfter != after) {
+				t.Errorf("debits go from %d to %d, balance from %d to %d",
+					debits[0].BalanceBefore, debits[len(debits)-1].BalanceAfter, before, after)
+			}
+		})
+	}
+}
+
+func TestConsumeCreditsAccounts(t *testing.T) {
+	db := openTestDB(t, creditTables...)
+	user := createTestUser(t, db)
+	org := models.Organization{ID: uuid.New(), Name: "Acme", CreatedByID: user.ID}
+	if err := db.Create(&org).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	personal := PersonalAccount(user.ID)
+	shared := AccountFor(user.ID, &org.ID)
+	personalLots := createTestLots(t, db, personal, []testLot{{30, 0}})
+	sharedLots := createTestLots(t, db, shared, []testLot{{100, 0}})
+
+	tests := []struct {
+		name    string
+		account Account
+		amount  int
+		wantErr error
+	}{
+		{"organization job draws from the pool", shared, 40, nil},
+		{"personal job can't use the pool", personal, 40, ErrInsufficientCredits},
+		{"personal job uses the personal lot", personal, 10, nil},
+	}
+	for _, tt := range tests {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := ConsumeCredits(tx, tt.account, tt.amount, nil, "test")
+			return err
+		})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if got := lotsRemaining(t, db, personalLots)[0]; got != 20 {
+		t.Errorf("personal lot has %d left, want 20", got)
+	}
+	if got := lotsRemaining(t, db, sharedLots)[0]; got != 60 {
+		t.Errorf("organization lot has %d left, want 60", got)
+	}
+	if balance, _ := AccountBalance(db, personal); balance != 20 {
+		t.Errorf("personal balance = %d, want 20", balance)
+	}
+	if balance, _ := AccountBalance(db, shared); balance != 60 {
+		t.Errorf("organization balance = %d, want 60", balance)
+	}
+}
+
+func TestExpireCreditLots(t *testing.T) {
+	db := openTestDB(t, creditTables...)
+	user := createTestUser(t, db)
+	account := PersonalAccount(user.ID)
+	lots := createTestLots(t, db, account, []testLot{{40, -time.Hour}, {25, time.Hour}, {10, 0}})
+
+	expired, err := ExpireCreditLots(db, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 {
+		t.Errorf("expired %d lots, want 1", expired)
+	}
+	if remaining := lotsRemaining(t, db, lots); remaining[0] != 0 || remaining[1] != 25 || remaining[2] != 10 {
+		t.Errorf("remaining = %v, want [0 25 10]", remaining)
+	}
+	if balance, _ := AccountBalance(db, account); balance != 35 {
+		t.Errorf("balance = %d, want 35", balance)
+	}
+
+	var expiry models.CreditTransaction
+	if err := db.Where("credit_lot_id = ? AND type = ?", lots[0].ID, models.TransactionExpiry).First(&expiry).Error; err != nil {
+		t.Fatalf("no expiry transaction: %v", err)
+	}
+	if expiry.Amount != 40 {
+		t.Errorf("expiry transaction of %d minutes, want 40", expiry.Amount)
+	}
+
+	// A second run finds nothing left to expire
+	if expired, err := ExpireCreditLots(db, time.Now()); err != nil || expired != 0 {
+		t.Errorf("second run expired %d lots (err %v), want 0", expired, err)
+	}
+}
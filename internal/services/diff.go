@@ -0,0 +1,160 @@
+package services
+
+import "strings"
+
+// Diff operations
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// maxDiffEdits bounds the work done by DiffWords. Past it, the changed
+// region is reported as one deletion plus one insertion.
+const maxDiffEdits = 2000
+
+// DiffChange is a run of consecutive words with the same operation
+type DiffChange struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// DiffWords computes a word-level diff between two texts using Myers'
+// algorithm, in its linear space variant. Whitespace is normalized to single
+// spaces.
+func DiffWords(from, to string) []DiffChange {
+	a := strings.Fields(from)
+	b := strings.Fields(to)
+
+	changes := diffRange(nil, a, b)
+	if changes == nil {
+		return []DiffChange{}
+	}
+	return changes
+}
+
+// diffRange appends the diff of a and b to changes. It finds the middle
+// snake of an optimal edit path and recurses on both sides of it, so memory
+// stays linear in the input instead of growing with the square of the edits.
+func diffRange(changes []DiffChange, a, b []string) []DiffChange {
+	// Most edits touch a small part of a long transcript; trimming the
+	// common ends keeps the search small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	changes = appendDiff(changes, DiffEqual, a[:prefix])
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	tail := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		changes = appendDiff(changes, DiffInsert, b)
+	case len(b) == 0:
+		changes = appendDiff(changes, DiffDelete, a)
+	default:
+		snake, ok := middleSnake(a, b, maxDiffEdits)
+		if !ok {
+			changes = appendDiff(changes, DiffDelete, a)
+			changes = appendDiff(changes, DiffInsert, b)
+			break
+		}
+		changes = diffRange(changes, a[:snake.x], b[:snake.y])
+		changes = appendDiff(changes, DiffEqual, a[snake.x:snake.u])
+		changes = diffRange(changes, a[snake.u:], b[snake.v:])
+	}
+
+	return appendDiff(changes, DiffEqual, tail)
+}
+
+// appendDiff adds words to the result, merging them into the last change
+// when the operation matches
+func appendDiff(changes []DiffChange, op string, words []string) []DiffChange {
+	if len(words) == 0 {
+		return changes
+	}
+	text := strings.Join(words, " ")
+	if n := len(changes); n > 0 && changes[n-1].Op == op {
+		changes[n-1].Text += " " + text
+		return changes
+	}
+	return append(changes, DiffChange{Op: op, Text: text})
+}
+
+// diffSnake is a run of equal words, a[x:u] and b[y:v]
+type diffSnake struct {
+	x, y, u, v int
+}
+
+// middleSnake finds the snake in the middle of an optimal edit path from a
+// to b by searching from both ends at once. It gives up when the path needs
+// more than maxEdits edits. a and b must be non-empty, without common ends,
+// so every path has at least two edits and both sides of the snake are
+// smaller problems.
+func middleSnake(a, b []string, maxEdits int) (diffSnake, bool) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+
+	limit := (n + m + 1) / 2
+	if limit > maxEdits/2 {
+		limit = maxEdits / 2
+	}
+
+	// forward[offset+k] is the furthest x reached on diagonal k = x-y from
+	// the start; backward[offset+c] the same from the end, with x and y
+	// counted backwards and c = delta-k
+	offset := limit + 1
+	forward := make([]int, 2*limit+3)
+	backward := make([]int, 2*limit+3)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1] // down: insertion
+			} else {
+				x = forward[offset+k-1] + 1 // right: deletion
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			if c := delta - k; odd && c >= -(d-1) && c <= d-1 && x+backward[offset+c] >= n {
+				return diffSnake{startX, startY, x, y}, true
+			}
+		}
+
+		for c := -d; c <= d; c += 2 {
+			var x int
+			if c == -d || (c != d && backward[offset+c-1] < backward[offset+c+1]) {
+				x = backward[offset+c+1]
+			} else {
+				x = backward[offset+c-1] + 1
+			}
+			y := x - c
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+c] = x
+
+			if k := delta - c; !odd && k >= -d && k <= d && forward[offset+k]+x >= n {
+				return diffSnake{n - x, m - y, n - startX, m - startY}, true
+			}
+		}
+	}
+
+	return diffSnake{}, false
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     []DiffChange
+	}{
+		{"both empty", "", "", []DiffChange{}},
+		{"identical", "hola que tal", "hola  que\ntal", []DiffChange{{DiffEqual, "hola que tal"}}},
+		{"from empty", "", "hola", []DiffChange{{DiffInsert, "hola"}}},
+		{"to empty", "hola", "", []DiffChange{{DiffDelete, "hola"}}},
+		{"replaced word", "el gato negro", "el perro negro", []DiffChange{
+			{DiffEqual, "el"}, {DiffDelete, "gato"}, {DiffInsert, "perro"}, {DiffEqual, "negro"},
+		}},
+		{"inserted words", "uno cuatro", "uno dos tres cuatro", []DiffChange{
+			{DiffEqual, "uno"}, {DiffInsert, "dos tres"}, {DiffEqual, "cuatro"},
+		}},
+		{"changes in the middle", "a b c d e f", "a x c d y f", []DiffChange{
+			{DiffEqual, "a"}, {DiffDelete, "b"}, {DiffInsert, "x"}, {DiffEqual, "c d"},
+			{DiffDelete, "e"}, {DiffInsert, "y"}, {DiffEqual, "f"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffWords(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffWords(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+// applyDiff rebuilds both texts from a diff
+func applyDiff(changes []DiffChange) (string, string) {
+	var from, to []string
+	for _, c := range changes {
+		words := strings.Fields(c.Text)
+		if c.Op != DiffInsert {
+			from = append(from, words...)
+		}
+		if c.Op != DiffDelete {
+			to = append(to, words...)
+		}
+	}
+	return strings.Join(from, " "), strings.Join(to, " ")
+}
+
+// editCount counts the inserted and deleted words of a diff
+func editCount(changes []DiffChange) int {
+	edits := 0
+	for _, c := range changes {
+		if c.Op != DiffEqual {
+			edits += len(strings.Fields(c.Text))
+		}
+	}
+	return edits
+}
+
+// lcsLength is the textbook dynamic program, to check the diff is minimal
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		for j := 1; j <= len(b); j++ {
+			switch {
+			case a[i-1] == b[j-1]:
+				cur[j] = prev[j-1] + 1
+			case prev[j] > cur[j-1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j-1]
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestDiffWordsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vocabulary := []string{"a", "b", "c", "d", "e"}
+	randomWords := func() []string {
+		words := make([]string, rng.Intn(30))
+		for i := range words {
+			words[i] = vocabulary[rng.Intn(len(vocabulary))]
+		}
+		return words
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomWords(), randomWords()
+		from, to := strings.Join(a, " "), strings.Join(b, " ")
+		changes := DiffWords(from, to)
+
+		gotFrom, gotTo := applyDiff(changes)
+		if gotFrom != from || gotTo != to {
+			t.Fatalf("DiffWords(%q, %q) rebuilds %q and %q", from, to, gotFrom, gotTo)
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); editCount(changes) != want {
+			t.Fatalf("DiffWords(%q, %q) has %d edits, want %d", from, to, editCount(changes), want)
+		}
+		for j := 1; j < len(changes); j++ {
+			if changes[j].Op == changes[j-1].Op {
+				t.Fatalf("DiffWords(%q, %q) has consecutive %s changes", from, to, changes[j].Op)
+			}
+		}
+	}
+}
+
+// interleaved returns texts sharing every other word, which take 2*pairs
+// edits to turn one into the other
+func interleaved(pairs int) (string, string) {
+	var a, b []string
+	for i := 0; i < pairs; i++ {
+		same := fmt.Sprintf("w%d", i)
+		a = append(a, same, fmt.Sprintf("x%d", i))
+		b = append(b, same, fmt.Sprintf("y%d", i))
+	}
+	return strings.Join(a, " "), strings.Join(b, " ")
+}
+
+func TestDiffWordsEditLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		pairs       int
+		wantChanges int
+	}{
+		// 1800 edits: every pair is found
+		{"within the limit", maxDiffEdits/2 - 100, 3 * (maxDiffEdits/2 - 100)},
+		// 2200 edits: the common first word, then everything replaced
+		{"past the limit", maxDiffEdits/2 + 100, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := interleaved(tt.pairs)
+			changes := DiffWords(from, to)
+
+			gotFrom, gotTo := applyDiff(changes)
+			if gotFrom != from || gotTo != to {
+				t.Fatal("diff doesn't rebuild the texts")
+			}
+			if len(changes) != tt.wantChanges {
+				t.Errorf("%d changes, want %d", len(changes), tt.wantChanges)
+			}
+		})
+	}
+}
+
+func BenchmarkDiffWords(b *testing.B) {
+	from, to := interleaved(maxDiffEdits / 2)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		DiffWords(from, to)
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrOrganizationsNeedOwner is returned when erasing a user who is the last
+// owner of an organization. They must hand it over or delete it first.
+var ErrOrganizationsNeedOwner = errors.New("hand over or delete the organizations you own first")
+
+// ErasureResult reports what EraseAccount did
+type ErasureResult struct {
+	TranscriptionsDeleted int    `json:"transcriptions_deleted"`
+	CreditsForfeited      int    `json:"credits_forfeited"`
+	AuthUserDeleted       bool   `json:"auth_user_deleted"`
+	AuthError             string `json:"auth_error,omitempty"`
+}
+
+// OrganizationsOwnedAlone lists the organizations where the user is the only
+// owner, which would be left without one if the account were erased
+func OrganizationsOwnedAlone(db *gorm.DB, userID uuid.UUID) ([]models.Organization, error) {
+	var orgs []models.Organization
+	err := db.
+		Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ? AND organization_members.role = ?", userID, models.OrgRoleOwner).
+		Where("NOT EXISTS (SELECT 1 FROM organization_members other WHERE other.organization_id = organizations.id AND other.role = ? AND other.user_id <> ?)", models.OrgRoleOwner, userID).
+		Find(&orgs).Error
+	return orgs, err
+}
+
+// EraseAccount deletes a user's personal data: transcriptions with their
+// media, batches, folders, API keys, webhooks, share links they created, consent
+// history, billing profile and organization memberships. Unspent personal
+// credits are forfeited. Payments, invoices and the credit ledger are kept
+// for accounting but stripped of contact and payer details, and the user row
+// stays as an anonymized tombstone they point to. Last, the Supabase auth
+// user is deleted.
+//
+// Erasing an already erased account only retries the auth user delete, so
+// a failed call can be repeated.
+func EraseAccount(ctx context.Context, db *gorm.DB, userID uuid.UUID) (*ErasureResult, error) {
+	storage, err := NewStorageService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ErasureResult{}
+	var user models.User
+	var deletions []uuid.UUID
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+		if user.IsErased() {
+			return nil
+		}
+
+		owned, err := OrganizationsOwnedAlone(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if len(owned) > 0 {
+			return ErrOrganizationsNeedOwner
+		}
+
+		personal := PersonalAccount(user.ID)
+
+		var transcriptions []models.Transcription
+		if err := tx.Unscoped().Scopes(personal.Scope).
+			Omit("transcript_text", "transcript_json", "srt_content", "vtt_content").
+			Find(&transcriptions).Error; err != nil {
+			return fmt.Errorf("failed to load transcriptions: %w", err)
+		}
+		for i := range transcriptions {
+			deletion, err := PurgeTranscription(tx, storage, &transcriptions[i])
+			if err != nil {
+				return fmt.Errorf("failed to delete transcription %s: %w", transcriptions[i].ID, err)
+			}
+			if deletion != nil {
+				deletions = append(deletions, deletion.ID)
+			}
+		}
+		result.TranscriptionsDeleted = len(transcriptions)
+
+		if err := tx.Scopes(personal.Scope).Delete(&models.TranscriptionBatch{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Scopes(personal.Scope).Delete(&models.Folder{}).Error; err != nil {
+			return err
+		}
+
+		if balance, err := AccountBalance(tx, personal); err != nil {
+			return err
+		} else if balance > 0 {
+			if _, err := ConsumeCredits(tx, personal, balance, nil, "Cuenta eliminada"); err != nil {
+				return err
+			}
+			result.CreditsForfeited = balance
+		}
+
+		for _, model := range []interface{}{
+			&models.APIKey{},
+			&models.WebhookEndpoint{},
+			&models.EmailConsentEvent{},
+			&models.BillingProfile{},
+			&models.OrganizationMember{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("created_by_id = ?", user.ID).Delete(&models.ShareLink{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.ImpersonationSession{}).
+			Where("user_id = ? AND ended_at IS NULL", user.ID).
+			Update("ended_at", now).Error; err != nil {
+			return err
+		}
+
+		// Records other people keep: their transcripts' history and the
+		// accounting. File names go from the ledger, payer data from the
+		// provider responses and the contact email from receipts; the
+		// fiscal details of issued receipts have to stay.
+		// UpdateColumn skips the hooks that keep versions and receipts
+		// immutable; only these contact fields are cleared
+		if err := tx.Model(&models.TranscriptVersion{}).Where("author_id = ?", user.ID).UpdateColumn("author_email", "").Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CreditTransaction{}).
+			Scopes(personal.Scope).
+			Where("transcription_id IS NOT NULL").
+			Update("description", "Transcription").Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Payment{}).Where("user_id = ?", user.ID).Update("payment_details", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Invoice{}).Where("user_id = ?", user.ID).UpdateColumn("email", "").Error; err != nil {
+			return err
+		}
+
+		// SupabaseUserID stays so tokens issued before the erasure are
+		// recognized and rejected instead of signing up a new account
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"email":               fmt.Sprintf("erased-%s@erased.invalid", user.ID),
+			"plan":                "free",
+			"role":                models.RoleUser,
+			"stripe_customer_id":  "",
+			"email_notifications": false,
+			"promotional_emails":  false,
+			"erased_at":           now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range deletions {
+		if err := DeleteQueuedMedia(ctx, db, storage, id); err != nil {
+			log.Printf("Media deletion %s: %v", id, err)
+		}
+	}
+
+	if err := DeleteSupabaseUser(ctx, user.SupabaseUserID); err != nil {
+		log.Printf("Failed to delete auth user of erased account %s: %v", user.ID, err)
+		result.AuthError = err.Error()
+	} else {
+		result.AuthUserDeleted = true
+	}
+
+	return result, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+)
+
+// Live event types streamed to the owner over SSE
+const (
+	LiveTranscriptionUploaded   = "transcription.uploaded"
+	LiveTranscriptionQueued     = "transcription.queued"
+	LiveTranscriptionProcessing = "transcription.processing"
+	LiveTranscriptionCompleted  = "transcription.completed"
+	LiveTranscriptionFailed     = "transcription.failed"
+	LiveTranscriptionCancelled  = "transcription.cancelled"
+	LiveCreditsUpdated          = "credits.updated"
+)
+
+const liveSubscriberBuffer = 32
+
+// LiveEvent is a status change published to an account's subscribers
+type LiveEvent struct {
+	Type      string      `json:"type"`
+	OwnerID   uuid.UUID   `json:"-"` // see Account.OwnerID
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// EventBroker is an in-process pub/sub keyed by account owner: the user for
+// personal accounts, the organization for shared ones. Publishing never
+// blocks: a subscriber that falls behind by more than its buffer misses
+// events and is expected to refetch state.
+type EventBroker struct {
+	mu          sync.RWMutex
+	subscribers map[uuid.UUID]map[chan LiveEvent]struct{}
+}
+
+func NewEventBroker() *EventBroker {
+	return &EventBroker{subscribers: make(map[uuid.UUID]map[chan LiveEvent]struct{})}
+}
+
+// Events is the broker shared by the worker, payments and the SSE handler
+var Events = NewEventBroker()
+
+// Subscribe returns a channel receiving the owner's events and a function
+// that must be called to release it
+func (b *EventBroker) Subscribe(ownerID uuid.UUID) (<-chan LiveEvent, func()) {
+	ch := make(chan LiveEvent, liveSubscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[ownerID] == nil {
+		b.subscribers[ownerID] = make(map[chan LiveEvent]struct{})
+	}
+	b.subscribers[ownerID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[ownerID], ch)
+			if len(b.subscribers[ownerID]) == 0 {
+				delete(b.subscribers, ownerID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish sends an event to every subscriber of the owner
+func (b *EventBroker) Publish(ownerID uuid.UUID, eventType string, data interface{}) {
+	event := LiveEvent{Type: eventType, OwnerID: ownerID, Data: data, CreatedAt: time.Now()}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers[ownerID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// PublishTranscription publishes a transcription.* event with the same data
+// as the matching webhook
+func (b *EventBroker) PublishTranscription(eventType string, t *models.Transcription) {
+	b.Publish(AccountFor(t.UserID, t.OrganizationID).OwnerID(), eventType, TranscriptionEventData(t))
+}
+
+// PublishCredits publishes the account's new balance
+func (b *EventBroker) PublishCredits(account Account, creditsRemaining int) {
+	b.Publish(account.OwnerID(), LiveCreditsUpdated, map[string]interface{}{
+		"credits_remaining": creditsRemaining,
+	})
+}
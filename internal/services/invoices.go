@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+// IssueInvoice assigns the next invoice number to an approved payment. It is
+// idempotent: a payment that already has an invoice gets the existing one. It
+// must be called inside a database transaction; the invoices table is locked
+// until commit so numbers stay sequential without gaps.
+func IssueInvoice(tx *gorm.DB, payment *models.Payment) (*models.Invoice, error) {
+	if payment.Status != models.PaymentApproved {
+		return nil, errors.New("only approved payments can be invoiced")
+	}
+
+	existing, err := findInvoice(tx, payment)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	if err := tx.Exec("LOCK TABLE invoices IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+		return nil, fmt.Errorf("failed to lock invoices: %w", err)
+	}
+
+	// Check again under the lock, a concurrent request may have issued it
+	existing, err = findInvoice(tx, payment)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	var last int64
+	if err := tx.Model(&models.Invoice{}).Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := tx.Where("id = ?", payment.UserID).First(&user).Error; err != nil {
+		return nil, err
+	}
+
+	var profile models.BillingProfile
+	if err := tx.Where("user_id = ?", payment.UserID).Limit(1).Find(&profile).Error; err != nil {
+		return nil, err
+	}
+
+	// Organization purchases are billed to the organization unless the
+	// buyer's profile names a legal entity
+	if profile.LegalName == "" && payment.OrganizationID != nil {
+		var org models.Organization
+		if err := tx.Where("id = ?", *payment.OrganizationID).First(&org).Error; err != nil {
+			return nil, err
+		}
+		profile.LegalName = org.Name
+	}
+
+	issuedAt := time.Now()
+	if payment.CompletedAt != nil {
+		issuedAt = *payment.CompletedAt
+	}
+
+	subtotal := payment.OriginalAmount
+	if subtotal == 0 {
+		subtotal = payment.Amount // payments created before promo codes
+	}
+
+	invoice := models.Invoice{
+		Number:        last + 1,
+		DisplayNumber: models.FormatInvoiceNumber(config.AppConfig.InvoicePrefix, last+1),
+		PaymentID:     payment.ID,
+		UserID:        payment.UserID,
+		Email:         user.Email,
+		LegalName:     profile.LegalName,
+		TaxID:         profile.TaxID,
+		Address:       formatAddress(profile),
+		Description:   fmt.Sprintf("Paquete de créditos %s (%d minutos)", payment.PackageName, payment.CreditsAmount),
+		CreditsAmount: payment.CreditsAmount,
+		Subtotal:      subtotal,
+		Discount:      payment.DiscountAmount,
+		Total:         payment.Amount,
+		Currency:      payment.Currency,
+		IssuedAt:      issuedAt,
+	}
+	if err := tx.Create(&invoice).Error; err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	return &invoice, nil
+}
+
+func findInvoice(tx *gorm.DB, payment *models.Payment) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := tx.Where("payment_id = ?", payment.ID).Limit(1).Find(&invoice).Error; err != nil {
+		return nil, err
+	}
+	if invoice.Number == 0 {
+		return nil, nil
+	}
+	return &invoice, nil
+}
+
+func formatAddress(p models.BillingProfile) string {
+	var parts []string
+	for _, part := range []string{p.AddressLine1, p.AddressLine2, strings.TrimSpace(p.PostalCode + " " + p.City), p.State, p.Country} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// InvoicePDF renders the receipt for an invoice
+func InvoicePDF(invoice *models.Invoice, promoCode string) []byte {
+	doc := NewPDFDocument()
+	doc.Title("Litwick - Recibo " + invoice.DisplayNumber)
+	doc.Text("Fecha de emisión: " + invoice.IssuedAt.Format("02/01/2006"))
+	doc.Text("Pago: " + invoice.PaymentID.String())
+
+	doc.Heading("Cliente")
+	if invoice.LegalName != "" {
+		doc.Text(invoice.LegalName)
+	}
+	if invoice.TaxID != "" {
+		doc.Text("Identificación fiscal: " + invoice.TaxID)
+	}
+	if invoice.Address != "" {
+		doc.Text(invoice.Address)
+	}
+	doc.Text(invoice.Email)
+
+	widths := []float64{360, 135}
+
+	doc.Heading("Detalle")
+	doc.Row(true, widths, "Concepto", "Importe")
+	doc.Row(false, widths, invoice.Description, fmt.Sprintf("%.2f %s", invoice.Subtotal, invoice.Currency))
+	if invoice.Discount > 0 {
+		label := "Descuento"
+		if promoCode != "" {
+			label += " (" + promoCode + ")"
+		}
+		doc.Row(false, widths, label, fmt.Sprintf("-%.2f %s", invoice.Discount, invoice.Currency))
+	}
+	doc.Space()
+	doc.Row(true, widths, "Total", fmt.Sprintf("%.2f %s", invoice.Total, invoice.Currency))
+
+	return doc.Bytes()
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	appconfig "github.com/matills/litwick/internal/config"
+)
+
+// MailMessage is a plain text email
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+	Headers map[string]string // extra headers, such as List-Unsubscribe
+}
+
+// Mailer delivers email. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg MailMessage) error
+}
+
+// Mail is the transport used for notifications, set by ConfigureMail
+var Mail Mailer = NewCaptureMailer("")
+
+// ConfigureMail selects the transport from MAIL_TRANSPORT: smtp, or capture
+// for development and tests. Real mail carries unsubscribe links, so smtp
+// requires UNSUBSCRIBE_SECRET to sign them.
+func ConfigureMail() error {
+	cfg := appconfig.AppConfig
+	switch cfg.MailTransport {
+	case "smtp":
+		if cfg.UnsubscribeSecret == "" {
+			return fmt.Errorf("UNSUBSCRIBE_SECRET is required with MAIL_TRANSPORT=smtp")
+		}
+		Mail = &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}
+	default:
+		if cfg.MailTransport != "capture" {
+			log.Printf("Unknown MAIL_TRANSPORT %q, capturing mail instead", cfg.MailTransport)
+		}
+		if cfg.UnsubscribeSecret == "" {
+			log.Println("UNSUBSCRIBE_SECRET not set, unsubscribe links won't work")
+		}
+		Mail = NewCaptureMailer(cfg.MailCaptureDir)
+	}
+	return nil
+}
+
+// buildMessage renders an RFC 5322 message with a UTF-8 text body
+func buildMessage(from string, msg MailMessage) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@litwick>\r\n", uuid.New())
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	for name, value := range msg.Headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	buf.WriteString("\r\n")
+	buf.WriteString(msg.Body)
+	return buf.Bytes()
+}
+
+// SMTPMailer sends through an SMTP server with STARTTLS when offered
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg MailMessage) error {
+	if m.Host == "" {
+		return fmt.Errorf("SMTP host not configured")
+	}
+
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	if err := smtp.SendMail(addr, auth, from.Address, []string{msg.To}, buildMessage(m.From, msg)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// maxCapturedMessages bounds the memory a long-running capture sink uses
+const maxCapturedMessages = 200
+
+// CaptureMailer keeps the latest messages instead of sending them. With a
+// directory set, each message is also written there as an .eml file.
+type CaptureMailer struct {
+	Dir string
+
+	mu       sync.Mutex
+	messages []MailMessage
+}
+
+func NewCaptureMailer(dir string) *CaptureMailer {
+	return &CaptureMailer{Dir: dir}
+}
+
+func (m *CaptureMailer) Send(ctx context.Context, msg MailMessage) error {
+	m.mu.Lock()
+	m.messages = append(m.messages, msg)
+	if len(m.messages) > maxCapturedMessages {
+		m.messages = m.messages[len(m.messages)-maxCapturedMessages:]
+	}
+	m.mu.Unlock()
+
+	if m.Dir == "" {
+		log.Printf("Captured email to %s: %s", msg.To, msg.Subject)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), uuid.New().String()[:8])
+	return os.WriteFile(filepath.Join(m.Dir, name), buildMessage(appconfig.AppConfig.MailFrom, msg), 0o644)
+}
+
+// Messages returns the captured messages, oldest first
+func (m *CaptureMailer) Messages() []MailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MailMessage(nil), m.messages...)
+}
+
+// Reset drops the captured messages
+func (m *CaptureMailer) Reset() {
+	m.mu.Lock()
+	m.messages = nil
+	m.mu.Unlock()
+}
@@ -0,0 +1,487 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	appconfig "github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	mediaAnalysisMaxAttempts = 3
+	mediaAnalysisLease       = 30 * time.Minute
+	mediaAnalysisTimeout     = 20 * time.Minute
+	mediaAnalysisBatchSize   = 10
+
+	// The waveform is decoded to mono at waveformSampleRate and reduced to
+	// a min/max pair per peak, waveformPeaksPerSecond peaks a second
+	waveformSampleRate     = 8000
+	waveformPeaksPerSecond = 20
+
+	posterMaxWidth = 640
+)
+
+// MediaToolsAvailable reports whether ffmpeg and ffprobe can be run. Without
+// them media analysis is turned off.
+func MediaToolsAvailable() bool {
+	for _, tool := range []string{appconfig.AppConfig.FFmpegPath, appconfig.AppConfig.FFprobePath} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// MediaAnalysisKey is where a generated file of a transcription's media is
+// stored, such as "waveform.json"
+func MediaAnalysisKey(transcriptionID uuid.UUID, name string) string {
+	return fmt.Sprintf("media/%s/%s", transcriptionID, name)
+}
+
+// queueMediaAnalysisFiles queues the deletes of the waveform and poster
+// generated for a transcription. They are kept when only the source media is
+// purged, since the editor still shows them.
+func queueMediaAnalysisFiles(tx *gorm.DB, t *models.Transcription) error {
+	var analysis models.MediaAnalysis
+	err := tx.Where("transcription_id = ?", t.ID).First(&analysis).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, key := range []string{analysis.WaveformKey, analysis.PosterKey} {
+		if key == "" {
+			continue
+		}
+		if _, err := EnqueueMediaDeletion(tx, &models.MediaDeletion{
+			Key:             key,
+			Reason:          models.MediaDeletionReasonTranscriptionDeleted,
+			TranscriptionID: &t.ID,
+			UserID:          &t.UserID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mediaAnalysisSlots bounds the analyses running at once in this process, as
+// each one downloads the whole file and runs ffmpeg on it
+var mediaAnalysisSlots = make(chan struct{}, 2)
+
+// AnalyzeMediaInBackground analyzes a transcription's media as soon as a slot
+// is free. Failures are left to the retry job.
+func AnalyzeMediaInBackground(db *gorm.DB, transcriptionID uuid.UUID) {
+	if !MediaToolsAvailable() {
+		return
+	}
+	go func() {
+		mediaAnalysisSlots <- struct{}{}
+		defer func() { <-mediaAnalysisSlots }()
+
+		ctx := context.Background()
+		storage, err := NewStorageService(ctx)
+		if err != nil {
+			log.Printf("Media analysis %s: %v", transcriptionID, err)
+			return
+		}
+		if err := AnalyzeMedia(ctx, db, storage, transcriptionID); err != nil {
+			log.Printf("Media analysis %s: %v", transcriptionID, err)
+		}
+	}()
+}
+
+// claimMediaAnalysis starts an analysis, or takes over one that failed with
+// attempts left or whose worker stopped holding it, so only one worker runs it
+func claimMediaAnalysis(db *gorm.DB, transcriptionID uuid.UUID, now time.Time) (bool, error) {
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.MediaAnalysis{
+		TranscriptionID: transcriptionID,
+		Status:          models.MediaAnalysisProcessing,
+		Attempts:        1,
+	})
+	if result.Error != nil || result.RowsAffected == 1 {
+		return result.RowsAffected == 1, result.Error
+	}
+
+	result = db.Model(&models.MediaAnalysis{}).
+		Where("transcription_id = ?", transcriptionID).
+		Where("(status = ? AND attempts < ?) OR (status = ? AND updated_at < ?)",
+			models.MediaAnalysisFailed, mediaAnalysisMaxAttempts,
+			models.MediaAnalysisProcessing, now.Add(-mediaAnalysisLease)).
+		Updates(map[string]interface{}{
+			"status":     models.MediaAnalysisProcessing,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"updated_at": now,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ResetMediaAnalysis clears a transcription's analysis, failed attempts
+// included, so it can run again
+func ResetMediaAnalysis(db *gorm.DB, transcriptionID uuid.UUID) error {
+	return db.Where("transcription_id = ? AND status <> ?", transcriptionID, models.MediaAnalysisProcessing).
+		Delete(&models.MediaAnalysis{}).Error
+}
+
+// AnalyzeMedia reads the container, codecs and stream details of a
+// transcription's stored media, renders its waveform peaks and, for video, a
+// poster frame. Media that isn't in our storage or was purged is skipped.
+func AnalyzeMedia(ctx context.Context, db *gorm.DB, storage *StorageService, transcriptionID uuid.UUID) error {
+	var t models.Transcription
+	if err := db.Omit("transcript_text", "transcript_json", "srt_content", "vtt_content").
+		Where("id = ?", transcriptionID).First(&t).Error; err != nil {
+		return err
+	}
+	if !t.HasMedia() || !storage.IsStoredFile(t.FileURL) {
+		return nil
+	}
+
+	claimed, err := claimMediaAnalysis(db, t.ID, time.Now())
+	if err != nil || !claimed {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mediaAnalysisTimeout)
+	defer cancel()
+
+	analysis, err := analyzeStoredMedia(ctx, storage, &t)
+	if err != nil {
+		updates := map[string]interface{}{
+			"status": models.MediaAnalysisFailed,
+			"error":  err.Error(),
+		}
+		if analysis != nil && analysis.WaveformKey != "" {
+			updates["waveform_key"] = analysis.WaveformKey
+		}
+		if analysis != nil && analysis.PosterKey != "" {
+			updates["poster_key"] = analysis.PosterKey
+		}
+		db.Model(&models.MediaAnalysis{}).Where("transcription_id = ?", t.ID).Updates(updates)
+		return err
+	}
+
+	now := time.Now()
+	analysis.Status = models.MediaAnalysisCompleted
+	analysis.AnalyzedAt = &now
+	return db.Model(&models.MediaAnalysis{}).Where("transcription_id = ?", t.ID).
+		Select("status", "error", "container", "duration_ms", "bitrate",
+			"audio_codec", "audio_channels", "audio_sample_rate", "audio_bitrate",
+			"video_codec", "video_width", "video_height", "video_frame_rate",
+			"waveform_key", "peaks_per_second", "poster_key", "analyzed_at").
+		Updates(analysis).Error
+}
+
+// analyzeStoredMedia downloads the media to a temporary file and runs the
+// tools on it. On failure the analysis so far is returned with the error, so
+// files already stored are recorded and deleted with the transcription.
+func analyzeStoredMedia(ctx context.Context, storage *StorageService, t *models.Transcription) (*models.MediaAnalysis, error) {
+	path, err := downloadStoredMedia(ctx, storage, storage.ExtractFilePathFromURL(t.FileURL))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	analysis, err := probeMedia(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if analysis.AudioCodec != "" {
+		waveform, err := renderWaveform(ctx, path)
+		if err != nil {
+			return analysis, err
+		}
+		key := MediaAnalysisKey(t.ID, "waveform.json")
+		if err := storage.PutFile(ctx, key, waveform, "application/json"); err != nil {
+			return analysis, err
+		}
+		analysis.WaveformKey = key
+		analysis.PeaksPerSecond = waveformPeaksPerSecond
+	}
+
+	if analysis.HasVideo() {
+		poster, err := renderPoster(ctx, path, analysis.DurationMs)
+		if err != nil {
+			return analysis, err
+		}
+		key := MediaAnalysisKey(t.ID, "poster.jpg")
+		if err := storage.PutFile(ctx, key, poster, "image/jpeg"); err != nil {
+			return analysis, err
+		}
+		analysis.PosterKey = key
+	}
+
+	return analysis, nil
+}
+
+func downloadStoredMedia(ctx context.Context, storage *StorageService, key string) (string, error) {
+	resp, err := storage.OpenFile(ctx, key, "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	file, err := os.CreateTemp("", "litwick-media-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to download media: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// runMediaTool runs ffmpeg or ffprobe and returns its output, with the end of
+// its error output in the error when it fails
+func runMediaTool(ctx context.Context, tool string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, tool, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", tool, err, lastLine(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return lines[len(lines)-1]
+}
+
+// probeMedia reads the container and stream details with ffprobe. Cover art
+// attached to audio files isn't counted as video.
+func probeMedia(ctx context.Context, path string) (*models.MediaAnalysis, error) {
+	output, err := runMediaTool(ctx, appconfig.AppConfig.FFprobePath,
+		"-v", "error", "-print_format", "json", "-show_format", "-show_streams", path)
+	if err != nil {
+		return nil, err
+	}
+
+	var probe struct {
+		Format struct {
+			FormatName string `json:"format_name"`
+			Duration   string `json:"duration"`
+			BitRate    string `json:"bit_rate"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType    string `json:"codec_type"`
+			CodecName    string `json:"codec_name"`
+			Channels     int    `json:"channels"`
+			SampleRate   string `json:"sample_rate"`
+			BitRate      string `json:"bit_rate"`
+			Width        int    `json:"width"`
+			Height       int    `json:"height"`
+			AvgFrameRate string `json:"avg_frame_rate"`
+			Disposition  struct {
+				AttachedPic int `json:"attached_pic"`
+			} `json:"disposition"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to read ffprobe output: %w", err)
+	}
+
+	duration, _ := strconv.ParseFloat(probe.Format.Duration, 64)
+	bitrate, _ := strconv.ParseInt(probe.Format.BitRate, 10, 64)
+	analysis := &models.MediaAnalysis{
+		Container:  probe.Format.FormatName,
+		DurationMs: int64(duration * 1000),
+		Bitrate:    bitrate,
+	}
+
+	for _, stream := range probe.Streams {
+		switch {
+		case stream.CodecType == "audio" && analysis.AudioCodec == "":
+			analysis.AudioCodec = stream.CodecName
+			analysis.AudioChannels = stream.Channels
+			analysis.AudioSampleRate, _ = strconv.Atoi(stream.SampleRate)
+			analysis.AudioBitrate, _ = strconv.ParseInt(stream.BitRate, 10, 64)
+		case stream.CodecType == "video" && stream.Disposition.AttachedPic == 0 && analysis.VideoCodec == "":
+			analysis.VideoCodec = stream.CodecName
+			analysis.VideoWidth = stream.Width
+			analysis.VideoHeight = stream.Height
+			analysis.VideoFrameRate = parseFrameRate(stream.AvgFrameRate)
+		}
+	}
+
+	if analysis.AudioCodec == "" && analysis.VideoCodec == "" {
+		return nil, errors.New("no audio or video stream found")
+	}
+	return analysis, nil
+}
+
+// parseFrameRate reads ffprobe's "30000/1001" style rates
+func parseFrameRate(rate string) float64 {
+	num, den, ok := strings.Cut(rate, "/")
+	if !ok {
+		value, _ := strconv.ParseFloat(rate, 64)
+		return value
+	}
+	n, _ := strconv.ParseFloat(num, 64)
+	d, _ := strconv.ParseFloat(den, 64)
+	if d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// Waveform is the peaks file format, the JSON format of BBC's audiowaveform
+// that editor libraries such as peaks.js read: Data holds a min/max pair per
+// peak, scaled to 8 bits.
+type Waveform struct {
+	Version         int    `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"`
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"`
+	Data            []int8 `json:"data"`
+}
+
+// renderWaveform decodes the audio to mono PCM with ffmpeg and reduces it to
+// peaks as it streams, so long files aren't held in memory
+func renderWaveform(ctx context.Context, path string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, appconfig.AppConfig.FFmpegPath,
+		"-v", "error", "-i", path, "-vn", "-ac", "1", "-ar", strconv.Itoa(waveformSampleRate),
+		"-f", "s16le", "-acodec", "pcm_s16le", "pipe:1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	samplesPerPeak := waveformSampleRate / waveformPeaksPerSecond
+	waveform := Waveform{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      waveformSampleRate,
+		SamplesPerPixel: samplesPerPeak,
+		Bits:            8,
+		Data:            []int8{},
+	}
+
+	reader := bufio.NewReaderSize(stdout, 64*1024)
+	buf := make([]byte, 64*1024)
+	var min, max int16
+	count := 0
+	for {
+		n, err := io.ReadFull(reader, buf)
+		for i := 0; i+1 < n; i += 2 {
+			sample := int16(binary.LittleEndian.Uint16(buf[i:]))
+			if count == 0 || sample < min {
+				min = sample
+			}
+			if count == 0 || sample > max {
+				max = sample
+			}
+			count++
+			if count == samplesPerPeak {
+				waveform.Data = append(waveform.Data, int8(min>>8), int8(max>>8))
+				count = 0
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			cmd.Wait()
+			return nil, fmt.Errorf("failed to read decoded audio: %w", err)
+		}
+	}
+	if count > 0 {
+		waveform.Data = append(waveform.Data, int8(min>>8), int8(max>>8))
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, lastLine(stderr.String()))
+	}
+
+	waveform.Length = len(waveform.Data) / 2
+	return json.Marshal(waveform)
+}
+
+// renderPoster grabs a JPEG frame a second in, or from the middle of clips
+// shorter than two seconds, scaled down to posterMaxWidth
+func renderPoster(ctx context.Context, path string, durationMs int64) ([]byte, error) {
+	at := time.Second
+	if durationMs > 0 && durationMs < 2000 {
+		at = time.Duration(durationMs/2) * time.Millisecond
+	}
+	poster, err := runMediaTool(ctx, appconfig.AppConfig.FFmpegPath,
+		"-v", "error", "-ss", strconv.FormatFloat(at.Seconds(), 'f', 3, 64), "-i", path,
+		"-frames:v", "1", "-vf", fmt.Sprintf("scale='min(%d,iw)':-2", posterMaxWidth),
+		"-f", "image2", "-c:v", "mjpeg", "-q:v", "4", "pipe:1")
+	if err != nil {
+		return nil, err
+	}
+	if len(poster) == 0 {
+		return nil, errors.New("no video frame could be extracted")
+	}
+	return poster, nil
+}
+
+// AnalyzePendingMedia analyzes stored media that hasn't been analyzed yet,
+// such as uploads from before the analysis existed or whose server stopped
+// mid-way, and retries failed analyses with attempts left
+func AnalyzePendingMedia(ctx context.Context, db *gorm.DB) (int, error) {
+	storage, err := NewStorageService(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var ids []uuid.UUID
+	if err := db.Model(&models.Transcription{}).
+		Joins("LEFT JOIN media_analyses ON media_analyses.transcription_id = transcriptions.id").
+		Where("transcriptions.media_purged_at IS NULL AND transcriptions.file_url LIKE ?", storage.PublicURLPrefix()+"%").
+		Where("media_analyses.transcription_id IS NULL OR (media_analyses.status = ? AND media_analyses.attempts < ?) OR (media_analyses.status = ? AND media_analyses.updated_at < ?)",
+			models.MediaAnalysisFailed, mediaAnalysisMaxAttempts,
+			models.MediaAnalysisProcessing, now.Add(-mediaAnalysisLease)).
+		Order("transcriptions.created_at DESC").
+		Limit(mediaAnalysisBatchSize).
+		Pluck("transcriptions.id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to find media to analyze: %w", err)
+	}
+
+	analyzed := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return analyzed, ctx.Err()
+		}
+		if err := AnalyzeMedia(ctx, db, storage, id); err != nil {
+			log.Printf("Media analysis %s: %v", id, err)
+			continue
+		}
+		analyzed++
+	}
+	return analyzed, nil
+}
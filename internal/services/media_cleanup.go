@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+// uploadsFolder is where UploadFile stores media
+const uploadsFolder = "uploads"
+
+const (
+	mediaDeletionMaxAttempts = 10
+	mediaDeletionBaseBackoff = time.Minute
+	mediaDeletionMaxBackoff  = 12 * time.Hour
+	mediaDeletionLease       = 5 * time.Minute
+	mediaRetentionBatchSize  = 100
+)
+
+// finishedStatuses are the job states whose media is no longer being read
+var finishedStatuses = []models.TranscriptionStatus{models.StatusCompleted, models.StatusFailed, models.StatusCancelled}
+
+// mediaDeletionBackoff is the wait before the next attempt after the given
+// number of failed attempts: 1m, 2m, 4m... capped at 12h
+func mediaDeletionBackoff(attempts int) time.Duration {
+	backoff := mediaDeletionBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= mediaDeletionMaxBackoff {
+			return mediaDeletionMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// QueueTranscriptionMedia queues the delete of a transcription's stored
+// media. Call it in the transaction that deletes or purges the transcription
+// so the delete can't be lost. It returns nil when the media isn't in our
+// storage, such as remote URLs transcribed without copying.
+func QueueTranscriptionMedia(tx *gorm.DB, storage *StorageService, t *models.Transcription, reason string) (*models.MediaDeletion, error) {
+	if !storage.IsStoredFile(t.FileURL) {
+		return nil, nil
+	}
+	return EnqueueMediaDeletion(tx, &models.MediaDeletion{
+		Key:             storage.ExtractFilePathFromURL(t.FileURL),
+		Reason:          reason,
+		TranscriptionID: &t.ID,
+		UserID:          &t.UserID,
+		Size:            t.FileSize,
+	})
+}
+
+// EnqueueMediaDeletion queues the delete of a stored object, due right away.
+// A key that already has a pending delete isn't queued twice; the pending one
+// is returned instead.
+func EnqueueMediaDeletion(tx *gorm.DB, deletion *models.MediaDeletion) (*models.MediaDeletion, error) {
+	var existing models.MediaDeletion
+	err := tx.Where("key = ? AND status = ?", deletion.Key, models.MediaDeletionPending).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	deletion.Status = models.MediaDeletionPending
+	deletion.NextAttemptAt = &now
+	if err := tx.Create(deletion).Error; err != nil {
+		return nil, fmt.Errorf("failed to queue media deletion: %w", err)
+	}
+	return deletion, nil
+}
+
+// DeleteMediaInBackground attempts a queued delete right away. Failures are
+// left to the retry job.
+func DeleteMediaInBackground(db *gorm.DB, deletionID uuid.UUID) {
+	go func() {
+		ctx := context.Background()
+		storage, err := NewStorageService(ctx)
+		if err != nil {
+			log.Printf("Media deletion %s: %v", deletionID, err)
+			return
+		}
+		if err := DeleteQueuedMedia(ctx, db, storage, deletionID); err != nil {
+			log.Printf("Media deletion %s: %v", deletionID, err)
+		}
+	}()
+}
+
+// claimMediaDeletion leases a due delete so only one worker attempts it
+func claimMediaDeletion(db *gorm.DB, id uuid.UUID, now time.Time) (bool, error) {
+	result := db.Model(&models.MediaDeletion{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, models.MediaDeletionPending, now).
+		Update("next_attempt_at", now.Add(mediaDeletionLease))
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeleteQueuedMedia makes one attempt at a pending delete, then schedules
+// the next retry or marks it done or failed. An object that is already gone
+// counts as deleted.
+func DeleteQueuedMedia(ctx context.Context, db *gorm.DB, storage *StorageService, deletionID uuid.UUID) error {
+	claimed, err := claimMediaDeletion(db, deletionID, time.Now())
+	if err != nil || !claimed {
+		return err
+	}
+
+	var deletion models.MediaDeletion
+	if err := db.Where("id = ?", deletionID).First(&deletion).Error; err != nil {
+		return err
+	}
+
+	err = storage.DeleteFile(ctx, deletion.Key)
+	deletion.Attempts++
+
+	switch {
+	case err == nil || errors.Is(err, ErrStorageObjectNotFound):
+		completed := time.Now()
+		deletion.Status = models.MediaDeletionDone
+		deletion.CompletedAt = &completed
+		deletion.NextAttemptAt = nil
+		deletion.LastError = ""
+	case deletion.Attempts >= mediaDeletionMaxAttempts:
+		deletion.Status = models.MediaDeletionFailed
+		deletion.NextAttemptAt = nil
+		deletion.LastError = err.Error()
+	default:
+		next := time.Now().Add(mediaDeletionBackoff(deletion.Attempts))
+		deletion.NextAttemptAt = &next
+		deletion.LastError = err.Error()
+	}
+
+	return db.Model(&deletion).Select("status", "attempts", "next_attempt_at", "last_error", "completed_at").Updates(&deletion).Error
+}
+
+// DeleteDueMedia attempts every pending delete whose retry time has come
+func DeleteDueMedia(ctx context.Context, db *gorm.DB) (int, error) {
+	var ids []uuid.UUID
+	if err := db.Model(&models.MediaDeletion{}).
+		Where("status = ? AND next_attempt_at <= ?", models.MediaDeletionPending, time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(100).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	storage, err := NewStorageService(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if err := DeleteQueuedMedia(ctx, db, storage, id); err != nil {
+			log.Printf("Media deletion %s: %v", id, err)
+		}
+	}
+
+	return len(ids), nil
+}
+
+// ApplyMediaRetention deletes the source media of finished transcriptions
+// older than their plan's retention, keeping the transcripts. Organization
+// jobs follow the plan of the organization's creator. Purged jobs are marked
+// with MediaPurgedAt and can no longer be retried or reprocessed.
+func ApplyMediaRetention(ctx context.Context, db *gorm.DB, retentionDays map[string]int, now time.Time) (int, error) {
+	storage, err := NewStorageService(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for plan, days := range retentionDays {
+		if days <= 0 {
+			continue
+		}
+		cutoff := now.AddDate(0, 0, -days)
+
+		lastID := uuid.Nil
+		for {
+			if ctx.Err() != nil {
+				return purged, ctx.Err()
+			}
+
+			var batch []models.Transcription
+			if err := db.Model(&models.Transcription{}).
+				Select("transcriptions.*").
+				Joins("LEFT JOIN organizations ON organizations.id = transcriptions.organization_id").
+				Joins("JOIN users ON users.id = COALESCE(organizations.created_by_id, transcriptions.user_id)").
+				Where("users.plan = ? AND transcriptions.id > ?", plan, lastID).
+				Where("transcriptions.media_purged_at IS NULL AND transcriptions.status IN ? AND transcriptions.created_at < ?", finishedStatuses, cutoff).
+				Order("transcriptions.id").
+				Limit(mediaRetentionBatchSize).
+				Find(&batch).Error; err != nil {
+				return purged, fmt.Errorf("failed to find expired media: %w", err)
+			}
+			if len(batch) == 0 {
+				break
+			}
+			lastID = batch[len(batch)-1].ID
+
+			for i := range batch {
+				deletion, err := purgeTranscriptionMedia(db, storage, &batch[i], now)
+				if err != nil {
+					log.Printf("Failed to purge media of transcription %s: %v", batch[i].ID, err)
+					continue
+				}
+				if deletion == nil {
+					continue
+				}
+				purged++
+				if err := DeleteQueuedMedia(ctx, db, storage, deletion.ID); err != nil {
+					log.Printf("Media deletion %s: %v", deletion.ID, err)
+				}
+			}
+		}
+	}
+
+	return purged, nil
+}
+
+// purgeTranscriptionMedia marks a job's media as purged and queues its
+// delete. It returns nil when the media isn't stored with us or the job was
+// restarted in the meantime.
+func purgeTranscriptionMedia(db *gorm.DB, storage *StorageService, t *models.Transcription, now time.Time) (*models.MediaDeletion, error) {
+	if !storage.IsStoredFile(t.FileURL) {
+		return nil, nil
+	}
+
+	var deletion *models.MediaDeletion
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Transcription{}).
+			Where("id = ? AND media_purged_at IS NULL AND status IN ?", t.ID, finishedStatuses).
+			Update("media_purged_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		var err error
+		deletion, err = QueueTranscriptionMedia(tx, storage, t, models.MediaDeletionReasonRetention)
+		return err
+	})
+	return deletion, err
+}
+
+// FindOrphanedMedia lists stored uploads that no transcription points to,
+// trashed ones included, such as objects whose queued delete gave up. Objects newer than minAge are
+// skipped so uploads still being registered aren't reported, and so are
+// objects with a delete already pending.
+func FindOrphanedMedia(ctx context.Context, db *gorm.DB, storage *StorageService, minAge time.Duration) ([]StoredObject, error) {
+	objects, err := storage.ListFiles(ctx, uploadsFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileURLs []string
+	if err := db.Unscoped().Model(&models.Transcription{}).Where("media_purged_at IS NULL").Pluck("file_url", &fileURLs).Error; err != nil {
+		return nil, fmt.Errorf("failed to load media references: %w", err)
+	}
+	referenced := make(map[string]bool, len(fileURLs))
+	for _, fileURL := range fileURLs {
+		if storage.IsStoredFile(fileURL) {
+			referenced[storage.ExtractFilePathFromURL(fileURL)] = true
+		}
+	}
+
+	var pendingKeys []string
+	if err := db.Model(&models.MediaDeletion{}).Where("status = ?", models.MediaDeletionPending).Pluck("key", &pendingKeys).Error; err != nil {
+		return nil, fmt.Errorf("failed to load queued deletions: %w", err)
+	}
+	for _, key := range pendingKeys {
+		referenced[key] = true
+	}
+
+	cutoff := time.Now().Add(-minAge)
+	var orphaned []StoredObject
+	for _, object := range objects {
+		if referenced[object.Key] || object.CreatedAt.After(cutoff) {
+			continue
+		}
+		orphaned = append(orphaned, object)
+	}
+	return orphaned, nil
+}
+
+// QueueOrphanedMedia queues the delete of objects found by FindOrphanedMedia
+func QueueOrphanedMedia(db *gorm.DB, objects []StoredObject) ([]models.MediaDeletion, error) {
+	deletions := make([]models.MediaDeletion, 0, len(objects))
+	for _, object := range objects {
+		deletion, err := EnqueueMediaDeletion(db, &models.MediaDeletion{
+			Key:    object.Key,
+			Reason: models.MediaDeletionReasonOrphaned,
+			Size:   object.Size,
+		})
+		if err != nil {
+			return deletions, err
+		}
+		deletions = append(deletions, *deletion)
+	}
+	return deletions, nil
+}
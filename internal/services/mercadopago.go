@@ -5,15 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
 
-	"github.com/mercadopago/sdk-go/pkg/config"
-	"github.com/mercadopago/sdk-go/pkg/preference"
 	appconfig "github.com/matills/litwick/internal/config"
 	"github.com/matills/litwick/internal/models"
+	"github.com/mercadopago/sdk-go/pkg/config"
+	"github.com/mercadopago/sdk-go/pkg/payment"
+	"github.com/mercadopago/sdk-go/pkg/preference"
 )
 
 type MercadoPagoService struct {
-	client preference.Client
+	client        preference.Client
+	paymentClient payment.Client
 }
 
 func NewMercadoPagoService() *MercadoPagoService {
@@ -23,7 +26,8 @@ func NewMercadoPagoService() *MercadoPagoService {
 	}
 
 	return &MercadoPagoService{
-		client: preference.NewClient(cfg),
+		client:        preference.NewClient(cfg),
+		paymentClient: payment.NewClient(cfg),
 	}
 }
 
@@ -32,7 +36,7 @@ type PreferenceResponse struct {
 	PreferenceID string
 }
 
-func (s *MercadoPagoService) CreatePreference(ctx context.Context, pkg models.CreditPackage, userEmail string, paymentID string) (*PreferenceResponse, error) {
+func (s *MercadoPagoService) CreatePreference(ctx context.Context, pkg models.CreditPackage, payment models.Payment, userEmail string) (*PreferenceResponse, error) {
 	baseURL := appconfig.AppConfig.FrontendURL + "/credits"
 
 	request := preference.Request{
@@ -42,8 +46,8 @@ func (s *MercadoPagoService) CreatePreference(ctx context.Context, pkg models.Cr
 				Title:       pkg.Name + " - " + pkg.Description,
 				Description: pkg.Description,
 				Quantity:    1,
-				UnitPrice:   pkg.Price,
-				CurrencyID:  pkg.Currency,
+				UnitPrice:   payment.Amount,
+				CurrencyID:  payment.Currency,
 			},
 		},
 		Payer: &preference.PayerRequest{
@@ -55,7 +59,7 @@ func (s *MercadoPagoService) CreatePreference(ctx context.Context, pkg models.Cr
 			Pending: baseURL + "?payment_status=pending",
 		},
 		BinaryMode:          true,
-		ExternalReference:   paymentID,
+		ExternalReference:   payment.ID.String(),
 		NotificationURL:     appconfig.AppConfig.WebhookURL + "/api/payments/webhook",
 		StatementDescriptor: "LITWICK - Créditos",
 		Purpose:             "wallet_purchase",
@@ -79,3 +83,35 @@ func (s *MercadoPagoService) CreatePreference(ctx context.Context, pkg models.Cr
 		PreferenceID: resp.ID,
 	}, nil
 }
+
+// GetPayment fetches a payment from MercadoPago by its MercadoPago ID
+func (s *MercadoPagoService) GetPayment(ctx context.Context, mpPaymentID string) (*payment.Response, error) {
+	id, err := strconv.Atoi(mpPaymentID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment ID format: %w", err)
+	}
+
+	resp, err := s.paymentClient.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+	return resp, nil
+}
+
+// FindPaymentsByReference lists the MercadoPago payments made for one of our
+// payments. Each checkout attempt creates its own MercadoPago payment, so a
+// single external reference can have several.
+func (s *MercadoPagoService) FindPaymentsByReference(ctx context.Context, externalReference string) ([]payment.Response, error) {
+	resp, err := s.paymentClient.Search(ctx, payment.SearchRequest{
+		Limit: 50,
+		Filters: map[string]string{
+			"external_reference": externalReference,
+			"sort":               "date_created",
+			"criteria":           "desc",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search payments: %w", err)
+	}
+	return resp.Results, nil
+}
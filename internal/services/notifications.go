@@ -0,0 +1,367 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	appconfig "github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+// Notification emails, by template name
+const (
+	MailTranscriptionCompleted = "transcription_completed"
+	MailTranscriptionFailed    = "transcription_failed"
+	MailPaymentApproved        = "payment_approved"
+	MailCreditsLow             = "credits_low"
+)
+
+// Mailing lists a user can unsubscribe from with a signed link
+const (
+	MailListNotifications = "notifications" // User.EmailNotifications
+	MailListPromotional   = "promotional"   // User.PromotionalEmails
+)
+
+const defaultLocale = "es"
+
+// SupportedLocales are the languages notification emails are written in
+var SupportedLocales = []string{"es", "en"}
+
+// IsSupportedLocale checks a locale against SupportedLocales
+func IsSupportedLocale(locale string) bool {
+	for _, l := range SupportedLocales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+type mailTemplate struct {
+	Subject string
+	Body    string
+}
+
+// notificationTemplates holds each email per locale. Bodies get the
+// notification data plus .Link and .UnsubscribeURL.
+var notificationTemplates = map[string]map[string]mailTemplate{
+	"es": {
+		MailTranscriptionCompleted: {
+			Subject: "Tu transcripción de {{.FileName}} está lista",
+			Body: `Hola,
+
+La transcripción de "{{.FileName}}" terminó ({{.Minutes}} min).
+
+Podés verla, editarla y descargarla en:
+{{.Link}}
+`,
+		},
+		MailTranscriptionFailed: {
+			Subject: "No pudimos transcribir {{.FileName}}",
+			Body: `Hola,
+
+La transcripción de "{{.FileName}}" falló: {{.Error}}
+
+No se consumieron créditos. Podés reintentarla desde:
+{{.Link}}
+`,
+		},
+		MailPaymentApproved: {
+			Subject: "Pago aprobado: {{.Credits}} minutos acreditados",
+			Body: `Hola,
+
+Recibimos tu pago de {{.Amount}} {{.Currency}} por el paquete {{.PackageName}}.
+Acreditamos {{.Credits}} minutos{{if .Organization}} a {{.Organization}}{{end}}.
+
+Podés descargar el recibo desde:
+{{.Link}}
+`,
+		},
+		MailCreditsLow: {
+			Subject: "Te quedan {{.Balance}} minutos",
+			Body: `Hola,
+
+El saldo{{if .Organization}} de {{.Organization}}{{end}} bajó a {{.Balance}} minutos, por debajo de {{.Threshold}}.
+
+Para seguir transcribiendo sin interrupciones podés comprar más créditos en:
+{{.Link}}
+`,
+		},
+	},
+	"en": {
+		MailTranscriptionCompleted: {
+			Subject: "Your transcript of {{.FileName}} is ready",
+			Body: `Hi,
+
+The transcription of "{{.FileName}}" has finished ({{.Minutes}} min).
+
+You can view, edit and download it at:
+{{.Link}}
+`,
+		},
+		MailTranscriptionFailed: {
+			Subject: "We couldn't transcribe {{.FileName}}",
+			Body: `Hi,
+
+The transcription of "{{.FileName}}" failed: {{.Error}}
+
+No credits were used. You can retry it from:
+{{.Link}}
+`,
+		},
+		MailPaymentApproved: {
+			Subject: "Payment approved: {{.Credits}} minutes added",
+			Body: `Hi,
+
+We received your payment of {{.Amount}} {{.Currency}} for the {{.PackageName}} package.
+{{.Credits}} minutes have been added{{if .Organization}} to {{.Organization}}{{end}}.
+
+You can download the receipt from:
+{{.Link}}
+`,
+		},
+		MailCreditsLow: {
+			Subject: "You have {{.Balance}} minutes left",
+			Body: `Hi,
+
+The balance{{if .Organization}} of {{.Organization}}{{end}} dropped to {{.Balance}} minutes, below {{.Threshold}}.
+
+To keep transcribing without interruptions you can buy more credits at:
+{{.Link}}
+`,
+		},
+	},
+}
+
+var unsubscribeFooters = map[string]string{
+	"es": "\n--\nRecibís este email porque tenés activadas las notificaciones de Litwick.\nPara dejar de recibirlas: %s\n",
+	"en": "\n--\nYou get this email because Litwick notifications are on.\nTo stop receiving them: %s\n",
+}
+
+// renderNotification renders an email in the user's locale, falling back to
+// the default one
+func renderNotification(locale, kind string, data map[string]interface{}) (string, string, error) {
+	templates, ok := notificationTemplates[locale]
+	if !ok {
+		locale = defaultLocale
+		templates = notificationTemplates[locale]
+	}
+	tmpl, ok := templates[kind]
+	if !ok {
+		return "", "", fmt.Errorf("unknown notification %q", kind)
+	}
+
+	subjectTmpl, err := template.New("subject").Parse(tmpl.Subject)
+	if err != nil {
+		return "", "", err
+	}
+	bodyTmpl, err := template.New("body").Parse(tmpl.Body)
+	if err != nil {
+		return "", "", err
+	}
+
+	var subject, body bytes.Buffer
+	if err := subjectTmpl.Execute(&subject, data); err != nil {
+		return "", "", err
+	}
+	if err := bodyTmpl.Execute(&body, data); err != nil {
+		return "", "", err
+	}
+	fmt.Fprintf(&body, unsubscribeFooters[locale], data["UnsubscribeURL"])
+
+	return subject.String(), body.String(), nil
+}
+
+// UnsubscribeToken signs a user's opt-out from a mailing list, so the link
+// works without signing in
+func UnsubscribeToken(userID uuid.UUID, list string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID.String() + ":" + list))
+	return payload + "." + unsubscribeSignature(payload)
+}
+
+// ParseUnsubscribeToken verifies a token made by UnsubscribeToken
+func ParseUnsubscribeToken(token string) (uuid.UUID, string, error) {
+	invalid := errors.New("invalid unsubscribe token")
+
+	if appconfig.AppConfig.UnsubscribeSecret == "" {
+		return uuid.Nil, "", invalid
+	}
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(unsubscribeSignature(payload))) {
+		return uuid.Nil, "", invalid
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return uuid.Nil, "", invalid
+	}
+	id, list, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return uuid.Nil, "", invalid
+	}
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, "", invalid
+	}
+	return userID, list, nil
+}
+
+func unsubscribeSignature(payload string) string {
+	h := hmac.New(sha256.New, []byte(appconfig.AppConfig.UnsubscribeSecret))
+	h.Write([]byte(payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// UnsubscribeURL is the public link that opts a user out of a mailing list
+func UnsubscribeURL(userID uuid.UUID, list string) string {
+	return fmt.Sprintf("%s/api/notifications/unsubscribe?token=%s",
+		strings.TrimRight(appconfig.AppConfig.WebhookURL, "/"), url.QueryEscape(UnsubscribeToken(userID, list)))
+}
+
+// sendNotification renders and sends an email in the background if the user
+// has notifications on. Failures are logged, never returned: a notification
+// must not fail the operation that caused it.
+func sendNotification(user models.User, kind string, data map[string]interface{}) {
+	if !user.EmailNotifications || user.Email == "" {
+		return
+	}
+
+	unsubscribe := UnsubscribeURL(user.ID, MailListNotifications)
+	data["UnsubscribeURL"] = unsubscribe
+
+	subject, body, err := renderNotification(user.Locale, kind, data)
+	if err != nil {
+		log.Printf("Failed to render %s email for %s: %v", kind, user.ID, err)
+		return
+	}
+
+	msg := MailMessage{
+		To:      user.Email,
+		Subject: subject,
+		Body:    body,
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribe + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := Mail.Send(ctx, msg); err != nil {
+			log.Printf("Failed to send %s email to %s: %v", kind, user.ID, err)
+		}
+	}()
+}
+
+func frontendLink(path string) string {
+	return strings.TrimRight(appconfig.AppConfig.FrontendURL, "/") + path
+}
+
+// NotifyTranscriptionFinished emails the user who created a job when it
+// completes or fails. A failed reprocess is reported as failed even though
+// the job stays completed with its previous version.
+func NotifyTranscriptionFinished(db *gorm.DB, t *models.Transcription, failed bool) {
+	kind := MailTranscriptionCompleted
+	if failed {
+		kind = MailTranscriptionFailed
+	} else if t.Status != models.StatusCompleted {
+		return
+	}
+
+	var user models.User
+	if err := db.Where("id = ?", t.UserID).First(&user).Error; err != nil {
+		return
+	}
+
+	minutes := t.Duration / 60
+	if minutes == 0 {
+		minutes = 1
+	}
+
+	sendNotification(user, kind, map[string]interface{}{
+		"FileName": t.FileName,
+		"Minutes":  minutes,
+		"Error":    t.ErrorMessage,
+		"Link":     frontendLink("/transcriptions/" + t.ID.String()),
+	})
+}
+
+// NotifyPaymentApproved emails the buyer once a payment is settled as approved
+func NotifyPaymentApproved(db *gorm.DB, p *models.Payment) {
+	var user models.User
+	if err := db.Where("id = ?", p.UserID).First(&user).Error; err != nil {
+		return
+	}
+
+	organization := ""
+	if p.OrganizationID != nil {
+		var org models.Organization
+		if err := db.Where("id = ?", *p.OrganizationID).First(&org).Error; err == nil {
+			organization = org.Name
+		}
+	}
+
+	sendNotification(user, MailPaymentApproved, map[string]interface{}{
+		"PackageName":  p.PackageName,
+		"Credits":      p.CreditsAmount,
+		"Amount":       fmt.Sprintf("%.2f", p.Amount),
+		"Currency":     p.Currency,
+		"Organization": organization,
+		"Link":         frontendLink("/credits"),
+	})
+}
+
+// NotifyCreditsLow emails that a balance dropped below the threshold: the
+// user for a personal account, the owners and admins for an organization's
+// pool
+func NotifyCreditsLow(db *gorm.DB, account Account, balance, threshold int) {
+	data := map[string]interface{}{
+		"Balance":   balance,
+		"Threshold": threshold,
+		"Link":      frontendLink("/credits"),
+	}
+
+	if account.OrganizationID == nil {
+		var user models.User
+		if err := db.Where("id = ?", account.UserID).First(&user).Error; err != nil {
+			return
+		}
+		sendNotification(user, MailCreditsLow, data)
+		return
+	}
+
+	var org models.Organization
+	if err := db.Where("id = ?", *account.OrganizationID).First(&org).Error; err != nil {
+		return
+	}
+
+	var members []models.OrganizationMember
+	if err := db.Preload("User").
+		Where("organization_id = ? AND role IN ?", org.ID, []string{models.OrgRoleOwner, models.OrgRoleAdmin}).
+		Find(&members).Error; err != nil {
+		return
+	}
+
+	for _, member := range members {
+		memberData := map[string]interface{}{"Organization": org.Name}
+		for k, v := range data {
+			memberData[k] = v
+		}
+		sendNotification(member.User, MailCreditsLow, memberData)
+	}
+}
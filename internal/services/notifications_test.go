@@ -0,0 +1,78 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	appconfig "github.com/matills/litwick/internal/config"
+)
+
+func TestUnsubscribeToken(t *testing.T) {
+	useTestConfig(t, appconfig.Config{UnsubscribeSecret: "unsubscribe-secret"})
+	userID := uuid.New()
+	token := UnsubscribeToken(userID, MailListNotifications)
+	payload, _, _ := strings.Cut(token, ".")
+	tampered := token[:len(token)-1] + "0"
+	if strings.HasSuffix(token, "0") {
+		tampered = token[:len(token)-1] + "1"
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"round trip", token, false},
+		{"tampered signature", tampered, true},
+		{"other user's payload", UnsubscribeToken(uuid.New(), MailListNotifications)[:len(payload)] + token[len(payload):], true},
+		{"no signature", payload, true},
+		{"empty", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotID, gotList, err := ParseUnsubscribeToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (gotID != userID || gotList != MailListNotifications) {
+				t.Errorf("parsed %s %q, want %s %q", gotID, gotList, userID, MailListNotifications)
+			}
+		})
+	}
+
+	// Tokens stop working when the secret changes or isn't set
+	appconfig.AppConfig.UnsubscribeSecret = "rotated"
+	if _, _, err := ParseUnsubscribeToken(token); err == nil {
+		t.Error("token accepted after rotating the secret")
+	}
+	appconfig.AppConfig.UnsubscribeSecret = ""
+	if _, _, err := ParseUnsubscribeToken(UnsubscribeToken(userID, MailListNotifications)); err == nil {
+		t.Error("token accepted without a secret")
+	}
+}
+
+func TestConfigureMail(t *testing.T) {
+	previous := Mail
+	t.Cleanup(func() { Mail = previous })
+
+	tests := []struct {
+		name    string
+		cfg     appconfig.Config
+		wantErr bool
+	}{
+		{"smtp with a secret", appconfig.Config{MailTransport: "smtp", UnsubscribeSecret: "s"}, false},
+		{"smtp without a secret", appconfig.Config{MailTransport: "smtp"}, true},
+		{"capture without a secret", appconfig.Config{MailTransport: "capture"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, tt.cfg)
+			if err := ConfigureMail(); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PDFDocument builds simple text-only A4 PDFs (statements, receipts) using
+// the standard Helvetica fonts, so no font files or external libraries are
+// needed. Text is encoded as WinAnsi, which covers Spanish accents.
+type PDFDocument struct {
+	pages [][]string // content stream operators per page
+	y     float64
+}
+
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 50.0
+	pdfLineHeight = 14.0
+)
+
+func NewPDFDocument() *PDFDocument {
+	d := &PDFDocument{}
+	d.newPage()
+	return d
+}
+
+func (d *PDFDocument) newPage() {
+	d.pages = append(d.pages, nil)
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// ensureSpace starts a new page if less than height points remain
+func (d *PDFDocument) ensureSpace(height float64) {
+	if d.y-height < pdfMargin {
+		d.newPage()
+	}
+}
+
+func (d *PDFDocument) write(x float64, font string, size float64, text string) {
+	page := len(d.pages) - 1
+	d.pages[page] = append(d.pages[page],
+		fmt.Sprintf("BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET", font, size, x, d.y, pdfEscape(text)))
+}
+
+// Title writes a large bold line
+func (d *PDFDocument) Title(text string) {
+	d.ensureSpace(24)
+	d.y -= 6
+	d.write(pdfMargin, "F2", 18, text)
+	d.y -= 24
+}
+
+// Heading writes a bold section heading
+func (d *PDFDocument) Heading(text string) {
+	d.ensureSpace(pdfLineHeight * 2)
+	d.y -= 8
+	d.write(pdfMargin, "F2", 12, text)
+	d.y -= pdfLineHeight + 2
+}
+
+// Text writes a line of regular text
+func (d *PDFDocument) Text(text string) {
+	d.ensureSpace(pdfLineHeight)
+	d.write(pdfMargin, "F1", 10, text)
+	d.y -= pdfLineHeight
+}
+
+// Row writes one line of a table. widths are column widths in points; the
+// last column is right-aligned, which suits amounts.
+func (d *PDFDocument) Row(bold bool, widths []float64, cols ...string) {
+	d.ensureSpace(pdfLineHeight)
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	x := pdfMargin
+	for i, col := range cols {
+		width := 100.0
+		if i < len(widths) {
+			width = widths[i]
+		}
+		text := pdfTruncate(col, width, 9)
+		if i == len(cols)-1 {
+			d.write(x+width-pdfTextWidth(text, 9), font, 9, text)
+		} else {
+			d.write(x, font, 9, text)
+		}
+		x += width
+	}
+	d.y -= pdfLineHeight
+}
+
+// Space adds vertical space
+func (d *PDFDocument) Space() {
+	d.y -= pdfLineHeight / 2
+}
+
+// Bytes renders the complete PDF file
+func (d *PDFDocument) Bytes() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are fixed; each page then takes a page object and a content stream
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, ops := range d.pages {
+		content := strings.Join(ops, "\n")
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+// pdfEscape converts text to WinAnsi bytes and escapes PDF string delimiters.
+// Characters outside Latin-1 are replaced with '?'.
+func pdfEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r == '€':
+			b.WriteByte(0x80)
+		case r == '…':
+			b.WriteByte(0x85)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x100:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfTextWidth approximates the rendered width of Helvetica text. An average
+// glyph width is close enough for aligning table columns.
+func pdfTextWidth(text string, size float64) float64 {
+	return float64(utf8.RuneCountInString(text)) * size * 0.5
+}
+
+func pdfTruncate(text string, width, size float64) string {
+	maxRunes := int((width - 6) / (size * 0.5))
+	if maxRunes < 1 || utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:maxRunes-1]) + "…"
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"github.com/mercadopago/sdk-go/pkg/payment"
+	"gorm.io/gorm"
+)
+
+// Discrepancy kinds reported by the reconciler
+const (
+	DiscrepancyMissedSettlement  = "missed_settlement"   // pending locally, final at the provider
+	DiscrepancyAbandoned         = "abandoned"           // pending locally, never paid at the provider
+	DiscrepancyStatusMismatch    = "status_mismatch"     // settled locally with a different status
+	DiscrepancyAmountMismatch    = "amount_mismatch"     // provider charged a different amount
+	DiscrepancyMissingAtProvider = "missing_at_provider" // approved locally, unknown to the provider
+)
+
+type ReconcileOptions struct {
+	PendingOlderThan time.Duration // skip pending payments younger than this, their webhook may still arrive
+	RecentWindow     time.Duration // also re-check payments settled within this window
+	AbandonAfter     time.Duration // cancel pending payments without any provider payment after this
+	DryRun           bool          // report only, don't settle or cancel anything
+	Trigger          string
+}
+
+type PaymentDiscrepancy struct {
+	PaymentID         uuid.UUID            `json:"payment_id"`
+	UserID            uuid.UUID            `json:"user_id"`
+	Kind              string               `json:"kind"`
+	LocalStatus       models.PaymentStatus `json:"local_status"`
+	ProviderStatus    string               `json:"provider_status,omitempty"`
+	ProviderPaymentID string               `json:"provider_payment_id,omitempty"`
+	Action            string               `json:"action"`
+	Detail            string               `json:"detail,omitempty"`
+}
+
+type ReconciliationReport struct {
+	Checked       int                  `json:"checked"`
+	Settled       int                  `json:"settled"`
+	Discrepancies []PaymentDiscrepancy `json:"discrepancies"`
+	Errors        []string             `json:"errors"`
+}
+
+// pickProviderPayment chooses the provider payment that decides the outcome.
+// An approved attempt wins; otherwise the most recent attempt counts.
+func pickProviderPayment(results []payment.Response) *payment.Response {
+	if len(results) == 0 {
+		return nil
+	}
+	latest := &results[0]
+	for i := range results {
+		if results[i].Status == "approved" {
+			return &results[i]
+		}
+		if results[i].DateCreated.After(latest.DateCreated) {
+			latest = &results[i]
+		}
+	}
+	return latest
+}
+
+// ReconcilePayments compares pending and recently settled payments with
+// MercadoPago, settles the ones whose webhook was missed through
+// SettlePayment, cancels abandoned checkouts and reports every discrepancy.
+// The run and its report are stored as a ReconciliationRun.
+func ReconcilePayments(ctx context.Context, db *gorm.DB, mp *MercadoPagoService, opts ReconcileOptions) (*models.ReconciliationRun, *ReconciliationReport, error) {
+	now := time.Now()
+	run := models.ReconciliationRun{
+		Trigger:   opts.Trigger,
+		DryRun:    opts.DryRun,
+		StartedAt: now,
+	}
+	report := &ReconciliationReport{
+		Discrepancies: []PaymentDiscrepancy{},
+		Errors:        []string{},
+	}
+
+	var payments []models.Payment
+	if err := db.Where("(status = ? AND created_at <= ?) OR (status <> ? AND updated_at >= ?)",
+		models.PaymentPending, now.Add(-opts.PendingOlderThan),
+		models.PaymentPending, now.Add(-opts.RecentWindow)).
+		Order("created_at ASC").
+		Find(&payments).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load payments: %w", err)
+	}
+
+	for _, p := range payments {
+		if ctx.Err() != nil {
+			report.Errors = append(report.Errors, "run interrupted: "+ctx.Err().Error())
+			break
+		}
+
+		report.Checked++
+		if err := reconcilePayment(ctx, db, mp, p, opts, now, report); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("payment %s: %v", p.ID, err))
+		}
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Checked = report.Checked
+	run.Settled = report.Settled
+	run.Discrepancies = len(report.Discrepancies)
+	run.Errors = len(report.Errors)
+
+	reportJSON, _ := json.Marshal(report)
+	reportStr := string(reportJSON)
+	run.Report = &reportStr
+
+	if err := db.Create(&run).Error; err != nil {
+		return nil, report, fmt.Errorf("failed to save reconciliation run: %w", err)
+	}
+
+	return &run, report, nil
+}
+
+func reconcilePayment(ctx context.Context, db *gorm.DB, mp *MercadoPagoService, p models.Payment, opts ReconcileOptions, now time.Time, report *ReconciliationReport) error {
+	results, err := mp.FindPaymentsByReference(ctx, p.ID.String())
+	if err != nil {
+		return err
+	}
+
+	discrepancy := PaymentDiscrepancy{
+		PaymentID:   p.ID,
+		UserID:      p.UserID,
+		LocalStatus: p.Status,
+		Action:      "none",
+	}
+
+	provider := pickProviderPayment(results)
+	if provider != nil {
+		discrepancy.ProviderStatus = provider.Status
+		discrepancy.ProviderPaymentID = fmt.Sprintf("%d", provider.ID)
+	}
+
+	if p.Status == models.PaymentPending {
+		var update ProviderPaymentUpdate
+		switch {
+		case provider != nil:
+			update = ProviderUpdateFromMercadoPago(provider, SettlementReconciler)
+			if !update.IsFinal() {
+				return nil // still in process at the provider
+			}
+			discrepancy.Kind = DiscrepancyMissedSettlement
+		case now.Sub(p.CreatedAt) >= opts.AbandonAfter:
+			update = ProviderPaymentUpdate{Status: "cancelled", StatusDetail: "abandoned_checkout", Source: SettlementReconciler}
+			discrepancy.Kind = DiscrepancyAbandoned
+		default:
+			return nil
+		}
+
+		if opts.DryRun {
+			discrepancy.Action = "would set " + update.Status
+			if update.Status == "approved" && !update.MatchesOrder(&p) {
+				discrepancy.Kind = DiscrepancyAmountMismatch
+				discrepancy.Action = "would hold for review"
+			}
+			report.Discrepancies = append(report.Discrepancies, discrepancy)
+			return nil
+		}
+
+		settled, err := SettlePayment(db, p.ID, update)
+		if errors.Is(err, ErrPaymentAlreadyProcessed) {
+			// Settled by a webhook since we loaded it, nothing left to fix
+			return nil
+		}
+		if errors.Is(err, ErrPaymentAmountMismatch) {
+			discrepancy.Kind = DiscrepancyAmountMismatch
+			discrepancy.Detail = err.Error()
+			discrepancy.Action = "held for review"
+			report.Discrepancies = append(report.Discrepancies, discrepancy)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		discrepancy.Action = "set " + string(settled.Status)
+		report.Settled++
+		report.Discrepancies = append(report.Discrepancies, discrepancy)
+		return nil
+	}
+
+	// Already settled locally: report differences, never change credits automatically
+	if provider == nil {
+		if p.Status == models.PaymentApproved {
+			discrepancy.Kind = DiscrepancyMissingAtProvider
+			report.Discrepancies = append(report.Discrepancies, discrepancy)
+		}
+		return nil
+	}
+
+	if provider.Status != string(p.Status) {
+		discrepancy.Kind = DiscrepancyStatusMismatch
+		discrepancy.Detail = fmt.Sprintf("local %s, provider %s (%s)", p.Status, provider.Status, provider.StatusDetail)
+		report.Discrepancies = append(report.Discrepancies, discrepancy)
+		return nil
+	}
+
+	if p.Status == models.PaymentApproved && math.Abs(provider.TransactionAmount-p.Amount) >= 0.01 {
+		discrepancy.Kind = DiscrepancyAmountMismatch
+		discrepancy.Detail = fmt.Sprintf("local %.2f %s, provider %.2f %s", p.Amount, p.Currency, provider.TransactionAmount, provider.CurrencyID)
+		report.Discrepancies = append(report.Discrepancies, discrepancy)
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupportedMedia  = errors.New("URL does not point to an audio or video file")
+	ErrRemoteMediaTooBig = errors.New("remote file too large")
+)
+
+// remoteMediaClient fetches user-supplied media URLs
+var remoteMediaClient = &http.Client{
+	Timeout:       30 * time.Minute,
+	Transport:     publicTransport(),
+	CheckRedirect: checkPublicRedirect,
+}
+
+// RemoteMedia describes a remote file as reported by its server
+type RemoteMedia struct {
+	URL         string // after redirects
+	FileName    string
+	ContentType string
+	Size        int64 // -1 when the server doesn't report it
+}
+
+// IsMediaContentType accepts audio and video types, plus the generic binary
+// type many servers use, in which case the caller checks the extension
+func IsMediaContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "audio/") || strings.HasPrefix(mediaType, "video/") ||
+		mediaType == "application/ogg" || mediaType == "application/octet-stream"
+}
+
+// ProbeRemoteMedia fetches a remote file's type and size without downloading
+// it: a HEAD request, falling back to a one-byte range GET for servers that
+// don't support HEAD or omit the length
+func ProbeRemoteMedia(ctx context.Context, u *url.URL, maxSize int64) (*RemoteMedia, error) {
+	media, err := probeRemoteMedia(ctx, "HEAD", u)
+	if err != nil || media.Size < 0 {
+		media, err = probeRemoteMedia(ctx, "GET", u)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsMediaContentType(media.ContentType) {
+		return nil, ErrUnsupportedMedia
+	}
+	if media.Size > maxSize {
+		return nil, ErrRemoteMediaTooBig
+	}
+	return media, nil
+}
+
+func probeRemoteMedia(ctx context.Context, method string, u *url.URL) (*RemoteMedia, error) {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Litwick/1.0")
+	if method == "GET" {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := remoteMediaClient.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrUnsafeURL) {
+			return nil, ErrUnsafeURL
+		}
+		return nil, fmt.Errorf("failed to reach URL: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("URL returned status %d", resp.StatusCode)
+	}
+
+	media := &RemoteMedia{
+		URL:         resp.Request.URL.String(),
+		FileName:    remoteFileName(resp),
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        -1,
+	}
+
+	if resp.StatusCode == http.StatusPartialContent {
+		// Content-Range: bytes 0-0/12345
+		contentRange := resp.Header.Get("Content-Range")
+		if i := strings.LastIndex(contentRange, "/"); i >= 0 {
+			if size, err := strconv.ParseInt(contentRange[i+1:], 10, 64); err == nil {
+				media.Size = size
+			}
+		}
+	} else if resp.ContentLength >= 0 {
+		media.Size = resp.ContentLength
+	}
+
+	return media, nil
+}
+
+// remoteFileName takes the name from Content-Disposition, or else from the
+// last path segment of the final URL
+func remoteFileName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := path.Base(params["filename"]); name != "" && name != "." && name != "/" {
+			return name
+		}
+	}
+	name := path.Base(resp.Request.URL.Path)
+	if name == "" || name == "." || name == "/" {
+		return resp.Request.URL.Hostname()
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+// CopyRemoteMedia downloads a remote file into our storage and returns its
+// public URL. Downloads larger than maxSize are aborted, whatever the server
+// claimed during the probe.
+func CopyRemoteMedia(ctx context.Context, storage *StorageService, media *RemoteMedia, maxSize int64) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", media.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Litwick/1.0")
+
+	resp, err := remoteMediaClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+
+	// Spool to disk rather than memory: the size limit allows files far
+	// larger than what the server should buffer
+	file, err := os.CreateTemp("", "litwick-remote-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	size, err := io.Copy(file, io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+	if size > maxSize {
+		return "", ErrRemoteMediaTooBig
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return storage.UploadStream(ctx, file, size, media.FileName, media.ContentType)
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+// RequeueTranscription hands a job stuck in processing since before cutoff
+// back to the server, which runs it again from the start. Only the server
+// runs jobs, so it can also cancel them. It reports false when the job was
+// requeued by someone else or made progress in the meantime.
+func RequeueTranscription(db *gorm.DB, id uuid.UUID, cutoff time.Time) (bool, error) {
+	now := time.Now()
+	result := db.Model(&models.Transcription{}).
+		Where("id = ? AND status = ? AND updated_at < ? AND requeued_at IS NULL", id, models.StatusProcessing, cutoff).
+		Updates(map[string]interface{}{
+			"requeued_at":   now,
+			"error_message": "",
+			"updated_at":    now,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ClaimRequeuedTranscriptions takes up to limit requeued jobs for this
+// process to run. Each job is claimed with a conditional update, so with
+// several servers only one of them runs it.
+func ClaimRequeuedTranscriptions(db *gorm.DB, limit int) ([]uuid.UUID, error) {
+	var requeued []models.Transcription
+	if err := db.Select("id").
+		Where("status = ? AND requeued_at IS NOT NULL", models.StatusProcessing).
+		Order("requeued_at").
+		Limit(limit).
+		Find(&requeued).Error; err != nil {
+		return nil, err
+	}
+
+	var claimed []uuid.UUID
+	for _, t := range requeued {
+		result := db.Model(&models.Transcription{}).
+			Where("id = ? AND status = ? AND requeued_at IS NOT NULL", t.ID, models.StatusProcessing).
+			Update("requeued_at", nil)
+		if result.Error != nil {
+			return claimed, result.Error
+		}
+		if result.RowsAffected == 1 {
+			claimed = append(claimed, t.ID)
+		}
+	}
+	return claimed, nil
+}
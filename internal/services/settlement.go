@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"github.com/mercadopago/sdk-go/pkg/payment"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrPaymentAlreadyProcessed = errors.New("payment already processed")
+
+// ErrPaymentAmountMismatch is returned when the provider approved a different
+// amount or currency than the order. The payment stays pending for review.
+var ErrPaymentAmountMismatch = errors.New("approved amount does not match the order")
+
+// Settlement sources, recorded in the payment details and credit descriptions
+const (
+	SettlementWebhook    = "webhook"
+	SettlementCallback   = "callback"
+	SettlementReconciler = "reconciler"
+	SettlementPromoCode  = "promo_code" // fully discounted, nothing to charge
+)
+
+// ProviderPaymentUpdate is the provider's view of a payment
+type ProviderPaymentUpdate struct {
+	ProviderPaymentID string
+	Status            string // MercadoPago status: approved, rejected, cancelled, pending, in_process...
+	StatusDetail      string
+	PaymentMethod     string
+	PaymentType       string
+	Amount            float64
+	Currency          string
+	Source            string
+}
+
+// ProviderUpdateFromMercadoPago converts a MercadoPago payment
+func ProviderUpdateFromMercadoPago(mp *payment.Response, source string) ProviderPaymentUpdate {
+	return ProviderPaymentUpdate{
+		ProviderPaymentID: fmt.Sprintf("%d", mp.ID),
+		Status:            mp.Status,
+		StatusDetail:      mp.StatusDetail,
+		PaymentMethod:     mp.PaymentMethodID,
+		PaymentType:       mp.PaymentTypeID,
+		Amount:            mp.TransactionAmount,
+		Currency:          mp.CurrencyID,
+		Source:            source,
+	}
+}
+
+// IsFinal reports whether the provider status settles the payment
+func (u ProviderPaymentUpdate) IsFinal() bool {
+	switch u.Status {
+	case "approved", "rejected", "cancelled":
+		return true
+	}
+	return false
+}
+
+// MatchesOrder reports whether the provider charged the order's amount and
+// currency. Fully discounted orders settle with an amount of zero.
+func (u ProviderPaymentUpdate) MatchesOrder(p *models.Payment) bool {
+	return math.Abs(u.Amount-p.Amount) < 0.01 && u.Currency == p.Currency
+}
+
+// SettlePayment applies a final provider status to a pending payment. It is
+// the only path through which payments leave pending: approval grants the
+// purchased credits as a lot and issues the invoice, in the same transaction
+// as the status change. The payment row is locked, so concurrent webhooks,
+// callbacks and reconciler runs settle a payment exactly once; the losers get
+// ErrPaymentAlreadyProcessed. Non-final statuses leave the payment untouched,
+// and so does an approval for another amount or currency, which returns
+// ErrPaymentAmountMismatch without granting anything.
+func SettlePayment(db *gorm.DB, paymentID uuid.UUID, update ProviderPaymentUpdate) (*models.Payment, error) {
+	var settled models.Payment
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", paymentID).First(&settled).Error; err != nil {
+			return fmt.Errorf("payment not found: %w", err)
+		}
+		if settled.Status != models.PaymentPending {
+			return ErrPaymentAlreadyProcessed
+		}
+		if !update.IsFinal() {
+			return nil
+		}
+
+		now := time.Now()
+		if update.ProviderPaymentID != "" {
+			settled.MercadoPagoPaymentID = &update.ProviderPaymentID
+		}
+		if update.PaymentMethod != "" {
+			settled.PaymentMethod = &update.PaymentMethod
+		}
+
+		details, _ := json.Marshal(map[string]interface{}{
+			"mercadopago_payment_id": update.ProviderPaymentID,
+			"status":                 update.Status,
+			"status_detail":          update.StatusDetail,
+			"payment_type":           update.PaymentType,
+			"payment_method":         update.PaymentMethod,
+			"transaction_amount":     update.Amount,
+			"settled_by":             update.Source,
+			"processed_at":           now.Format(time.RFC3339),
+		})
+		detailsStr := string(details)
+		settled.PaymentDetails = &detailsStr
+
+		switch update.Status {
+		case "approved":
+			if !update.MatchesOrder(&settled) {
+				return fmt.Errorf("%w: approved %.2f %s, order %.2f %s", ErrPaymentAmountMismatch,
+					update.Amount, update.Currency, settled.Amount, settled.Currency)
+			}
+
+			description := fmt.Sprintf("Compra de paquete %s", settled.PackageName)
+			if update.Source != SettlementCallback {
+				description = fmt.Sprintf("%s (%s)", description, update.Source)
+			}
+
+			grant := CreditGrant{
+				Amount:      settled.CreditsAmount,
+				Source:      models.CreditSourcePackage,
+				PaymentID:   &settled.ID,
+				Description: description,
+			}
+			if settled.CreditsExpireDays > 0 {
+				expiresAt := now.AddDate(0, 0, settled.CreditsExpireDays)
+				grant.ExpiresAt = &expiresAt
+			}
+			if _, err := GrantCredits(tx, AccountFor(settled.UserID, settled.OrganizationID), grant); err != nil {
+				return err
+			}
+
+			settled.Status = models.PaymentApproved
+			settled.CompletedAt = &now
+		case "rejected":
+			settled.Status = models.PaymentRejected
+		case "cancelled":
+			settled.Status = models.PaymentCancelled
+		}
+
+		if err := tx.Save(&settled).Error; err != nil {
+			return err
+		}
+
+		if settled.Status == models.PaymentApproved {
+			if _, err := IssueInvoice(tx, &settled); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return &settled, err
+	}
+
+	if settled.Status == models.PaymentApproved {
+		account := AccountFor(settled.UserID, settled.OrganizationID)
+		if balance, err := AccountBalance(db, account); err == nil {
+			Events.PublishCredits(account, balance)
+		}
+		NotifyPaymentApproved(db, &settled)
+	}
+
+	return &settled, nil
+}
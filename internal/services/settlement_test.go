@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	appconfig "github.com/matills/litwick/internal/config"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+var settlementTables = append([]interface{}{&models.Payment{}, &models.Invoice{}, &models.BillingProfile{}}, creditTables...)
+
+func createTestPayment(t *testing.T, db *gorm.DB, user models.User, amount float64) models.Payment {
+	t.Helper()
+	payment := models.Payment{
+		UserID:         user.ID,
+		Status:         models.PaymentPending,
+		Amount:         amount,
+		OriginalAmount: 1000,
+		DiscountAmount: 1000 - amount,
+		Currency:       "ARS",
+		CreditsAmount:  300,
+		PackageName:    "Básico",
+		PackageID:      "basic",
+	}
+	if err := db.Create(&payment).Error; err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+func TestSettlePayment(t *testing.T) {
+	useTestConfig(t, appconfig.Config{InvoicePrefix: "T", FrontendURL: "http://localhost"})
+
+	tests := []struct {
+		name        string
+		amount      float64
+		update      ProviderPaymentUpdate
+		wantErr     error
+		wantStatus  models.PaymentStatus
+		wantCredits int
+	}{
+		{
+			name:        "approved",
+			amount:      1000,
+			update:      ProviderPaymentUpdate{ProviderPaymentID: "1", Status: "approved", Amount: 1000, Currency: "ARS", Source: SettlementWebhook},
+			wantStatus:  models.PaymentApproved,
+			wantCredits: 300,
+		},
+		{
+			name:        "fully discounted",
+			amount:      0,
+			update:      ProviderPaymentUpdate{Status: "approved", Amount: 0, Currency: "ARS", Source: SettlementPromoCode},
+			wantStatus:  models.PaymentApproved,
+			wantCredits: 300,
+		},
+		{
+			name:       "rejected",
+			amount:     1000,
+			update:     ProviderPaymentUpdate{ProviderPaymentID: "2", Status: "rejected", Amount: 1000, Currency: "ARS", Source: SettlementWebhook},
+			wantStatus: models.PaymentRejected,
+		},
+		{
+			name:       "still in process",
+			amount:     1000,
+			update:     ProviderPaymentUpdate{ProviderPaymentID: "3", Status: "in_process", Amount: 1000, Currency: "ARS", Source: SettlementWebhook},
+			wantStatus: models.PaymentPending,
+		},
+		{
+			name:       "approved for less",
+			amount:     1000,
+			update:     ProviderPaymentUpdate{ProviderPaymentID: "4", Status: "approved", Amount: 1, Currency: "ARS", Source: SettlementWebhook},
+			wantErr:    ErrPaymentAmountMismatch,
+			wantStatus: models.PaymentPending,
+		},
+		{
+			name:       "approved in another currency",
+			amount:     1000,
+			update:     ProviderPaymentUpdate{ProviderPaymentID: "5", Status: "approved", Amount: 1000, Currency: "USD", Source: SettlementWebhook},
+			wantErr:    ErrPaymentAmountMismatch,
+			wantStatus: models.PaymentPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t, settlementTables...)
+			user := createTestUser(t, db)
+			payment := createTestPayment(t, db, user, tt.amount)
+
+			if _, err := SettlePayment(db, payment.ID, tt.update); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			var stored models.Payment
+			db.First(&stored, "id = ?", payment.ID)
+			if stored.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", stored.Status, tt.wantStatus)
+			}
+			if balance, _ := AccountBalance(db, PersonalAccount(user.ID)); balance != tt.wantCredits {
+				t.Errorf("balance = %d, want %d", balance, tt.wantCredits)
+			}
+
+			var invoices int64
+			db.Model(&models.Invoice{}).Where("payment_id = ?", payment.ID).Count(&invoices)
+			if want := tt.wantStatus == models.PaymentApproved; (invoices == 1) != want {
+				t.Errorf("%d invoices, want invoiced=%v", invoices, want)
+			}
+		})
+	}
+}
+
+// Webhooks, callbacks and the reconciler can all report the same approval
+func TestSettlePaymentIdempotent(t *testing.T) {
+	useTestConfig(t, appconfig.Config{InvoicePrefix: "T", FrontendURL: "http://localhost"})
+	db := openTestDB(t, settlementTables...)
+	user := createTestUser(t, db)
+	payment := createTestPayment(t, db, user, 1000)
+
+	approved := ProviderPaymentUpdate{ProviderPaymentID: "1", Status: "approved", Amount: 1000, Currency: "ARS"}
+	sources := []string{SettlementWebhook, SettlementCallback, SettlementReconciler}
+
+	for i, source := range sources {
+		approved.Source = source
+		_, err := SettlePayment(db, payment.ID, approved)
+		if i == 0 && err != nil {
+			t.Fatalf("first settlement: %v", err)
+		}
+		if i > 0 && !errors.Is(err, ErrPaymentAlreadyProcessed) {
+			t.Errorf("settlement by %s: err = %v, want %v", source, err, ErrPaymentAlreadyProcessed)
+		}
+	}
+
+	// A late rejection doesn't undo the approval either
+	if _, err := SettlePayment(db, payment.ID, ProviderPaymentUpdate{Status: "rejected"}); !errors.Is(err, ErrPaymentAlreadyProcessed) {
+		t.Errorf("late rejection: err = %v, want %v", err, ErrPaymentAlreadyProcessed)
+	}
+
+	if balance, _ := AccountBalance(db, PersonalAccount(user.ID)); balance != 300 {
+		t.Errorf("balance = %d, want 300 granted once", balance)
+	}
+	var lots, invoices int64
+	db.Model(&models.CreditLot{}).Where("payment_id = ?", payment.ID).Count(&lots)
+	db.Model(&models.Invoice{}).Where("payment_id = ?", payment.ID).Count(&invoices)
+	if lots != 1 || invoices != 1 {
+		t.Errorf("%d lots and %d invoices, want one of each", lots, invoices)
+	}
+}
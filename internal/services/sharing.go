@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const shareTokenLength = 32
+
+// GenerateShareToken creates the random token that identifies a share link
+func GenerateShareToken() (string, error) {
+	token, err := randomString(shareTokenLength)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+	return token, nil
+}
+
+// HashSharePassword hashes a share link password. Unlike API keys these are
+// chosen by people, so they get a slow salted hash.
+func HashSharePassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckSharePassword compares a password with a stored hash
+func CheckSharePassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// ShareAccessTTL is how long the access token issued after a share link's
+// password check lasts
+const ShareAccessTTL = 2 * time.Hour
+
+// ShareAccessToken lets a viewer who passed a link's password check fetch its
+// media and downloads, which the browser requests without custom headers.
+// It is signed with the password hash, so changing the password revokes it.
+func ShareAccessToken(token, passwordHash string, expiresAt time.Time) string {
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expiry + "." + shareAccessSignature(token, passwordHash, expiry)
+}
+
+// CheckShareAccessToken verifies a token made by ShareAccessToken for the
+// same link and password
+func CheckShareAccessToken(access, token, passwordHash string, now time.Time) bool {
+	expiry, signature, ok := strings.Cut(access, ".")
+	if !ok || passwordHash == "" {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() >= unix {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(shareAccessSignature(token, passwordHash, expiry)))
+}
+
+func shareAccessSignature(token, passwordHash, expiry string) string {
+	h := hmac.New(sha256.New, []byte(passwordHash))
+	h.Write([]byte("share-access:" + token + ":" + expiry))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CaptionCue is one timed line of a transcript, used by the shared player
+// to highlight the text as the media plays
+type CaptionCue struct {
+	Start int    `json:"start"` // milliseconds
+	End   int    `json:"end"`   // milliseconds
+	Text  string `json:"text"`
+}
+
+// ParseSRTCues reads the cues of an SRT document. Malformed blocks are skipped.
+func ParseSRTCues(srt string) []CaptionCue {
+	cues := []CaptionCue{}
+	scanner := bufio.NewScanner(strings.NewReader(srt))
+
+	var cue *CaptionCue
+	var lines []string
+	flush := func() {
+		if cue != nil && len(lines) > 0 {
+			cue.Text = strings.Join(lines, "\n")
+			cues = append(cues, *cue)
+		}
+		cue = nil
+		lines = nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			flush()
+		case cue == nil && strings.Contains(line, "-->"):
+			parts := strings.SplitN(line, "-->", 2)
+			start, err1 := parseSRTTimestamp(parts[0])
+			end, err2 := parseSRTTimestamp(parts[1])
+			if err1 == nil && err2 == nil {
+				cue = &CaptionCue{Start: start, End: end}
+			}
+		case cue != nil:
+			lines = append(lines, line)
+		}
+	}
+	flush()
+
+	return cues
+}
+
+// parseSRTTimestamp converts HH:MM:SS,mmm to milliseconds
+func parseSRTTimestamp(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if i := strings.IndexByte(value, ' '); i >= 0 {
+		value = value[:i] // drop position settings
+	}
+	value = strings.Replace(value, ",", ".", 1)
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, err
+	}
+	return (hours*3600+minutes*60)*1000 + int(seconds*1000+0.5), nil
+}
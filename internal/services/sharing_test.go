@@ -0,0 +1,115 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSRTTimestamp(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"00:00:00,000", 0, false},
+		{"00:00:01,500", 1500, false},
+		{"01:02:03,004", 3723004, false},
+		{" 00:00:02,345 ", 2345, false},
+		{"00:00:02.345", 2345, false},
+		{"00:00:05,000 X1:100 X2:200", 5000, false},
+		{"00:05,000", 0, true},
+		{"aa:00:05,000", 0, true},
+		{"00:00:xx", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSRTTimestamp(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSRTTimestamp(%q) err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSRTTimestamp(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseSRTCues(t *testing.T) {
+	tests := []struct {
+		name string
+		srt  string
+		want []CaptionCue
+	}{
+		{"empty", "", []CaptionCue{}},
+		{
+			name: "two cues",
+			srt:  "1\n00:00:00,000 --> 00:00:01,200\nHola\n\n2\n00:00:01,200 --> 00:00:03,000\nque tal\n",
+			want: []CaptionCue{{0, 1200, "Hola"}, {1200, 3000, "que tal"}},
+		},
+		{
+			name: "multi-line text and CRLF",
+			srt:  "1\r\n00:00:00,000 --> 00:00:02,000\r\nSpeaker A:\r\nbuen día\r\n",
+			want: []CaptionCue{{0, 2000, "Speaker A:\nbuen día"}},
+		},
+		{
+			name: "extra blank lines",
+			srt:  "\n\n1\n00:00:00,000 --> 00:00:01,000\nuno\n\n\n\n2\n00:00:01,000 --> 00:00:02,000\ndos",
+			want: []CaptionCue{{0, 1000, "uno"}, {1000, 2000, "dos"}},
+		},
+		{
+			name: "malformed blocks are skipped",
+			srt:  "1\nnot a time --> 00:00:01,000\nroto\n\n2\n00:00:01,000 --> 00:00:02,000\n\n3\n00:00:02,000 --> 00:00:03,000\nbien\n",
+			want: []CaptionCue{{2000, 3000, "bien"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseSRTCues(tt.srt); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSRTCues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShareAccessToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	hash, err := HashSharePassword("secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherHash, err := HashSharePassword("secreto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	access := ShareAccessToken("link", hash, now.Add(ShareAccessTTL))
+
+	tests := []struct {
+		name   string
+		access string
+		token  string
+		hash   string
+		now    time.Time
+		want   bool
+	}{
+		{"valid", access, "link", hash, now, true},
+		{"just before expiry", access, "link", hash, now.Add(ShareAccessTTL - time.Second), true},
+		{"expired", access, "link", hash, now.Add(ShareAccessTTL), false},
+		{"other link", access, "other", hash, now, false},
+		{"password changed", access, "link", otherHash, now, false},
+		{"link without password", access, "link", "", now, false},
+		{"expiry extended", "9999999999" + access[len("1700007200"):], "link", hash, now, false},
+		{"malformed", "garbage", "link", hash, now, false},
+		{"empty", "", "link", hash, now, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckShareAccessToken(tt.access, tt.token, tt.hash, tt.now); got != tt.want {
+				t.Errorf("CheckShareAccessToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
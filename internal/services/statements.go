@@ -0,0 +1,243 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+// UsageStatement summarizes an account's credit activity for one calendar month
+type UsageStatement struct {
+	Month          string            `json:"month"` // YYYY-MM
+	PeriodStart    time.Time         `json:"period_start"`
+	PeriodEnd      time.Time         `json:"period_end"`
+	OpeningBalance int               `json:"opening_balance"`
+	ClosingBalance int               `json:"closing_balance"`
+	TotalDebited   int               `json:"total_debited"`
+	TotalCredited  int               `json:"total_credited"`
+	TotalExpired   int               `json:"total_expired"`
+	Debits         []StatementDebit  `json:"debits"`
+	Credits        []StatementCredit `json:"credits"`
+}
+
+// StatementDebit is the minutes charged for one transcription, summed over
+// every lot it was paid from
+type StatementDebit struct {
+	TranscriptionID *uuid.UUID `json:"transcription_id,omitempty"`
+	Description     string     `json:"description"`
+	Minutes         int        `json:"minutes"`
+	Date            time.Time  `json:"date"`
+}
+
+// StatementCredit is the minutes added by one payment or grant
+type StatementCredit struct {
+	PaymentID   *uuid.UUID          `json:"payment_id,omitempty"`
+	Source      models.CreditSource `json:"source"`
+	Description string              `json:"description"`
+	Minutes     int                 `json:"minutes"`
+	Amount      float64             `json:"amount,omitempty"`
+	Currency    string              `json:"currency,omitempty"`
+	Date        time.Time           `json:"date"`
+}
+
+// ParseStatementMonth parses a YYYY-MM month in the given location
+func ParseStatementMonth(month string, loc *time.Location) (time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", month, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", month)
+	}
+	return start, nil
+}
+
+// BuildUsageStatement groups the account's transactions in the month starting
+// at start: debits by transcription and credits by payment
+func BuildUsageStatement(db *gorm.DB, account Account, start time.Time) (*UsageStatement, error) {
+	end := start.AddDate(0, 1, 0)
+
+	statement := &UsageStatement{
+		Month:       start.Format("2006-01"),
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Debits:      []StatementDebit{},
+		Credits:     []StatementCredit{},
+	}
+
+	var previous models.CreditTransaction
+	err := db.Scopes(account.Scope).Where("created_at < ?", start).Order("created_at DESC").Limit(1).Find(&previous).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load opening balance: %w", err)
+	}
+	statement.OpeningBalance = previous.BalanceAfter
+	statement.ClosingBalance = previous.BalanceAfter
+
+	var transactions []models.CreditTransaction
+	if err := db.Preload("CreditLot").
+		Scopes(account.Scope).
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Order("created_at ASC").
+		Find(&transactions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load transactions: %w", err)
+	}
+
+	if len(transactions) > 0 {
+		if previous.ID == uuid.Nil {
+			statement.OpeningBalance = transactions[0].BalanceBefore
+		}
+		statement.ClosingBalance = transactions[len(transactions)-1].BalanceAfter
+	}
+
+	debits := map[string]*StatementDebit{}
+	credits := map[string]*StatementCredit{}
+	var paymentIDs []uuid.UUID
+
+	for _, t := range transactions {
+		switch t.Type {
+		case models.TransactionDebit:
+			statement.TotalDebited += t.Amount
+			key := t.ID.String()
+			if t.TranscriptionID != nil {
+				key = t.TranscriptionID.String()
+			}
+			if d, ok := debits[key]; ok {
+				d.Minutes += t.Amount
+				continue
+			}
+			debits[key] = &StatementDebit{
+				TranscriptionID: t.TranscriptionID,
+				Description:     t.Description,
+				Minutes:         t.Amount,
+				Date:            t.CreatedAt,
+			}
+
+		case models.TransactionCredit:
+			statement.TotalCredited += t.Amount
+			credit := StatementCredit{
+				Description: t.Description,
+				Minutes:     t.Amount,
+				Date:        t.CreatedAt,
+			}
+			key := t.ID.String()
+			if t.CreditLot != nil {
+				credit.Source = t.CreditLot.Source
+				if t.CreditLot.PaymentID != nil {
+					credit.PaymentID = t.CreditLot.PaymentID
+					key = t.CreditLot.PaymentID.String()
+					paymentIDs = append(paymentIDs, *t.CreditLot.PaymentID)
+				}
+			}
+			if c, ok := credits[key]; ok {
+				c.Minutes += t.Amount
+				continue
+			}
+			credits[key] = &credit
+
+		case models.TransactionExpiry:
+			statement.TotalExpired += t.Amount
+		}
+	}
+
+	if len(paymentIDs) > 0 {
+		var payments []models.Payment
+		if err := db.Where("id IN ?", paymentIDs).Find(&payments).Error; err != nil {
+			return nil, fmt.Errorf("failed to load payments: %w", err)
+		}
+		for _, p := range payments {
+			if c, ok := credits[p.ID.String()]; ok {
+				c.Amount = p.Amount
+				c.Currency = p.Currency
+			}
+		}
+	}
+
+	for _, d := range debits {
+		statement.Debits = append(statement.Debits, *d)
+	}
+	for _, c := range credits {
+		statement.Credits = append(statement.Credits, *c)
+	}
+	sort.Slice(statement.Debits, func(i, j int) bool { return statement.Debits[i].Date.Before(statement.Debits[j].Date) })
+	sort.Slice(statement.Credits, func(i, j int) bool { return statement.Credits[i].Date.Before(statement.Credits[j].Date) })
+
+	return statement, nil
+}
+
+// CSV renders the statement as one row per debit and credit
+func (s *UsageStatement) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	rows := [][]string{
+		{"date", "type", "description", "reference", "minutes", "amount", "currency"},
+	}
+	for _, c := range s.Credits {
+		ref := ""
+		if c.PaymentID != nil {
+			ref = c.PaymentID.String()
+		}
+		amount := ""
+		if c.Amount > 0 {
+			amount = strconv.FormatFloat(c.Amount, 'f', 2, 64)
+		}
+		rows = append(rows, []string{c.Date.Format(time.RFC3339), "credit", c.Description, ref, strconv.Itoa(c.Minutes), amount, c.Currency})
+	}
+	for _, d := range s.Debits {
+		ref := ""
+		if d.TranscriptionID != nil {
+			ref = d.TranscriptionID.String()
+		}
+		rows = append(rows, []string{d.Date.Format(time.RFC3339), "debit", d.Description, ref, strconv.Itoa(-d.Minutes), "", ""})
+	}
+	if s.TotalExpired > 0 {
+		rows = append(rows, []string{s.PeriodEnd.Format(time.RFC3339), "expiry", "Créditos vencidos", "", strconv.Itoa(-s.TotalExpired), "", ""})
+	}
+
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PDF renders the statement as a printable document
+func (s *UsageStatement) PDF(account string) []byte {
+	doc := NewPDFDocument()
+	doc.Title("Litwick - Resumen de uso " + s.Month)
+	doc.Text("Cuenta: " + account)
+	doc.Text(fmt.Sprintf("Período: %s al %s", s.PeriodStart.Format("02/01/2006"), s.PeriodEnd.AddDate(0, 0, -1).Format("02/01/2006")))
+	doc.Space()
+	doc.Text(fmt.Sprintf("Saldo inicial: %d min", s.OpeningBalance))
+	doc.Text(fmt.Sprintf("Acreditado: %d min   Consumido: %d min   Vencido: %d min", s.TotalCredited, s.TotalDebited, s.TotalExpired))
+	doc.Text(fmt.Sprintf("Saldo final: %d min", s.ClosingBalance))
+
+	widths := []float64{70, 290, 70, 65}
+
+	doc.Heading("Créditos")
+	doc.Row(true, widths, "Fecha", "Descripción", "Importe", "Minutos")
+	for _, c := range s.Credits {
+		amount := ""
+		if c.Amount > 0 {
+			amount = fmt.Sprintf("%.2f %s", c.Amount, c.Currency)
+		}
+		doc.Row(false, widths, c.Date.Format("02/01/2006"), c.Description, amount, fmt.Sprintf("+%d", c.Minutes))
+	}
+	if len(s.Credits) == 0 {
+		doc.Text("Sin créditos en el período")
+	}
+
+	doc.Heading("Consumos por transcripción")
+	doc.Row(true, widths, "Fecha", "Descripción", "", "Minutos")
+	for _, d := range s.Debits {
+		doc.Row(false, widths, d.Date.Format("02/01/2006"), d.Description, "", fmt.Sprintf("-%d", d.Minutes))
+	}
+	if len(s.Debits) == 0 {
+		doc.Text("Sin consumos en el período")
+	}
+
+	return doc.Bytes()
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +16,9 @@ import (
 	appconfig "github.com/matills/litwick/internal/config"
 )
 
+// ErrStorageObjectNotFound is returned when deleting an object that doesn't exist
+var ErrStorageObjectNotFound = errors.New("storage object not found")
+
 type StorageService struct {
 	supabaseURL string
 	serviceKey  string
@@ -29,39 +34,60 @@ func NewStorageService(ctx context.Context) (*StorageService, error) {
 }
 
 func (s *StorageService) UploadFile(ctx context.Context, file io.Reader, filename string, contentType string) (string, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return s.UploadStream(ctx, bytes.NewReader(fileBytes), int64(len(fileBytes)), filename, contentType)
+}
+
+// UploadStream uploads size bytes read from body under a new name, without
+// holding the file in memory
+func (s *StorageService) UploadStream(ctx context.Context, body io.Reader, size int64, filename string, contentType string) (string, error) {
 	ext := filepath.Ext(filename)
 	uniqueFilename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
 	path := fmt.Sprintf("uploads/%s", uniqueFilename)
 
-	fileBytes, err := io.ReadAll(file)
-	if err != nil {
-		return "", fmt.Errorf("failed to read file: %w", err)
+	if err := s.putObject(ctx, path, body, size, contentType); err != nil {
+		return "", err
 	}
 
-	url := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.supabaseURL, s.bucket, path)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(fileBytes))
+	publicURL := fmt.Sprintf("%s/storage/v1/object/public/%s/%s", s.supabaseURL, s.bucket, path)
+
+	return publicURL, nil
+}
+
+// PutFile stores data under the given key, replacing any object already there
+func (s *StorageService) PutFile(ctx context.Context, key string, data []byte, contentType string) error {
+	return s.putObject(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+func (s *StorageService) putObject(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	url := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.supabaseURL, s.bucket, key)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
+	req.ContentLength = size
 
 	req.Header.Set("Authorization", "Bearer "+s.serviceKey)
 	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("x-upsert", "true")
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload file: %w", err)
+		return fmt.Errorf("failed to upload file: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
-	publicURL := fmt.Sprintf("%s/storage/v1/object/public/%s/%s", s.supabaseURL, s.bucket, path)
-
-	return publicURL, nil
+	return nil
 }
 
 func (s *StorageService) GetPresignedURL(ctx context.Context, key string, duration time.Duration) (string, error) {
@@ -95,6 +121,36 @@ func (s *StorageService) GetPresignedURL(ctx context.Context, key string, durati
 	return string(body), nil
 }
 
+// OpenFile streams a stored object. A Range header value, if given, is
+// forwarded so media players can seek. The caller must close the body.
+func (s *StorageService) OpenFile(ctx context.Context, key, rangeHeader string) (*http.Response, error) {
+	url := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.supabaseURL, s.bucket, key)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+s.serviceKey)
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("open failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
 func (s *StorageService) DeleteFile(ctx context.Context, key string) error {
 	url := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.supabaseURL, s.bucket, key)
 
@@ -114,14 +170,102 @@ func (s *StorageService) DeleteFile(ctx context.Context, key string) error {
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(resp.Body)
+		// Supabase reports missing objects as a 400 with a not_found error
+		if resp.StatusCode == http.StatusNotFound || strings.Contains(string(body), "not_found") {
+			return ErrStorageObjectNotFound
+		}
 		return fmt.Errorf("delete failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	return nil
 }
 
+// StoredObject is an entry of a storage listing
+type StoredObject struct {
+	Key       string    `json:"key"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const storageListPageSize = 1000
+
+// ListFiles lists the objects directly under a folder of the bucket, such as
+// "uploads"
+func (s *StorageService) ListFiles(ctx context.Context, prefix string) ([]StoredObject, error) {
+	url := fmt.Sprintf("%s/storage/v1/object/list/%s", s.supabaseURL, s.bucket)
+	client := &http.Client{}
+
+	var objects []StoredObject
+	for offset := 0; ; offset += storageListPageSize {
+		body, err := json.Marshal(map[string]interface{}{
+			"prefix": prefix,
+			"limit":  storageListPageSize,
+			"offset": offset,
+			"sortBy": map[string]string{"column": "name", "order": "asc"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+s.serviceKey)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+
+		var page []struct {
+			ID        *string   `json:"id"` // null for folders
+			Name      string    `json:"name"`
+			CreatedAt time.Time `json:"created_at"`
+			Metadata  struct {
+				Size int64 `json:"size"`
+			} `json:"metadata"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("list failed with status %d: %s", resp.StatusCode, string(respBody))
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode file list: %w", err)
+		}
+
+		for _, entry := range page {
+			if entry.ID == nil {
+				continue
+			}
+			objects = append(objects, StoredObject{
+				Key:       strings.TrimSuffix(prefix, "/") + "/" + entry.Name,
+				Size:      entry.Metadata.Size,
+				CreatedAt: entry.CreatedAt,
+			})
+		}
+		if len(page) < storageListPageSize {
+			return objects, nil
+		}
+	}
+}
+
+// PublicURLPrefix is what the URLs of objects in our bucket start with
+func (s *StorageService) PublicURLPrefix() string {
+	return fmt.Sprintf("%s/storage/v1/object/public/%s/", s.supabaseURL, s.bucket)
+}
+
+// IsStoredFile reports whether a URL points into our storage bucket, as
+// opposed to remote media that was transcribed in place
+func (s *StorageService) IsStoredFile(fileURL string) bool {
+	return strings.HasPrefix(fileURL, s.PublicURLPrefix())
+}
+
 func (s *StorageService) ExtractFilePathFromURL(fileURL string) string {
-	prefix := fmt.Sprintf("%s/storage/v1/object/public/%s/", s.supabaseURL, s.bucket)
-	path := strings.TrimPrefix(fileURL, prefix)
+	path := strings.TrimPrefix(fileURL, s.PublicURLPrefix())
 	return path
 }
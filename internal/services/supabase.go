@@ -1,10 +1,14 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/matills/litwick/internal/config"
@@ -60,3 +64,33 @@ func ParseSupabaseUser(data []byte) (*SupabaseUser, error) {
 	}
 	return &user, nil
 }
+
+var supabaseAdminClient = &http.Client{Timeout: 15 * time.Second}
+
+// DeleteSupabaseUser deletes a user from Supabase Auth with the service key.
+// A user that no longer exists counts as deleted.
+func DeleteSupabaseUser(ctx context.Context, supabaseUserID string) error {
+	url := fmt.Sprintf("%s/auth/v1/admin/users/%s", config.AppConfig.SupabaseURL, supabaseUserID)
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("apikey", config.AppConfig.SupabaseServiceKey)
+	req.Header.Set("Authorization", "Bearer "+config.AppConfig.SupabaseServiceKey)
+
+	resp, err := supabaseAdminClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete auth user: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("auth user delete failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
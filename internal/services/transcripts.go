@@ -0,0 +1,45 @@
+package services
+
+import (
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+// RecordTranscriptVersion stores the transcription's current content as its
+// next version and points the transcription at it. author is nil for
+// provider results. The caller holds a lock on the transcription row and
+// saves it afterwards.
+func RecordTranscriptVersion(tx *gorm.DB, t *models.Transcription, reason string, author *models.User, restoredFrom *int) (*models.TranscriptVersion, error) {
+	var latest int
+	if err := tx.Model(&models.TranscriptVersion{}).
+		Where("transcription_id = ?", t.ID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+
+	version := models.TranscriptVersion{
+		TranscriptionID: t.ID,
+		Version:         latest + 1,
+		Reason:          reason,
+		RestoredFrom:    restoredFrom,
+		Language:        t.Language,
+		SpeakerLabels:   t.SpeakerLabels,
+		AssemblyAIID:    t.AssemblyAIID,
+		TranscriptText:  t.TranscriptText,
+		SRTContent:      t.SRTContent,
+		VTTContent:      t.VTTContent,
+		Duration:        t.Duration,
+		CompletedAt:     t.CompletedAt,
+	}
+	if author != nil {
+		version.AuthorID = &author.ID
+		version.AuthorEmail = author.Email
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return nil, err
+	}
+
+	t.Version = version.Version
+	return &version, nil
+}
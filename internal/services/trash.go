@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+// TrashRetention is how long a deleted transcription can be restored before
+// it and its media are removed for good
+const TrashRetention = 30 * 24 * time.Hour
+
+// TrashPurgeAt is when a trashed transcription will be permanently deleted
+func TrashPurgeAt(t *models.Transcription) *time.Time {
+	if !t.DeletedAt.Valid {
+		return nil
+	}
+	purgeAt := t.DeletedAt.Time.Add(TrashRetention)
+	return &purgeAt
+}
+
+// PurgeTranscription permanently deletes a transcription. Its versions and
+// share links go with it through the foreign keys, and the deletes of its
+// media and the files generated from it are queued in the same transaction. The queued delete is returned so
+// the caller can attempt it right away.
+func PurgeTranscription(db *gorm.DB, storage *StorageService, t *models.Transcription) (*models.MediaDeletion, error) {
+	var deletion *models.MediaDeletion
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := queueMediaAnalysisFiles(tx, t); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(t).Error; err != nil {
+			return err
+		}
+		// Media purged by the retention policy was queued back then
+		if !t.HasMedia() {
+			return nil
+		}
+		var err error
+		deletion, err = QueueTranscriptionMedia(tx, storage, t, models.MediaDeletionReasonTranscriptionDeleted)
+		return err
+	})
+	return deletion, err
+}
+
+// PurgeExpiredTrash permanently deletes transcriptions that have been in the
+// trash for longer than TrashRetention
+func PurgeExpiredTrash(ctx context.Context, db *gorm.DB, now time.Time) (int, error) {
+	storage, err := NewStorageService(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for {
+		if ctx.Err() != nil {
+			return purged, ctx.Err()
+		}
+
+		var batch []models.Transcription
+		if err := db.Unscoped().
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", now.Add(-TrashRetention)).
+			Omit("transcript_text", "transcript_json", "srt_content", "vtt_content").
+			Order("deleted_at").
+			Limit(mediaRetentionBatchSize).
+			Find(&batch).Error; err != nil {
+			return purged, fmt.Errorf("failed to find expired trash: %w", err)
+		}
+		if len(batch) == 0 {
+			return purged, nil
+		}
+
+		for i := range batch {
+			deletion, err := PurgeTranscription(db, storage, &batch[i])
+			if err != nil {
+				// Stop rather than pick the same rows up again forever
+				return purged, fmt.Errorf("failed to purge transcription %s: %w", batch[i].ID, err)
+			}
+			purged++
+			if deletion != nil {
+				if err := DeleteQueuedMedia(ctx, db, storage, deletion.ID); err != nil {
+					log.Printf("Media deletion %s: %v", deletion.ID, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/matills/litwick/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	webhookMaxAttempts   = 8
+	webhookBaseBackoff   = 30 * time.Second
+	webhookMaxBackoff    = 6 * time.Hour
+	webhookLease         = 2 * time.Minute
+	webhookMaxBodyLogged = 2048
+)
+
+// webhookClient delivers to user-supplied URLs, so like remoteMediaClient it
+// only reaches public addresses, redirects included
+var webhookClient = &http.Client{
+	Timeout:       10 * time.Second,
+	Transport:     publicTransport(),
+	CheckRedirect: checkPublicRedirect,
+}
+
+// WebhookEnvelope is the JSON body posted to endpoints. ID identifies the
+// event and is the same across retries and manual redeliveries, so receivers
+// can deduplicate on it.
+type WebhookEnvelope struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// GenerateWebhookSecret creates the HMAC key for a new endpoint
+func GenerateWebhookSecret() (string, error) {
+	secret, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + secret, nil
+}
+
+// SignWebhookPayload computes the v1 signature sent in X-Litwick-Signature:
+// hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the endpoint secret
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", timestamp)
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// webhookBackoff is the wait before the next attempt after the given number
+// of failed attempts: 30s, 1m, 2m, 4m... capped at 6h
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return backoff
+}
+
+// EnqueueWebhookEvent creates a delivery for every active endpoint of the
+// user subscribed to the event, and attempts them right away in the background
+func EnqueueWebhookEvent(db *gorm.DB, userID uuid.UUID, event string, data interface{}) error {
+	var endpoints []models.WebhookEndpoint
+	if err := db.Where("user_id = ? AND active = ?", userID, true).Find(&endpoints).Error; err != nil {
+		return fmt.Errorf("failed to load webhook endpoints: %w", err)
+	}
+
+	payload, err := json.Marshal(WebhookEnvelope{
+		ID:        "evt_" + uuid.New().String(),
+		Event:     event,
+		CreatedAt: time.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	now := time.Now()
+	for _, endpoint := range endpoints {
+		subscribed := false
+		for _, e := range endpoint.EventList() {
+			if e == event {
+				subscribed = true
+				break
+			}
+		}
+		if !subscribed {
+			continue
+		}
+
+		delivery := models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			UserID:        userID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := db.Create(&delivery).Error; err != nil {
+			return fmt.Errorf("failed to create webhook delivery: %w", err)
+		}
+
+		go func(id uuid.UUID) {
+			if err := DeliverWebhook(context.Background(), db, id); err != nil {
+				log.Printf("Webhook delivery %s: %v", id, err)
+			}
+		}(delivery.ID)
+	}
+
+	return nil
+}
+
+// RedeliverWebhook queues a new delivery with the same payload as an earlier one
+func RedeliverWebhook(db *gorm.DB, original *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	now := time.Now()
+	delivery := models.WebhookDelivery{
+		EndpointID:    original.EndpointID,
+		UserID:        original.UserID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &now,
+		RedeliveryOf:  &original.ID,
+	}
+	if err := db.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := DeliverWebhook(context.Background(), db, delivery.ID); err != nil {
+			log.Printf("Webhook redelivery %s: %v", delivery.ID, err)
+		}
+	}()
+
+	return &delivery, nil
+}
+
+// claimDelivery leases a due delivery so only one worker attempts it
+func claimDelivery(db *gorm.DB, id uuid.UUID, now time.Time) (bool, error) {
+	result := db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, models.DeliveryPending, now).
+		Update("next_attempt_at", now.Add(webhookLease))
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeliverWebhook makes one attempt at a pending delivery, then schedules the
+// next retry or marks it succeeded or failed
+func DeliverWebhook(ctx context.Context, db *gorm.DB, deliveryID uuid.UUID) error {
+	now := time.Now()
+	claimed, err := claimDelivery(db, deliveryID, now)
+	if err != nil || !claimed {
+		return err
+	}
+
+	var delivery models.WebhookDelivery
+	if err := db.Preload("Endpoint").Where("id = ?", deliveryID).First(&delivery).Error; err != nil {
+		return err
+	}
+
+	attempt := models.WebhookAttempt{DeliveryID: delivery.ID}
+	start := time.Now()
+
+	if !delivery.Endpoint.Active {
+		attempt.Error = "endpoint disabled"
+	} else {
+		attempt.ResponseCode, attempt.ResponseBody, err = postWebhook(ctx, &delivery)
+		if err != nil {
+			attempt.Error = err.Error()
+		} else if attempt.ResponseCode < 200 || attempt.ResponseCode >= 300 {
+			attempt.Error = fmt.Sprintf("unexpected status %d", attempt.ResponseCode)
+		}
+	}
+	attempt.DurationMs = time.Since(start).Milliseconds()
+
+	delivery.Attempts++
+	delivery.LastResponseCode = attempt.ResponseCode
+	delivery.LastError = attempt.Error
+
+	switch {
+	case attempt.Error == "":
+		delivered := time.Now()
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &delivered
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= webhookMaxAttempts || !delivery.Endpoint.Active:
+		delivery.Status = models.DeliveryFailed
+		delivery.NextAttemptAt = nil
+	default:
+		next := time.Now().Add(webhookBackoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		return tx.Model(&delivery).Select("status", "attempts", "last_response_code", "last_error", "next_attempt_at", "delivered_at").Updates(&delivery).Error
+	})
+}
+
+func postWebhook(ctx context.Context, delivery *models.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+	signature := SignWebhookPayload(delivery.Endpoint.Secret, timestamp, body)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", delivery.Endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Litwick-Webhooks/1.0")
+	req.Header.Set("X-Litwick-Event", delivery.Event)
+	req.Header.Set("X-Litwick-Delivery", delivery.ID.String())
+	req.Header.Set("X-Litwick-Signature", fmt.Sprintf("ts=%d,v1=%s", timestamp, signature))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxBodyLogged))
+	return resp.StatusCode, strings.ToValidUTF8(string(respBody), ""), nil
+}
+
+// DeliverDueWebhooks attempts every pending delivery whose retry time has come
+func DeliverDueWebhooks(ctx context.Context, db *gorm.DB) (int, error) {
+	var ids []uuid.UUID
+	if err := db.Model(&models.WebhookDelivery{}).
+		Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(100).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if err := DeliverWebhook(ctx, db, id); err != nil {
+			log.Printf("Webhook delivery %s: %v", id, err)
+		}
+	}
+
+	return len(ids), nil
+}
+
+// TranscriptionEventData is the data sent with transcription.* events. The
+// transcript itself is left out; receivers fetch it from the API.
+func TranscriptionEventData(t *models.Transcription) map[string]interface{} {
+	return map[string]interface{}{
+		"transcription": map[string]interface{}{
+			"id":            t.ID,
+			"file_name":     t.FileName,
+			"status":        t.Status,
+			"language":      t.Language,
+			"duration":      t.Duration,
+			"credits_used":  t.CreditsUsed,
+			"error_message": t.ErrorMessage,
+			"created_at":    t.CreatedAt,
+			"completed_at":  t.CompletedAt,
+		},
+	}
+}
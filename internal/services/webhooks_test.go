@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	payload := []byte(`{"id":"evt_1"}`)
+	// printf '1700000000.{"id":"evt_1"}' | openssl dgst -sha256 -hmac whsec_test
+	want := "c89214b5b5da833daed6f0b8c5bb6bd58cea9022bd80ccc78230f3942d632925"
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		payload   []byte
+		match     bool
+	}{
+		{"same input", "whsec_test", 1700000000, payload, true},
+		{"other secret", "whsec_other", 1700000000, payload, false},
+		{"other timestamp", "whsec_test", 1700000001, payload, false},
+		{"other body", "whsec_test", 1700000000, []byte(`{"id":"evt_2"}`), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignWebhookPayload(tt.secret, tt.timestamp, tt.payload); (got == want) != tt.match {
+				t.Errorf("SignWebhookPayload() = %s, match %v", got, tt.match)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{webhookMaxAttempts * 10, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempts); got != tt.want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}